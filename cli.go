@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settingFlags maps the command-line flags to the environment variables they
+// override. Secrets such as the GCP credentials are only read from the
+// environment.
+var settingFlags = []struct {
+	flag  string
+	env   string
+	usage string
+}{
+	{"project-id", "GCP_PROJECT_ID", "GCP project to tail the logs from"},
+	{"project-ids", "GCP_PROJECT_IDS", "comma-separated GCP projects, for networks spanning several"},
+	{"network", "PENUMBRA_NETWORK", "name of the monitored network"},
+	{"networks-config", "NETWORKS_CONFIG", "JSON file describing several networks to monitor"},
+	{"state-file", "STATE_FILE", "file persisting the monitor state across restarts"},
+	{"sqlite-path", "SQLITE_PATH", "SQLite database recording every commit log, requires -tags sqlite"},
+	{"audit-log-path", "AUDIT_LOG_PATH", "file recording every alert as newline-delimited JSON"},
+	{"audit-max-size-mb", "AUDIT_MAX_SIZE_MB", "size at which the audit log is rotated and compressed (default 100)"},
+	{"maintenance-window", "MAINTENANCE_WINDOW", "length of the maintenance windows opened without an end, e.g. with SIGUSR1 (default 1h)"},
+	{"expected-pods", "EXPECTED_PODS", "comma-separated pods tracked for liveness from startup"},
+	{"include-pods", "INCLUDE_PODS", "comma-separated pod name globs whose roots are compared, default all"},
+	{"exclude-pods", "EXCLUDE_PODS", "comma-separated pod name globs left out of the root comparison, e.g. *-archive-*"},
+	{"reference-pod", "REFERENCE_POD", "pod trusted as canonical, the roots of the other pods are compared to its own"},
+	{"discord-webhook-url", "DISCORD_WEBHOOK_URL", "Discord webhook receiving the alerts"},
+	{"discord-webhook-url-info", "DISCORD_WEBHOOK_URL_INFO", "Discord webhook receiving the info messages, e.g. milestones"},
+	{"discord-webhook-url-warning", "DISCORD_WEBHOOK_URL_WARNING", "Discord webhook receiving the warnings"},
+	{"discord-webhook-url-error", "DISCORD_WEBHOOK_URL_ERROR", "Discord webhook receiving the errors"},
+	{"discord-webhook-url-critical", "DISCORD_WEBHOOK_URL_CRITICAL", "Discord webhook receiving the critical alerts"},
+	{"webhook-signing-secret", "WEBHOOK_SIGNING_SECRET", "secret signing the notification bodies with HMAC-SHA256"},
+	{"webhook-signature-header", "WEBHOOK_SIGNATURE_HEADER", "header carrying the hex signature (default X-Signature)"},
+	{"alert-mention", "ALERT_MENTION", "Discord mentions prepended to critical alerts, e.g. <@&role-id>"},
+	{"env-tag", "ENV_TAG", "tag of the monitor instance, e.g. prod, prepended to the alerts and added to the metrics and logs"},
+	{"slack-webhook-url", "SLACK_WEBHOOK_URL", "Slack incoming webhook receiving the alerts"},
+	{"pagerduty-routing-key", "PAGERDUTY_ROUTING_KEY", "PagerDuty Events API v2 routing key"},
+	{"telegram-bot-token", "TELEGRAM_BOT_TOKEN", "Telegram bot token"},
+	{"telegram-chat-id", "TELEGRAM_CHAT_ID", "Telegram chat receiving the alerts"},
+	{"sns-topic-arn", "SNS_TOPIC_ARN", "Amazon SNS topic receiving the alerts"},
+	{"webhook-url", "WEBHOOK_URL", "URL receiving a templated JSON body for every alert"},
+	{"webhook-payload-template", "WEBHOOK_PAYLOAD_TEMPLATE", "text/template rendering the JSON body posted to WEBHOOK_URL"},
+	{"webhook-headers", "WEBHOOK_HEADERS", "comma-separated Name: value headers sent to WEBHOOK_URL"},
+	{"discord-min-severity", "DISCORD_MIN_SEVERITY", "least severe alerts sent to Discord, one of info, warning, error or critical (default info)"},
+	{"slack-min-severity", "SLACK_MIN_SEVERITY", "least severe alerts sent to Slack (default info)"},
+	{"telegram-min-severity", "TELEGRAM_MIN_SEVERITY", "least severe alerts sent to Telegram (default info)"},
+	{"pagerduty-min-severity", "PAGERDUTY_MIN_SEVERITY", "least severe alerts sent to PagerDuty (default critical)"},
+	{"sns-min-severity", "SNS_MIN_SEVERITY", "least severe alerts sent to Amazon SNS (default info)"},
+	{"webhook-min-severity", "WEBHOOK_MIN_SEVERITY", "least severe alerts sent to WEBHOOK_URL (default info)"},
+	{"tm-log-filter", "TM_LOG_FILTER", "GCP filter selecting the commit logs"},
+	{"pd-log-filter", "PD_LOG_FILTER", "GCP filter selecting the error logs"},
+	{"pubsub-subscription", "PUBSUB_SUBSCRIPTION", "Pub/Sub subscription the commit logs are pulled from instead of tailing them"},
+	{"pubsub-pd-subscription", "PUBSUB_PD_SUBSCRIPTION", "Pub/Sub subscription the error logs are pulled from instead of tailing them"},
+	{"ingest-token", "INGEST_TOKEN", "bearer token of the logs pushed to POST /ingest, which are processed instead of tailing GCP"},
+	{"log-payload-field", "LOG_PAYLOAD_FIELD", "field holding the log line in structured payloads (default message)"},
+	{"pod-name-label", "POD_NAME_LABEL", "resource label the pod name is read from when pod_name is missing, e.g. instance_id"},
+	{"alert-templates-dir", "ALERT_TEMPLATES_DIR", "directory of <event>.tmpl files overriding the alert templates"},
+	{"commit-log-pattern", "COMMIT_LOG_PATTERN", "regular expression matching the commit logs, instead of the built-in CometBFT variants"},
+	{"pd-error-height-pattern", "PD_ERROR_HEIGHT_PATTERN", "regular expression extracting the height from a pd error, with a height named group"},
+	{"pd-severity-rules", "PD_SEVERITY_RULES", "JSON file of pattern to severity rules classifying the pd errors"},
+	{"metrics-addr", "METRICS_ADDR", "address the metrics server listens on (default :9090)"},
+	{"ready-staleness-seconds", "READY_STALENESS_SECONDS", "seconds without logs before /readyz fails (default 300)"},
+	{"summary-interval", "SUMMARY_INTERVAL", "how often a summary of every network is posted, e.g. 1h, 0 disables"},
+	{"event-buffer-size", "EVENT_BUFFER_SIZE", "number of recent events served at /events (default 500)"},
+	{"kafka-brokers", "KAFKA_BROKERS", "comma-separated host:port Kafka brokers receiving every event as JSON"},
+	{"kafka-topic", "KAFKA_TOPIC", "Kafka topic the events are published to"},
+	{"kafka-buffer-size", "KAFKA_BUFFER_SIZE", "number of events queued for Kafka before new ones are dropped (default 1000)"},
+	{"log-buffer-size", "LOG_BUFFER_SIZE", "number of log entries buffered while a worker is busy (default 1000)"},
+	{"log-buffer-drop-errors", "LOG_BUFFER_DROP_ERRORS", "drop the oldest pd errors when their buffer is full instead of pausing the stream (true or false)"},
+	{"explorer-url", "EXPLORER_URL", "block explorer URL returning the canonical root of a height, with {height} and optionally {network} placeholders"},
+	{"explorer-root-field", "EXPLORER_ROOT_FIELD", "dotted path to the root in the explorer responses, e.g. result.app_hash (default root)"},
+	{"explorer-rate-per-min", "EXPLORER_RATE_PER_MIN", "maximum number of explorer requests per minute and network (default 30)"},
+	{"cache-window", "CACHE_WINDOW", "number of recent heights whose roots are kept (default 1000)"},
+	{"notify-rate-per-min", "NOTIFY_RATE_PER_MIN", "maximum number of alerts sent per minute (default 20)"},
+	{"notify-burst", "NOTIFY_BURST", "number of alerts sent at once before NOTIFY_RATE_PER_MIN applies (default 5)"},
+	{"notify-workers", "NOTIFY_WORKERS", "maximum number of backends delivered to concurrently (default 4)"},
+	{"notify-breaker-failures", "NOTIFY_BREAKER_FAILURES", "number of deliveries in a row to fail before the alerts to a backend are held (default 5)"},
+	{"notify-breaker-cooldown", "NOTIFY_BREAKER_COOLDOWN", "how long the alerts to a failing backend are held before probing it again (default 1m)"},
+	{"notify-timeout", "NOTIFY_TIMEOUT", "timeout of a request to a notification backend (default 10s)"},
+	{"notify-ca-bundle", "NOTIFY_CA_BUNDLE", "PEM file of CA certificates trusted by the notifiers, e.g. for internal webhooks"},
+	{"notify-insecure-skip-verify", "NOTIFY_INSECURE_SKIP_VERIFY", "skip the TLS certificate checks of the notifiers, for testing only (true or false)"},
+	{"batch-alerts", "BATCH_ALERTS", "combine the alerts raised close together into a single message (true or false)"},
+	{"batch-interval", "BATCH_INTERVAL", "how long alerts are accumulated before a batch is sent (default 2s)"},
+	{"max-error-chars", "MAX_ERROR_CHARS", "length the forwarded pd errors are truncated to (default 1800)"},
+	{"upload-full-errors", "UPLOAD_FULL_ERRORS", "attach the full payload of truncated pd errors to Discord alerts (true or false)"},
+	{"dedup-window", "DEDUP_WINDOW", "window over which identical pd errors are reported once (default 5m)"},
+	{"milestone-interval", "MILESTONE_INTERVAL", "announce every height that is a multiple of it, 0 disables (default 1000)"},
+	{"milestone-heights", "MILESTONE_HEIGHTS", "comma-separated heights announced once"},
+	{"liveness-timeout", "LIVENESS_TIMEOUT", "how long a pod may lag behind before an alert, 0 disables (default 5m)"},
+	{"chain-stall-timeout", "CHAIN_STALL_TIMEOUT", "how long the chain may stall before an alert, 0 disables (default 2m)"},
+	{"process-rate-per-sec", "PROCESS_RATE_PER_SEC", "maximum number of commit logs processed per second, 0 for no limit"},
+	{"stream-startup-jitter", "STREAM_STARTUP_JITTER", "maximum random delay before each log stream first connects (default 2s)"},
+	{"stream-reconnect-jitter", "STREAM_RECONNECT_JITTER", "fraction of the reconnect backoff that is randomized, between 0 and 1 (default 0.2)"},
+	{"startup-timeout", "STARTUP_TIMEOUT", "warn when no commit log is received this long after starting, 0 disables (default 2m)"},
+	{"quorum-size", "QUORUM_SIZE", "pods that must agree on a root before its height is confirmed (default 2)"},
+	{"mismatch-confirmations", "MISMATCH_CONFIRMATIONS", "divergent reports required before a mismatch is alerted (default 1)"},
+	{"mismatch-confirm-window", "MISMATCH_CONFIRM_WINDOW", "window within which divergent reports must be observed (default 5m)"},
+	{"escalate-after", "ESCALATE_AFTER", "alert a mismatch as critical only if it persists this long, e.g. 10m, 0 disables"},
+	{"regression-tolerance", "REGRESSION_TOLERANCE", "how far below its highest height a pod may report before an alert (default 0)"},
+	{"max-lag-blocks", "MAX_LAG_BLOCKS", "alert when a pod falls this many blocks behind the leader, 0 disables"},
+	{"empty-block-streak", "EMPTY_BLOCK_STREAK", "alert after this many consecutive empty blocks, 0 disables"},
+	{"max-txs-alert", "MAX_TXS_ALERT", "flag blocks with more transactions than this, 0 disables"},
+	{"otel-exporter-otlp-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP/HTTP endpoint receiving the traces, e.g. http://localhost:4318, unset disables tracing"},
+	{"log-format", "LOG_FORMAT", "log format, text or json (default text)"},
+	{"log-level", "LOG_LEVEL", "log level, one of debug, info, warn or error (default info)"},
+}
+
+// settings resolves a setting, named after its environment variable, from
+// the command-line flags first, then the environment, then the config file.
+type settings struct {
+	flags map[string]string
+	file  map[string]string
+}
+
+// registerSettingFlags defines a string flag for every entry of settingFlags.
+func registerSettingFlags(fs *flag.FlagSet) map[string]*string {
+	values := make(map[string]*string, len(settingFlags))
+	for _, f := range settingFlags {
+		values[f.flag] = fs.String(f.flag, "", fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+	return values
+}
+
+// newSettings collects the flags explicitly set on `fs` and the settings of
+// the config file at `configPath`, if any.
+func newSettings(fs *flag.FlagSet, values map[string]*string, configPath string) (settings, error) {
+	s := settings{flags: make(map[string]string), file: make(map[string]string)}
+
+	envByFlag := make(map[string]string, len(settingFlags))
+	for _, f := range settingFlags {
+		envByFlag[f.flag] = f.env
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if env, ok := envByFlag[f.Name]; ok {
+			s.flags[env] = *values[f.Name]
+		}
+	})
+
+	if configPath != "" {
+		file, err := loadConfigFile(configPath, envByFlag)
+		if err != nil {
+			return s, err
+		}
+		s.file = file
+	}
+	return s, nil
+}
+
+// loadConfigFile reads a JSON object whose keys are flag names, e.g.
+// {"project-id": "penumbra-sl-testnet", "cache-window": 500}, or the same
+// as a YAML mapping if the file is named *.yaml or *.yml.
+func loadConfigFile(path string, envByFlag map[string]string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %v", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %v", path, err)
+	}
+
+	file := make(map[string]string, len(raw))
+	for key, value := range raw {
+		env, ok := envByFlag[key]
+		if !ok {
+			return nil, fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		switch v := value.(type) {
+		case string:
+			file[env] = v
+		case int, float64, bool:
+			file[env] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("config file %s: %q must be a string, number or boolean", path, key)
+		}
+	}
+	return file, nil
+}
+
+// Lookup returns the value of the setting `name` and whether it was set at
+// all. An environment variable set to an empty string is set, and takes
+// precedence over the config file.
+func (s settings) Lookup(name string) (string, bool) {
+	if v, ok := s.flags[name]; ok {
+		return v, true
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true
+	}
+	v, ok := s.file[name]
+	return v, ok
+}
+
+// Get returns the value of the setting `name`, or an empty string if unset.
+func (s settings) Get(name string) string {
+	v, _ := s.Lookup(name)
+	return v
+}
+
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Settings are read from the flags, then from the environment variable named")
+	fmt.Fprintln(out, "in parentheses, then from the --config file. The GCP credentials are only")
+	fmt.Fprintln(out, "read from the environment: a service account key inline in GCP_CREDENTIALS,")
+	fmt.Fprintln(out, "or in the file at GOOGLE_APPLICATION_CREDENTIALS, or else the Application")
+	fmt.Fprintln(out, "Default Credentials.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+// parseBackfillRange parses the --from and --to flags. `to` defaults to
+// `now`, and requires `from`.
+func parseBackfillRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	if from == "" {
+		if to != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("--to requires --from")
+		}
+		return time.Time{}, time.Time{}, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z: %v", err)
+	}
+	end := now
+	if to != "" {
+		end, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--to must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z: %v", err)
+		}
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from must be before --to")
+	}
+	return start, end, nil
+}
+
+// parseSinceHeight parses the --since-height flag, a height or "auto".
+func parseSinceHeight(s string) (int64, bool, error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	if s == "auto" {
+		return 0, true, nil
+	}
+	height, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || height < 0 {
+		return 0, false, fmt.Errorf("--since-height must be a height or auto, got %q", s)
+	}
+	return height, false, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erwanor/check-apphash/monitor"
+)
+
+func TestSettingsLookup(t *testing.T) {
+	const name = "TM_LOG_FILTER"
+	tests := []struct {
+		name string
+		flag string
+		// env is unset if nil.
+		env    *string
+		file   string
+		want   string
+		wantOk bool
+	}{
+		{name: "unset", want: "", wantOk: false},
+		{name: "file", file: "from-file", want: "from-file", wantOk: true},
+		{name: "env over file", env: ptr("from-env"), file: "from-file", want: "from-env", wantOk: true},
+		{name: "blank env over file", env: ptr(""), file: "from-file", want: "", wantOk: true},
+		{name: "blank env", env: ptr(""), want: "", wantOk: true},
+		{name: "flag over env", flag: "from-flag", env: ptr("from-env"), want: "from-flag", wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != nil {
+				t.Setenv(name, *tt.env)
+			} else {
+				unsetenv(t, name)
+			}
+			s := settings{flags: map[string]string{}, file: map[string]string{}}
+			if tt.flag != "" {
+				s.flags[name] = tt.flag
+			}
+			if tt.file != "" {
+				s.file[name] = tt.file
+			}
+
+			got, ok := s.Lookup(name)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Lookup(%s) = %q, %v, want %q, %v", name, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestBlankFilterFromEnvironment(t *testing.T) {
+	for _, name := range []string{"TM_LOG_FILTER", "PD_LOG_FILTER"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("DISCORD_WEBHOOK_URL", "https://discord.example/webhook")
+			t.Setenv(name, " ")
+			s := settings{flags: map[string]string{}, file: map[string]string{}}
+
+			_, err := monitor.LoadConfig(s, "replay.log")
+			if err == nil || !strings.Contains(err.Error(), name+" is set but blank") {
+				t.Errorf("LoadConfig() = %v, want %s rejected as blank", err, name)
+			}
+		})
+	}
+}
+
+func TestNewSettingsConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"project-id": "from-file", "cache-window": 500, "upload-full-errors": true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	values := registerSettingFlags(fs)
+	if err := fs.Parse([]string{"--cache-window", "250"}); err != nil {
+		t.Fatal(err)
+	}
+	unsetenv(t, "GCP_PROJECT_ID")
+	unsetenv(t, "CACHE_WINDOW")
+	unsetenv(t, "UPLOAD_FULL_ERRORS")
+
+	s, err := newSettings(fs, values, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"GCP_PROJECT_ID": "from-file", "CACHE_WINDOW": "250", "UPLOAD_FULL_ERRORS": "true"} {
+		if got := s.Get(name); got != want {
+			t.Errorf("Get(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// unsetenv unsets the environment variable `name` for the duration of the
+// test.
+func unsetenv(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv(name, "")
+	os.Unsetenv(name)
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	envByFlag := map[string]string{"project-id": "GCP_PROJECT_ID", "cache-window": "CACHE_WINDOW", "dry-run-alerts": "DRY_RUN"}
+	want := map[string]string{"GCP_PROJECT_ID": "penumbra-sl-testnet", "CACHE_WINDOW": "500", "DRY_RUN": "true"}
+	tests := []struct {
+		file    string
+		content string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{"config.json", `{"project-id": "penumbra-sl-testnet", "cache-window": 500, "dry-run-alerts": true}`, ""},
+		{"config.yaml", "project-id: penumbra-sl-testnet\ncache-window: 500\ndry-run-alerts: true\n", ""},
+		{"config.YML", "project-id: penumbra-sl-testnet\ncache-window: 500\ndry-run-alerts: true\n", ""},
+		{"config.json", "project-id: penumbra-sl-testnet\n", "parsing config file"},
+		{"config.yaml", "project-id: [unterminated\n", "parsing config file"},
+		{"config.yaml", "unknown-setting: 1\n", `unknown setting "unknown-setting"`},
+		{"config.yaml", "project-id:\n  nested: value\n", `"project-id" must be a string, number or boolean`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadConfigFile(path, envByFlag)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("loadConfigFile() = %v, %v, want error %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("loadConfigFile() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogger installs the default slog logger according to LOG_FORMAT
+// (text or json) and LOG_LEVEL (debug, info, warn or error). The records
+// carry the ENV_TAG, if set, as `env`.
+func setupLogger(s settings) error {
+	var level slog.Level
+	switch strings.ToLower(s.Get("LOG_LEVEL")) {
+	case "debug":
+		level = slog.LevelDebug
+	case "", "info":
+		level = slog.LevelInfo
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return fmt.Errorf("LOG_LEVEL must be one of debug, info, warn or error")
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(s.Get("LOG_FORMAT")) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT must be either text or json")
+	}
+
+	logger := slog.New(handler)
+	if tag := strings.TrimSpace(s.Get("ENV_TAG")); tag != "" {
+		logger = logger.With("env", tag)
+	}
+	slog.SetDefault(logger)
+	return nil
+}
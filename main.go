@@ -1,294 +1,158 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"log/slog"
 	"os"
-	"regexp"
-	"strconv"
-	"sync"
+	"os/signal"
+	"syscall"
+	"time"
 
-	logging "cloud.google.com/go/logging/apiv2"
-	"cloud.google.com/go/logging/apiv2/loggingpb"
-	"google.golang.org/api/option"
+	"github.com/erwanor/check-apphash/monitor"
 )
 
-type LogEntry struct {
-	metadata map[string]string
-	payload  string
-}
-
-type LogData struct {
-	Height  int
-	Hash    string
-	Root    string
-	NumTxs  int
-	PodName string
-}
-
-type RootHashRecord struct {
-	PodName string
-	Root    string
-}
-
-func parseCommitLog(podName, logEntry string) (*LogData, error) {
-	re := regexp.MustCompile(`finalizing commit of block\s+module=consensus height=(\d+) hash=([0-9a-fA-F]+) root=([0-9a-fA-F]+) num_txs=(\d+)`)
-	match := re.FindStringSubmatch(logEntry)
-
-	if len(match) == 0 {
-		return nil, fmt.Errorf("no match")
+func main() {
+	exitOnMismatch := flag.Bool("exit-on-mismatch", false, "exit the process when a root mismatch is detected")
+	dryRun := flag.Bool("dry-run", false, "log alerts instead of sending them (same as DRY_RUN=true)")
+	enableTM := flag.Bool("enable-tm", true, "run the tm worker, which checks the reported roots")
+	enablePD := flag.Bool("enable-pd", true, "run the pd worker, which forwards the pd errors")
+	replayFile := flag.String("replay-file", "", "replay commit logs from a file (\"-\" for stdin) instead of tailing GCP")
+	from := flag.String("from", "", "audit the past logs logged from this RFC 3339 time instead of tailing GCP")
+	to := flag.String("to", "", "end of the past logs audited with --from, defaults to now")
+	sinceHeight := flag.String("since-height", "", "skip the commit logs below this height, or below the highest of the first ones with \"auto\"")
+	check := flag.Bool("check", false, "check the configuration, the access to the logs and the notifier, then exit")
+	filterTest := flag.String("filter-test", "", "print the GCP log entries matching this filter as they are logged, without parsing or alerting, then exit")
+	filterTestCount := flag.Int("filter-test-count", 0, "exit --filter-test after printing this many entries, 0 waits for Ctrl-C")
+	filterTestJSON := flag.Bool("filter-test-json", false, "print the --filter-test entries as JSON lines, in the format of the replay files")
+	configFile := flag.String("config", "", "JSON, or YAML if named *.yaml or *.yml, file of settings keyed by flag name, used when neither the flag nor the environment variable is set")
+	values := registerSettingFlags(flag.CommandLine)
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if !*enableTM && !*enablePD {
+		exit(errors.New("--enable-tm and --enable-pd are both false, there is nothing to monitor"))
 	}
-
-	height, err := strconv.Atoi(match[1])
+	if *replayFile != "" && !*enableTM {
+		exit(errors.New("--replay-file replays commit logs, it requires the tm worker"))
+	}
+	backfillFrom, backfillTo, err := parseBackfillRange(*from, *to, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("parsing height: %v", err)
+		exit(err)
 	}
-
-	hash := match[2]
-	root := match[3]
-
-	numTxs, err := strconv.Atoi(match[4])
+	if !backfillFrom.IsZero() && *replayFile != "" {
+		exit(errors.New("--from and --replay-file cannot be used together"))
+	}
+	if *filterTest != "" && (*replayFile != "" || !backfillFrom.IsZero()) {
+		exit(errors.New("--filter-test tails GCP, it cannot be used with --replay-file or --from"))
+	}
+	if *filterTestCount < 0 {
+		exit(errors.New("--filter-test-count must be non-negative"))
+	}
+	sinceHeightValue, sinceHeightAuto, err := parseSinceHeight(*sinceHeight)
 	if err != nil {
-		return nil, fmt.Errorf("parsing num_txs: %v", err)
+		exit(err)
 	}
 
-	return &LogData{
-		Height:  height,
-		Hash:    hash,
-		Root:    root,
-		NumTxs:  numTxs,
-		PodName: podName,
-	}, nil
-}
-
-func streamLogsWithFilter(ctx context.Context, projectID string, filter string, out chan<- LogEntry) error {
-	client, err := logging.NewClient(ctx, option.WithCredentialsJSON([]byte(os.Getenv("GCP_CREDENTIALS"))))
+	s, err := newSettings(flag.CommandLine, values, *configFile)
 	if err != nil {
-		return fmt.Errorf("NewClient error: %v", err)
+		exit(err)
 	}
 
-	log.Print("connected to GCP")
+	if err := setupLogger(s); err != nil {
+		exit(err)
+	}
 
-	stream, err := client.TailLogEntries(ctx)
+	cfg, err := monitor.LoadConfig(s, *replayFile)
 	if err != nil {
-		client.Close()
-		return fmt.Errorf("TailLogEntries error: %v", err)
+		exit(err)
 	}
-
-	log.Print("established stream")
-
-	req := &loggingpb.TailLogEntriesRequest{
-		ResourceNames: []string{
-			"projects/" + projectID,
-		},
-		Filter: filter,
+	if !backfillFrom.IsZero() && cfg.IngestToken != "" {
+		exit(errors.New("--from audits the GCP logs, it cannot be used with INGEST_TOKEN"))
 	}
-
-	if err := stream.Send(req); err != nil {
-		stream.CloseSend()
-		client.Close()
-		log.Fatal("stream.Send error: %v", err)
+	if *filterTest != "" && cfg.IngestToken != "" {
+		exit(errors.New("--filter-test tails GCP, it cannot be used with INGEST_TOKEN"))
 	}
-
-	for {
-		resp, err := stream.Recv()
-		if err == io.EOF {
-			log.Print("stream EOF")
-			break
-		}
-		if err != nil {
-			log.Print("stream.Recv error:", err)
-			break
-		}
-
-		for _, entry := range resp.Entries {
-			metadata := entry.GetResource().GetLabels()
-			payload := entry.GetTextPayload()
-
-			out <- LogEntry{
-				metadata: metadata,
-				payload:  payload,
-			}
-		}
+	// The flags that only exist on the command line are applied again to
+	// the configuration reloaded on SIGHUP.
+	applyFlags := func(cfg *monitor.Config) {
+		cfg.ExitOnMismatch = *exitOnMismatch
+		cfg.EnableTM = *enableTM
+		cfg.EnablePD = *enablePD
+		cfg.DryRun = cfg.DryRun || *dryRun
+		cfg.BackfillFrom, cfg.BackfillTo = backfillFrom, backfillTo
+		cfg.SinceHeight, cfg.SinceHeightAuto = sinceHeightValue, sinceHeightAuto
 	}
+	applyFlags(cfg)
 
-	close(out)
-	stream.CloseSend()
-	client.Close()
-	log.Print("terminating routine")
-	return nil
-}
-
-func postToDiscord(msg string) {
-	webhookUrl := os.Getenv("DISCORD_WEBHOOK_URL")
-
-	payload := map[string]interface{}{
-		"content": msg,
+	if *check {
+		exit(monitor.Check(cfg, os.Stdout))
 	}
 
-	payloadBytes, _ := json.Marshal(payload)
-
-	http.Post(webhookUrl, "application/json", bytes.NewBuffer(payloadBytes))
-}
-
-func main() {
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	if projectID == "" {
-		fmt.Println("GCP PROJECT_ID is not set or empty")
-		os.Exit(1)
-	} else if os.Getenv("DISCORD_WEBHOOK_URL") == "" {
-		fmt.Println("DISCORD_WEBHOOK_URL is unset or empty")
-		os.Exit(1)
-	} else if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
-		fmt.Println("GOOGLE_APPLICATION_CREDENTIALS is unset or empty")
-		os.Exit(1)
-	} else if os.Getenv("GCP_CREDENTIALS") == "" {
-		fmt.Println("GCP_CREDENTIALS is unset or empty")
-		os.Exit(1)
-	} else if os.Getenv("PENUMBRA_NETWORK") == "" {
-		fmt.Println("PENUMBRA_NETWORK is unset or empty")
-		os.Exit(1)
-	} else {
-		log.Print("log relayer starting up!")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *filterTest != "" {
+		err = monitor.FilterTest(ctx, cfg, *filterTest, *filterTestCount, *filterTestJSON, os.Stdout)
+		stop()
+		exit(err)
 	}
+	m := monitor.New(*cfg, nil)
+	// SIGUSR1 toggles a maintenance window, e.g. around a planned upgrade.
+	onSignal(syscall.SIGUSR1, m.ToggleMaintenance)
+	// SIGHUP reloads the --config file, and the files it points to, e.g.
+	// NETWORKS_CONFIG. An invalid configuration is not applied.
+	onSignal(syscall.SIGHUP, func() {
+		reloadConfig(flag.CommandLine, values, *configFile, *replayFile, applyFlags, m.Reload)
+	})
+	err = m.Run(ctx)
+	stop()
+	exit(err)
+}
 
-	log.Print("starting log relayer for network: ", os.Getenv("PENUMBRA_NETWORK"))
-
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Print("started tm log relay")
-		// Map the block height to a list of `RootHashRecord` that store the pod name
-		// and reported root hash.
-		rootCache := make(map[int][]RootHashRecord)
-		ctx := context.Background()
-		commitLogs := make(chan LogEntry)
-
-		filter := fmt.Sprintf(`resource.labels.container_name="tm" AND resource.labels.cluster_name="testnet" AND resource.labels.pod_name:"penumbra-%s"`, os.Getenv("PENUMBRA_NETWORK"))
-		log.Print("tm filter: ", filter)
-
-		go streamLogsWithFilter(ctx, projectID, filter, commitLogs)
-
-		for logEntry := range commitLogs {
-			podName, exists := logEntry.metadata["pod_name"]
-			if !exists {
-				continue
-			}
-
-			commitLog, err := parseCommitLog(podName, logEntry.payload)
-			if err != nil {
-				continue
-			}
-
-			record := RootHashRecord{
-				PodName: commitLog.PodName,
-				Root:    commitLog.Root,
-			}
-
-			log_msg := fmt.Sprintf("%s, at height %d, has apphash %s", commitLog.PodName, commitLog.Height, commitLog.Root)
-			log.Print(log_msg)
-
-			if commitLog.Height%1000 == 0 {
-				discord_msg := fmt.Sprintf("**%s**, at height **%d**, has apphash _%s_", commitLog.PodName, commitLog.Height, commitLog.Root)
-				postToDiscord(discord_msg)
-			}
-
-			if prev, exists := rootCache[commitLog.Height]; exists {
-				// Detect a chain restart
-				// Note: this isn't actually correct because logs can be delivered
-				// out-of-order or duplicated. We can handle the duplication by keeping
-				// a sliding cache of records that we have seen.
-				// To detect a chain restart, we should instead lean onto the fact that
-				// pod ids are randomly generated.
-				// if commitLog.Height < confirmedHeight {
-				// msg := fmt.Sprintf("detected chain restart, current height=%d, previous tip: height=%d, %s:%s and %s:%s", commitLog.Height, confirmedHeight, prev[0].PodName, prev[0].Root, prev[1].PodName, prev[1].Root)
-				// postToDiscord(msg)
-				// log.Print(msg)
-				// rootCache = map[int][]RootHashRecord{
-				// 	commitLog.Height: {record},
-				// }
-				// continue
-				// } else if ...
-				if !consistentRecords(record, prev) {
-					record_str := knownRootHashesString(prev)
-					err_str := fmt.Sprintf("ROOT MISMATCH DETECTED AT BLOCK %d", commitLog.Height)
-					err_str = fmt.Sprintf("%s\n%s", err_str, record_str)
-					disc_msg := fmt.Sprintf("@erwanor : %s", err_str)
-					postToDiscord(disc_msg)
-					log.Fatal(err_str)
-				} else {
-					rootCache[commitLog.Height] = append(rootCache[commitLog.Height], record)
-				}
-			} else {
-				rootCache[commitLog.Height] = []RootHashRecord{record}
-			}
-
-		}
-		log.Print("tm worker exiting")
-	}()
-
-	wg.Add(1)
+// onSignal calls `f` on every `sig` received, one call at a time, until the
+// returned function is called.
+func onSignal(sig os.Signal, f func()) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sig)
 	go func() {
-		defer wg.Done()
-		log.Print("started pd worker")
-		ctx := context.Background()
-		errorLogs := make(chan LogEntry)
-
-		filter := fmt.Sprintf(`resource.labels.container_name="pd" AND resource.labels.cluster_name="testnet" AND resource.labels.pod_name:"penumbra-%s" AND severity>=ERROR`, os.Getenv("PENUMBRA_NETWORK"))
-		log.Print("pd filter: ", filter)
-		go streamLogsWithFilter(ctx, projectID, filter, errorLogs)
-
-		for logEntry := range errorLogs {
-			podName, exists := logEntry.metadata["pod_name"]
-			if !exists {
-				log.Print("pod name not found!")
-				continue
-			}
-
-			msg := fmt.Sprintf("%s: %s", podName, logEntry.payload)
-			postToDiscord(msg)
+		for range c {
+			f()
 		}
-		log.Print("pd worker exiting")
-	}()
-
-	// Digital ocean deploy fails unless it can ping a health endpoint
-	go func() {
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "OK")
-		})
-		log.Fatal(http.ListenAndServe(":8080", nil))
 	}()
-
-	wg.Wait()
-	log.Print("exiting")
+	return func() {
+		signal.Stop(c)
+		close(c)
+	}
 }
 
-func consistentRecords(current RootHashRecord, records []RootHashRecord) bool {
-	if len(records) == 0 {
-		return true
+// reloadConfig reads the configuration again, from the settings flags of
+// `fs` and the `configPath` file, applies `applyFlags` to it and passes it
+// to `reload`. An invalid configuration is not applied.
+func reloadConfig(fs *flag.FlagSet, values map[string]*string, configPath, replayFile string, applyFlags func(*monitor.Config), reload func(monitor.Config)) {
+	slog.Info("reloading the configuration", "config", configPath)
+	s, err := newSettings(fs, values, configPath)
+	if err != nil {
+		slog.Error("not reloading the configuration", "err", err)
+		return
 	}
-
-	for record := range records {
-		if current.Root != records[record].Root {
-			return false
-		}
+	cfg, err := monitor.LoadConfig(s, replayFile)
+	if err != nil {
+		slog.Error("not reloading the configuration", "err", err)
+		return
 	}
-
-	return true
+	applyFlags(cfg)
+	reload(*cfg)
 }
 
-func knownRootHashesString(records []RootHashRecord) string {
-	var s string
-	for record := range records {
-		s += fmt.Sprintf("%s: %s\n", records[record].PodName, records[record].Root)
+// exit ends the process with the exit code of `err`, see monitor.ExitCode,
+// logging why.
+func exit(err error) {
+	code := monitor.ExitCode(err)
+	if err == nil {
+		slog.Info("exiting", "exit_code", code)
+	} else {
+		slog.Error("exiting", "exit_code", code, "err", err)
 	}
-	return s
+	os.Exit(code)
 }
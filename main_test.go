@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erwanor/check-apphash/monitor"
+)
+
+func TestReloadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		// wantInterval is the MilestoneInterval reloaded, 0 if not reloaded.
+		wantInterval int
+	}{
+		{
+			name:         "valid",
+			config:       `{"discord-webhook-url": "https://discord.example/webhook", "milestone-interval": 50}`,
+			wantInterval: 50,
+		},
+		{
+			name:   "invalid",
+			config: `{"discord-webhook-url": "discord.example/webhook", "milestone-interval": 50}`,
+		},
+		{
+			name:   "unreadable",
+			config: `{"discord-webhook-url": `,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "DISCORD_WEBHOOK_URL")
+			unsetenv(t, "MILESTONE_INTERVAL")
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			values := registerSettingFlags(fs)
+
+			var reloaded []monitor.Config
+			applyFlags := func(cfg *monitor.Config) { cfg.ExitOnMismatch = true }
+			reloadConfig(fs, values, path, "replay.log", applyFlags, func(cfg monitor.Config) { reloaded = append(reloaded, cfg) })
+
+			if tt.wantInterval == 0 {
+				if len(reloaded) != 0 {
+					t.Errorf("reloaded %d configurations, want none", len(reloaded))
+				}
+				return
+			}
+			if len(reloaded) != 1 {
+				t.Fatalf("reloaded %d configurations, want 1", len(reloaded))
+			}
+			if got := reloaded[0].MilestoneInterval; got != tt.wantInterval {
+				t.Errorf("MilestoneInterval = %d once reloaded, want %d", got, tt.wantInterval)
+			}
+			if !reloaded[0].ExitOnMismatch {
+				t.Error("ExitOnMismatch not applied from the flags")
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord is a line of the audit log.
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	Network     string    `json:"network,omitempty"`
+	Severity    string    `json:"severity"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	Event       string    `json:"event,omitempty"`
+	IncidentKey string    `json:"incident_key,omitempty"`
+	Resolved    bool      `json:"resolved,omitempty"`
+	PodName     string    `json:"pod_name,omitempty"`
+	Height      int64     `json:"height,omitempty"`
+}
+
+// auditLogger appends every alert the monitor decides to send, as
+// newline-delimited JSON, to a local file. Once the file would grow past
+// maxSize, it is rotated: renamed with the time of the rotation as suffix,
+// then compressed with gzip in the background. A nil *auditLogger records
+// nothing.
+type auditLogger struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// compressing tracks the rotated segments being compressed.
+	compressing sync.WaitGroup
+}
+
+// openAuditLogger appends to the file at `path`, rotating it at `maxSize`
+// bytes.
+func openAuditLogger(path string, maxSize int64) (*auditLogger, error) {
+	l := &auditLogger{path: path, maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *auditLogger) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening audit log: %v", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Record appends `msg` to the log, rotating it first if needed.
+func (l *auditLogger) Record(msg Message, at time.Time) error {
+	if l == nil {
+		return nil
+	}
+
+	record := auditRecord{
+		Time:        at.UTC(),
+		Network:     msg.Network,
+		Severity:    msg.Severity.String(),
+		Title:       msg.Title,
+		Body:        msg.Body,
+		Event:       msg.Event,
+		IncidentKey: msg.IncidentKey,
+		Resolved:    msg.Resolved,
+	}
+	if msg.Commit != nil {
+		record.PodName, record.Height = msg.Commit.PodName, msg.Commit.Height
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshalling audit record: %v", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(at); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing audit log: %v", err)
+	}
+	return nil
+}
+
+// rotate closes the current file, renames it after `at` and opens a new
+// one. The renamed segment is compressed in the background.
+func (l *auditLogger) rotate(at time.Time) error {
+	if err := l.file.Sync(); err != nil {
+		slog.Warn("failed to sync the audit log before rotating it", "path", l.path, "err", err)
+	}
+	if err := l.file.Close(); err != nil {
+		slog.Warn("failed to close the audit log before rotating it", "path", l.path, "err", err)
+	}
+	l.file = nil
+
+	segment := fmt.Sprintf("%s.%s", l.path, at.UTC().Format("20060102T150405.000000000Z"))
+	if err := os.Rename(l.path, segment); err != nil {
+		// Keep appending to the current file rather than losing records.
+		slog.Error("failed to rotate the audit log", "path", l.path, "err", err)
+	} else {
+		l.compressing.Add(1)
+		go func() {
+			defer l.compressing.Done()
+			if err := compressFile(segment); err != nil {
+				slog.Error("failed to compress the audit log segment", "path", segment, "err", err)
+			}
+		}()
+	}
+	return l.open()
+}
+
+// compressFile replaces the file at `path` with its gzip compression at
+// `path`.gz.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close syncs the current file to disk and closes it, then waits for the
+// rotated segments to be compressed.
+func (l *auditLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	var err error
+	if l.file != nil {
+		if err = l.file.Sync(); err == nil {
+			err = l.file.Close()
+		} else {
+			l.file.Close()
+		}
+		l.file = nil
+	}
+	l.mu.Unlock()
+
+	l.compressing.Wait()
+	if err != nil {
+		return fmt.Errorf("closing audit log: %v", err)
+	}
+	return nil
+}
+
+// auditingNotifier records every message in an audit log before handing it
+// to the next notifier.
+type auditingNotifier struct {
+	log      *auditLogger
+	notifier Notifier
+}
+
+func (a auditingNotifier) Notify(ctx context.Context, msg Message) error {
+	if err := a.log.Record(msg, time.Now()); err != nil {
+		slog.Error("failed to record alert in the audit log", "title", msg.Title, "err", err)
+	}
+	return a.notifier.Notify(ctx, msg)
+}
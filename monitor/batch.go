@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discordMaxMessageLen is the maximum length of a Discord message, in
+// characters.
+const discordMaxMessageLen = 2000
+
+// batchingNotifier accumulates alerts for `interval` after the first one and
+// delivers them as a single message, split to fit in `maxLen` characters.
+// Critical alerts and incident resolutions bypass the batch, so that paging
+// backends see them individually and without delay, as do the alerts with an
+// attachment, which cannot be combined.
+type batchingNotifier struct {
+	notifier Notifier
+	interval time.Duration
+	maxLen   int
+
+	mu      sync.Mutex
+	pending []Message
+	timer   *time.Timer
+}
+
+func newBatchingNotifier(notifier Notifier, interval time.Duration, maxLen int) *batchingNotifier {
+	return &batchingNotifier{
+		notifier: notifier,
+		interval: interval,
+		maxLen:   maxLen,
+	}
+}
+
+func (b *batchingNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Severity == SeverityCritical || (msg.Resolved && msg.IncidentKey != "") || msg.Attachment != nil {
+		return b.notifier.Notify(ctx, msg)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, msg)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() {
+			if err := b.Flush(context.Background()); err != nil {
+				slog.Error("failed to deliver batched alerts", "err", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush delivers the pending alerts right away.
+func (b *batchingNotifier) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	var errs []string
+	for _, msg := range batch(pending, b.maxLen) {
+		if err := b.notifier.Notify(ctx, msg); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("batch: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// batch combines `msgs` into as few messages as possible, each with a body of
+// at most `maxLen` characters and the highest severity among them.
+func batch(msgs []Message, maxLen int) []Message {
+	if len(msgs) <= 1 {
+		return msgs
+	}
+
+	combined := Message{Severity: SeverityInfo}
+	parts := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Severity > combined.Severity {
+			combined.Severity = msg.Severity
+		}
+		part := msg.Body
+		if msg.Title != "" {
+			part = fmt.Sprintf("**%s**\n%s", msg.Title, msg.Body)
+		}
+		parts = append(parts, part)
+	}
+
+	// Leave room for the title the backends prepend to the body.
+	chunks := splitMessage(strings.Join(parts, "\n\n"), maxLen-100)
+	batched := make([]Message, 0, len(chunks))
+	for i, chunk := range chunks {
+		msg := combined
+		msg.Title = fmt.Sprintf("%d alerts", len(msgs))
+		if len(chunks) > 1 {
+			msg.Title = fmt.Sprintf("%d alerts (%d/%d)", len(msgs), i+1, len(chunks))
+		}
+		msg.Body = chunk
+		batched = append(batched, msg)
+	}
+	return batched
+}
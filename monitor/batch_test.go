@@ -0,0 +1,101 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchingNotifierBypass(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		// want is whether the message is delivered without waiting.
+		want bool
+	}{
+		{"info", Message{Severity: SeverityInfo, Title: "Milestone"}, false},
+		{"error", Message{Severity: SeverityError, Title: "Height regression"}, false},
+		{"critical", Message{Severity: SeverityCritical, Title: "Root mismatch"}, true},
+		{"resolution", Message{Severity: SeverityInfo, Title: "Monitoring restored", IncidentKey: "key", Resolved: true}, true},
+		{"attachment", Message{Severity: SeverityInfo, Attachment: &Attachment{Name: "roots.txt"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &recordingNotifier{}
+			b := newBatchingNotifier(notifier, time.Hour, discordMaxMessageLen)
+			if err := b.Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(notifier.titles()) == 1; got != tt.want {
+				t.Errorf("delivered right away: %v, want %v", got, tt.want)
+			}
+
+			if err := b.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(notifier.titles()); got != 1 {
+				t.Errorf("delivered %d messages once flushed, want 1", got)
+			}
+		})
+	}
+}
+
+func TestBatchingNotifierFlushesAfterInterval(t *testing.T) {
+	notifier := &recordingNotifier{}
+	b := newBatchingNotifier(notifier, 50*time.Millisecond, discordMaxMessageLen)
+	for _, title := range []string{"Milestone", "Pod lagging", "Height regression"} {
+		if err := b.Notify(context.Background(), Message{Severity: SeverityWarning, Title: title, Body: "body"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := notifier.titles(); len(got) != 0 {
+		t.Fatalf("delivered %q before the interval, want nothing", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(notifier.titles()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := notifier.titles(), []string{"3 alerts"}; !slices.Equal(got, want) {
+		t.Fatalf("delivered %q after the interval, want %q", got, want)
+	}
+	msg := notifier.messages[0]
+	if msg.Severity != SeverityWarning {
+		t.Errorf("batch severity = %s, want the highest one, warning", msg.Severity)
+	}
+	for _, title := range []string{"**Milestone**", "**Pod lagging**", "**Height regression**"} {
+		if !strings.Contains(msg.Body, title) {
+			t.Errorf("batch %q does not include %s", msg.Body, title)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	long := Message{Severity: SeverityInfo, Body: strings.Repeat("x", 900)}
+	tests := []struct {
+		name string
+		msgs []Message
+		want []string
+	}{
+		{"single", []Message{{Title: "Milestone"}}, []string{"Milestone"}},
+		{"fitting", []Message{long, long}, []string{"2 alerts"}},
+		{"split", []Message{long, long, long}, []string{"3 alerts (1/2)", "3 alerts (2/2)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batched := batch(tt.msgs, discordMaxMessageLen)
+			var titles []string
+			for _, msg := range batched {
+				titles = append(titles, msg.Title)
+				if n := len([]rune(msg.Body)); n > discordMaxMessageLen {
+					t.Errorf("batched body of %d characters, want at most %d", n, discordMaxMessageLen)
+				}
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("batched as %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
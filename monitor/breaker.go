@@ -0,0 +1,175 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// breakerHeldSize bounds the number of messages held for a backend while its
+// circuit breaker is open.
+const breakerHeldSize = 20
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops delivering to a backend once `threshold` deliveries
+// in a row failed, so that the retries of each message do not hammer a
+// broken endpoint. While the breaker is open, the messages are held, the
+// oldest being dropped beyond breakerHeldSize. After `cooldown`, the breaker
+// half-opens and the oldest held message, or the next one, probes the
+// backend: if it is delivered, the breaker closes and the held messages are
+// delivered, otherwise it opens again.
+type circuitBreaker struct {
+	backend   string
+	notifier  Notifier
+	threshold int
+	cooldown  time.Duration
+	metrics   *metrics
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	// probing is set while a probe is being delivered.
+	probing bool
+	held    []heldMessage
+	timer   *time.Timer
+}
+
+// heldMessage is a message held while the breaker is open.
+type heldMessage struct {
+	ctx context.Context
+	msg Message
+}
+
+func newCircuitBreaker(backend string, notifier Notifier, threshold int, cooldown time.Duration, metrics *metrics) *circuitBreaker {
+	metrics.notifyBreakerState.WithLabelValues(backend).Set(float64(breakerClosed))
+	return &circuitBreaker{backend: backend, notifier: notifier, threshold: threshold, cooldown: cooldown, metrics: metrics}
+}
+
+// Notify delivers `msg` while the breaker is closed, and holds it while it is
+// open. A held message is not an error.
+func (b *circuitBreaker) Notify(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	switch {
+	case b.state == breakerClosed:
+		b.mu.Unlock()
+		return b.deliver(ctx, msg)
+	case b.state == breakerHalfOpen && !b.probing:
+		b.probing = true
+		b.mu.Unlock()
+		b.probe(ctx, msg)
+		return nil
+	default:
+		b.holdLocked(ctx, msg)
+		b.mu.Unlock()
+		return nil
+	}
+}
+
+// State returns the state of the breaker, "closed", "open" or "half-open",
+// and the number of messages held.
+func (b *circuitBreaker) State() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String(), len(b.held)
+}
+
+// deliver delivers `msg` through the closed breaker, opening it on the
+// threshold-th failure in a row.
+func (b *circuitBreaker) deliver(ctx context.Context, msg Message) error {
+	err := b.notifier.Notify(ctx, msg)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return nil
+	}
+	b.failures++
+	if b.failures >= b.threshold && b.state == breakerClosed {
+		b.openLocked(err)
+	}
+	return err
+}
+
+// probe delivers `msg` through the half-open breaker: the breaker closes if
+// it is delivered, and opens again, holding `msg`, otherwise.
+func (b *circuitBreaker) probe(ctx context.Context, msg Message) {
+	err := b.notifier.Notify(ctx, msg)
+
+	b.mu.Lock()
+	b.probing = false
+	if err != nil {
+		b.held = append([]heldMessage{{ctx, msg}}, b.held...)
+		b.openLocked(err)
+		b.mu.Unlock()
+		return
+	}
+	held := b.held
+	b.state, b.failures, b.held = breakerClosed, 0, nil
+	b.metrics.notifyBreakerState.WithLabelValues(b.backend).Set(float64(breakerClosed))
+	b.mu.Unlock()
+
+	slog.Info("notification backend recovered, delivering the held alerts", "backend", b.backend, "held", len(held))
+	for _, h := range held {
+		if err := b.Notify(h.ctx, h.msg); err != nil {
+			slog.Error("failed to deliver alert", "backend", b.backend, "title", h.msg.Title, "severity", h.msg.Severity.String(), "err", err)
+		}
+	}
+}
+
+// halfOpen half-opens the breaker once the cooldown elapsed, probing the
+// backend with the oldest held message, if any.
+func (b *circuitBreaker) halfOpen() {
+	b.mu.Lock()
+	b.state = breakerHalfOpen
+	b.metrics.notifyBreakerState.WithLabelValues(b.backend).Set(float64(breakerHalfOpen))
+	if len(b.held) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	h := b.held[0]
+	b.held = b.held[1:]
+	b.probing = true
+	b.mu.Unlock()
+
+	b.probe(h.ctx, h.msg)
+}
+
+func (b *circuitBreaker) openLocked(err error) {
+	b.state, b.failures = breakerOpen, 0
+	b.metrics.notifyBreakerState.WithLabelValues(b.backend).Set(float64(breakerOpen))
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.AfterFunc(b.cooldown, b.halfOpen)
+	slog.Warn("notification backend failing, holding its alerts", "backend", b.backend, "cooldown", b.cooldown, "held", len(b.held), "err", err)
+}
+
+func (b *circuitBreaker) holdLocked(ctx context.Context, msg Message) {
+	if len(b.held) >= breakerHeldSize {
+		dropped := b.held[0]
+		b.held = b.held[1:]
+		b.metrics.notifyBreakerDropped.WithLabelValues(b.backend).Inc()
+		slog.Warn("notification backend failing, dropping the oldest held alert", "backend", b.backend, "title", dropped.msg.Title)
+	}
+	b.held = append(b.held, heldMessage{ctx, msg})
+}
@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// switchNotifier records the titles of the messages it delivers, and fails
+// them while `failing` is set.
+type switchNotifier struct {
+	mu        sync.Mutex
+	failing   bool
+	delivered []string
+}
+
+func (n *switchNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failing {
+		return errors.New("backend down")
+	}
+	n.delivered = append(n.delivered, msg.Title)
+	return nil
+}
+
+func (n *switchNotifier) setFailing(failing bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failing = failing
+}
+
+func (n *switchNotifier) titles() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.delivered)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	backend := &switchNotifier{failing: true}
+	// The cooldown never elapses, the breaker is half-opened by the test.
+	b := newCircuitBreaker("discord", backend, 2, time.Hour, newMetrics())
+	notify := func(title string) error {
+		return b.Notify(context.Background(), Message{Title: title})
+	}
+	wantState := func(step, state string, held int) {
+		t.Helper()
+		if gotState, gotHeld := b.State(); gotState != state || gotHeld != held {
+			t.Errorf("%s: State() = %s, %d, want %s, %d", step, gotState, gotHeld, state, held)
+		}
+	}
+
+	if err := notify("first"); err == nil {
+		t.Error("Notify() = nil with the backend failing, want an error")
+	}
+	wantState("below the threshold", "closed", 0)
+	if err := notify("second"); err == nil {
+		t.Error("Notify() = nil with the backend failing, want an error")
+	}
+	wantState("at the threshold", "open", 0)
+	if err := notify("held"); err != nil {
+		t.Errorf("Notify() = %v while open, want the message held", err)
+	}
+	wantState("open", "open", 1)
+
+	// The held message probes the backend, still failing.
+	b.halfOpen()
+	wantState("failed probe", "open", 1)
+
+	// Without a held message, the next one probes the backend.
+	b.mu.Lock()
+	b.held = nil
+	b.mu.Unlock()
+	b.halfOpen()
+	wantState("half-open", "half-open", 0)
+	if err := notify("probe"); err != nil {
+		t.Errorf("Notify() = %v while half-open, want the probe held on failure", err)
+	}
+	wantState("failed probe", "open", 1)
+
+	// A delivered probe closes the breaker and delivers the held messages.
+	backend.setFailing(false)
+	if err := notify("later"); err != nil {
+		t.Fatal(err)
+	}
+	b.halfOpen()
+	wantState("recovered", "closed", 0)
+	if got, want := backend.titles(), []string{"probe", "later"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %q, want %q", got, want)
+	}
+	if err := notify("closed"); err != nil {
+		t.Errorf("Notify() = %v once closed, want nil", err)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	backend := &switchNotifier{}
+	b := newCircuitBreaker("discord", backend, 2, time.Hour, newMetrics())
+	for _, failing := range []bool{true, false, true, false, true} {
+		backend.setFailing(failing)
+		b.Notify(context.Background(), Message{Title: "alert"})
+	}
+	if state, _ := b.State(); state != "closed" {
+		t.Errorf("State() = %s after failures interleaved with deliveries, want closed", state)
+	}
+}
+
+func TestCircuitBreakerDropsOldest(t *testing.T) {
+	metrics := newMetrics()
+	b := newCircuitBreaker("discord", &switchNotifier{failing: true}, 1, time.Hour, metrics)
+	b.Notify(context.Background(), Message{Title: "opening"})
+	for i := 0; i <= breakerHeldSize; i++ {
+		b.Notify(context.Background(), Message{Title: "held"})
+	}
+
+	if _, held := b.State(); held != breakerHeldSize {
+		t.Errorf("held %d messages, want %d", held, breakerHeldSize)
+	}
+	if got := counterValue(t, metrics.notifyBreakerDropped); got != 1 {
+		t.Errorf("counted %v dropped messages, want 1", got)
+	}
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	backend := &switchNotifier{failing: true}
+	b := newCircuitBreaker("discord", backend, 1, 10*time.Millisecond, newMetrics())
+	b.Notify(context.Background(), Message{Title: "opening"})
+	backend.setFailing(false)
+	b.Notify(context.Background(), Message{Title: "held"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for state, _ := b.State(); state != "closed" && time.Now().Before(deadline); state, _ = b.State() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, want := backend.titles(), []string{"held"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %q once the cooldown elapsed, want %q", got, want)
+	}
+}
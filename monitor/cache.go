@@ -0,0 +1,105 @@
+package monitor
+
+import "sync"
+
+// rootCache maps block heights to the roots reported at that height. Only
+// the `window` heights leading up to the highest one seen are retained. It
+// is written by a single tm worker and may be read concurrently.
+type rootCache struct {
+	window int64
+
+	mu      sync.RWMutex
+	tip     int64
+	records map[int64][]rootHashRecord
+}
+
+func newRootCache(window int) *rootCache {
+	return &rootCache{
+		window:  int64(window),
+		records: make(map[int64][]rootHashRecord),
+	}
+}
+
+func (c *rootCache) Get(height int64) ([]rootHashRecord, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	records, ok := c.records[height]
+	return records, ok
+}
+
+// Set stores the records for `height`, evicting heights that fell out of the
+// window. Heights that are already out of the window are ignored.
+func (c *rootCache) Set(height int64, records []rootHashRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if height <= c.tip-c.window {
+		return
+	}
+
+	c.records[height] = records
+	if height > c.tip {
+		c.tip = height
+		c.evict()
+	}
+}
+
+// Append adds `record` to the records of `height` and returns the records
+// stored before it. The stored slice is never modified in place, so that
+// the records returned by Get can be read while others are appended.
+func (c *rootCache) Append(height int64, record rootHashRecord) []rootHashRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.records[height]
+	if height <= c.tip-c.window {
+		return prev
+	}
+
+	records := make([]rootHashRecord, len(prev), len(prev)+1)
+	copy(records, prev)
+	c.records[height] = append(records, record)
+	if height > c.tip {
+		c.tip = height
+		c.evict()
+	}
+	return prev
+}
+
+// Reset drops every record, e.g. after a chain restart.
+func (c *rootCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tip = 0
+	c.records = make(map[int64][]rootHashRecord)
+}
+
+// Len returns the number of cached heights.
+func (c *rootCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.records)
+}
+
+// Recent returns the records of the `n` heights leading up to the tip.
+func (c *rootCache) Recent(n int) map[int64][]rootHashRecord {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	recent := make(map[int64][]rootHashRecord)
+	for height, records := range c.records {
+		if height > c.tip-int64(n) {
+			recent[height] = records
+		}
+	}
+	return recent
+}
+
+func (c *rootCache) evict() {
+	floor := c.tip - c.window
+	for height := range c.records {
+		if height <= floor {
+			delete(c.records, height)
+		}
+	}
+}
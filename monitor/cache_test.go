@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRootCacheWindow(t *testing.T) {
+	cache := newRootCache(1000)
+	for height := int64(1); height <= 2000; height++ {
+		cache.Append(height, rootHashRecord{PodName: "pod-0", Root: fmt.Sprintf("%x", height)})
+	}
+
+	if got := cache.Len(); got != 1000 {
+		t.Errorf("Len() = %d after 2000 heights, want 1000", got)
+	}
+	tests := []struct {
+		height int64
+		want   bool
+	}{
+		{1, false},
+		{1000, false},
+		{1001, true},
+		{2000, true},
+	}
+	for _, tt := range tests {
+		if _, ok := cache.Get(tt.height); ok != tt.want {
+			t.Errorf("Get(%d) found = %v, want %v", tt.height, ok, tt.want)
+		}
+	}
+}
+
+func TestRootCacheAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		// before are the heights pod-0 reported, with a window of 5.
+		before   []int64
+		height   int64
+		wantPrev int
+		wantLen  int
+	}{
+		{"first report", nil, 10, 0, 1},
+		{"second report", []int64{10}, 10, 1, 2},
+		{"below the tip, within the window", []int64{10, 12}, 10, 1, 2},
+		{"out of the window", []int64{20}, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newRootCache(5)
+			for _, height := range tt.before {
+				cache.Append(height, rootHashRecord{PodName: "pod-0", Root: "aa"})
+			}
+
+			prev := cache.Append(tt.height, rootHashRecord{PodName: "pod-1", Root: "aa"})
+			if len(prev) != tt.wantPrev {
+				t.Errorf("Append() returned %d records, want %d", len(prev), tt.wantPrev)
+			}
+			if records, _ := cache.Get(tt.height); len(records) != tt.wantLen {
+				t.Errorf("Get(%d) = %d records, want %d", tt.height, len(records), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestRootCacheAppendCopies(t *testing.T) {
+	cache := newRootCache(10)
+	cache.Append(1, rootHashRecord{PodName: "pod-0", Root: "aa"})
+	read, _ := cache.Get(1)
+	cache.Append(1, rootHashRecord{PodName: "pod-1", Root: "bb"})
+
+	if len(read) != 1 || read[0].PodName != "pod-0" {
+		t.Errorf("records read before an append = %v, want those of pod-0 only", read)
+	}
+}
+
+func TestRootCacheRecent(t *testing.T) {
+	cache := newRootCache(100)
+	for height := int64(1); height <= 10; height++ {
+		cache.Set(height, []rootHashRecord{{PodName: "pod-0", Root: "aa"}})
+	}
+	recent := cache.Recent(3)
+	if len(recent) != 3 {
+		t.Fatalf("Recent(3) = %d heights, want 3", len(recent))
+	}
+	for _, height := range []int64{8, 9, 10} {
+		if _, ok := recent[height]; !ok {
+			t.Errorf("Recent(3) misses height %d", height)
+		}
+	}
+
+	cache.Reset()
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Len() = %d after Reset, want 0", got)
+	}
+	cache.Set(1, []rootHashRecord{{PodName: "pod-0", Root: "bb"}})
+	if _, ok := cache.Get(1); !ok {
+		t.Error("height 1 not cached after Reset, want it cached as the new tip")
+	}
+}
+
+func TestProcessCommitLogsRestartBeyondCacheWindow(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.CacheWindow = 5
+	var entries []LogEntry
+	for height := int64(1); height <= 50; height++ {
+		entries = append(entries, commitEntry("pod-0", height, "aa"), commitEntry("pod-1", height, "aa"))
+	}
+	entries = append(entries, commitEntry("pod-0", 1, "bb"), commitEntry("pod-1", 1, "bb"))
+
+	notifier := processEntries(t, cfg, tmDeps{}, entries...)
+	want := []string{"Height regression", "Height regression", "Chain restart"}
+	if got := notifier.titles(); !slices.Equal(got, want) {
+		t.Errorf("notified %q once the window evicted the restart height, want %q", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkTimeout bounds every step of a connectivity check. A tail stream that
+// returns no error within it is considered working.
+const checkTimeout = 10 * time.Second
+
+// Check checks that the monitor can do its job without entering the main
+// loop: the GCP credentials and filters are accepted by the logging API,
+// unless the logs are pushed to the ingest endpoint, and every notifier
+// backend delivers a test message. Every step is reported on `w`. A backend
+// whose minimum severity is above info is skipped rather than sent the test
+// message, e.g. PagerDuty, which would page.
+func Check(cfg *Config, w io.Writer) error {
+	failed := false
+	report := func(step string, err error) {
+		if err != nil {
+			failed = true
+			fmt.Fprintf(w, "FAIL %s: %v\n", step, err)
+			return
+		}
+		fmt.Fprintf(w, "ok   %s\n", step)
+	}
+	report("configuration", nil)
+
+	if cfg.IngestToken != "" {
+		report("logs pushed to /ingest, GCP not checked", nil)
+	} else {
+		checkGCP(cfg, report)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	msg := Message{
+		Severity: SeverityInfo,
+		Title:    "Connectivity test",
+		Body:     "monitor connectivity test",
+	}
+	for _, notifier := range buildNotifier(newLiveConfig(cfg), nil, newMetrics()) {
+		gate := notifier.(severityGate)
+		step := "notifier " + gate.backend
+		if !gate.accepts(msg) {
+			fmt.Fprintf(w, "skip %s: skipped (severity gate), only %s alerts are sent\n", step, cfg.minSeverity(gate.backend))
+			continue
+		}
+		report(step, gate.Notify(ctx, msg))
+	}
+
+	if failed {
+		return errors.New("connectivity check failed")
+	}
+	return nil
+}
+
+// checkGCP checks that the GCP credentials and filters, or subscriptions,
+// of every network are accepted.
+func checkGCP(cfg *Config, report func(step string, err error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	client, err := newLoggingClient(ctx, cfg.Credentials)
+	report(fmt.Sprintf("logging client (%s)", cfg.CredentialsMode), err)
+	if err == nil {
+		defer client.Close()
+		for _, network := range cfg.Networks {
+			if cfg.EnableTM && network.CommitSubscription() == "" {
+				report(fmt.Sprintf("%s: tail commit logs", network.Name), checkTail(loggingTailClient{client}, network.Projects(), network.CommitLogFilter()))
+			}
+			if cfg.EnablePD && network.ErrorSubscription() == "" {
+				report(fmt.Sprintf("%s: tail error logs", network.Name), checkTail(loggingTailClient{client}, network.Projects(), network.ErrorLogFilter()))
+			}
+		}
+	}
+
+	for _, network := range cfg.Networks {
+		if subscription := network.CommitSubscription(); cfg.EnableTM && subscription != "" {
+			report(fmt.Sprintf("%s: commit logs subscription", network.Name), checkSubscription(cfg, subscription))
+		}
+		if subscription := network.ErrorSubscription(); cfg.EnablePD && subscription != "" {
+			report(fmt.Sprintf("%s: error logs subscription", network.Name), checkSubscription(cfg, subscription))
+		}
+	}
+}
+
+// checkSubscription checks that `subscription` exists and is readable.
+// Nothing is pulled, so that no message is taken from the monitor.
+func checkSubscription(cfg *Config, subscription string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	service, err := newPubSubService(ctx, cfg.Credentials)
+	if err != nil {
+		return fmt.Errorf("creating Pub/Sub client: %v", err)
+	}
+	if _, err := service.Projects.Subscriptions.Get(subscription).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("getting subscription: %v", err)
+	}
+	return nil
+}
+
+// checkTail opens a tail stream of the entries of `projectIDs` matching
+// `filter`, then closes it.
+func checkTail(client tailClient, projectIDs []string, filter string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	stream, err := client.TailLogEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("TailLogEntries error: %v", err)
+	}
+	defer stream.CloseSend()
+
+	resourceNames := make([]string, 0, len(projectIDs))
+	for _, projectID := range projectIDs {
+		resourceNames = append(resourceNames, "projects/"+projectID)
+	}
+	if err := stream.Send(&loggingpb.TailLogEntriesRequest{ResourceNames: resourceNames, Filter: filter}); err != nil {
+		return fmt.Errorf("%w: %v", errStreamSend, err)
+	}
+
+	// Invalid filters and missing permissions are only reported on the
+	// first receive; a stream without matching entries just blocks.
+	if _, err := stream.Recv(); err != nil && status.Code(err) != codes.DeadlineExceeded {
+		return fmt.Errorf("stream.Recv error: %v", err)
+	}
+	return nil
+}
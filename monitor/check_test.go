@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingServer serves `status` to every request, counting them.
+func countingServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestCheck(t *testing.T) {
+	discord, discordRequests := countingServer(t, http.StatusNoContent)
+	slack, slackRequests := countingServer(t, http.StatusForbidden)
+
+	tests := []struct {
+		name     string
+		cfg      func(cfg *Config)
+		want     []string
+		wantErr  bool
+		requests map[*atomic.Int32]int32
+	}{
+		{
+			name: "delivered",
+			cfg: func(cfg *Config) {
+				cfg.DiscordWebhookURL = discord.URL
+			},
+			want: []string{
+				"ok   configuration",
+				"ok   logs pushed to /ingest, GCP not checked",
+				"ok   notifier discord",
+			},
+			requests: map[*atomic.Int32]int32{discordRequests: 1},
+		},
+		{
+			name: "backend failing",
+			cfg: func(cfg *Config) {
+				cfg.DiscordWebhookURL = discord.URL
+				cfg.SlackWebhookURL = slack.URL
+			},
+			want: []string{
+				"ok   configuration",
+				"ok   logs pushed to /ingest, GCP not checked",
+				"ok   notifier discord",
+				"FAIL notifier slack: slack webhook returned 403 Forbidden",
+			},
+			wantErr:  true,
+			requests: map[*atomic.Int32]int32{discordRequests: 1, slackRequests: 1},
+		},
+		{
+			name: "severity gate",
+			cfg: func(cfg *Config) {
+				cfg.DiscordWebhookURL = discord.URL
+				cfg.SlackWebhookURL = slack.URL
+				cfg.MinSeverities = map[string]Severity{"slack": SeverityError}
+				cfg.PagerDutyRoutingKey = "routing-key"
+			},
+			want: []string{
+				"ok   configuration",
+				"ok   logs pushed to /ingest, GCP not checked",
+				"ok   notifier discord",
+				"skip notifier slack: skipped (severity gate), only error alerts are sent",
+				"skip notifier pagerduty: skipped (severity gate), only critical alerts are sent",
+			},
+			requests: map[*atomic.Int32]int32{discordRequests: 1, slackRequests: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discordRequests.Store(0)
+			slackRequests.Store(0)
+			cfg := testConfig(t)
+			cfg.IngestToken = "token"
+			tt.cfg(cfg)
+
+			var out bytes.Buffer
+			err := Check(cfg, &out)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := strings.TrimSpace(out.String()), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("Check() printed:\n%s\nwant:\n%s", got, want)
+			}
+			for requests, want := range tt.requests {
+				if got := requests.Load(); got != want {
+					t.Errorf("backend received %d requests, want %d", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// CommitLogPattern is a regular expression matching a commit log line, see
+// compileCommitLogPattern for the named groups it captures.
+type CommitLogPattern struct {
+	// Name identifies the pattern in the logs.
+	Name string
+	Re   *regexp.Regexp
+}
+
+// defaultCommitLogPatterns match the commit logs of the CometBFT and
+// Tendermint versions, in order of preference. Every version logs both
+// "finalizing commit of block", whose root is the app hash in the header of
+// the block, and "committed state", whose app hash results from the block
+// and is the root of the next one. The latter are only relied on for the
+// pods not logging the former, e.g. with the consensus module logging at
+// error level.
+var defaultCommitLogPatterns = []struct{ name, pattern string }{
+	{"finalizing-commit", `finalizing commit of block\s+module=consensus height=(?P<height>\d+) hash=(?P<hash>[0-9a-fA-F]+) root=(?P<root>[0-9a-fA-F]+) num_txs=(?P<num_txs>\d+)`},
+	// Tendermint 0.33 and earlier.
+	{"finalizing-commit-legacy", `Finalizing commit of block with (?P<num_txs>\d+) txs\s+module=consensus height=(?P<height>\d+) hash=(?P<hash>[0-9a-fA-F]+) root=(?P<root>[0-9a-fA-F]+)`},
+	// log_format = "json", whose keys are sorted.
+	{"finalizing-commit-json", `"_msg":"finalizing commit of block".*"hash":"(?P<hash>[0-9a-fA-F]+)","height":(?P<height>\d+).*"num_txs":(?P<num_txs>\d+).*"root":"(?P<root>[0-9a-fA-F]+)"`},
+	// CometBFT 0.38, which logs the root of the block.
+	{"committed-state-block-app-hash", `committed state\s+module=state height=(?P<height>\d+) block_app_hash=(?P<root>[0-9a-fA-F]+)`},
+	// CometBFT 0.34 and 0.37.
+	{"committed-state", `committed state\s+module=state height=(?P<height>\d+) num_txs=\d+ app_hash=(?P<next_root>[0-9a-fA-F]+)`},
+	// Tendermint 0.33 and earlier.
+	{"committed-state-legacy", `Committed state\s+module=state height=(?P<height>\d+) txs=\d+ appHash=(?P<next_root>[0-9a-fA-F]+)`},
+}
+
+// DefaultCommitLogPatterns returns the patterns matching the commit logs of
+// the known CometBFT and Tendermint versions, in order of preference.
+func DefaultCommitLogPatterns() []CommitLogPattern {
+	patterns := make([]CommitLogPattern, 0, len(defaultCommitLogPatterns))
+	for _, p := range defaultCommitLogPatterns {
+		re, err := compileCommitLogPattern(p.pattern)
+		if err != nil {
+			panic(err)
+		}
+		patterns = append(patterns, CommitLogPattern{Name: p.name, Re: re})
+	}
+	return patterns
+}
+
+// commitLogParser parses the commit logs of a network with the first of its
+// patterns that matches. Once a pod has logged a line matching a pattern,
+// the lines matching the patterns after it are ignored for that pod, so that
+// a pod logging several variants of each commit reports it once.
+type commitLogParser struct {
+	patterns []CommitLogPattern
+	// preferred is the index of the first pattern matched by each pod.
+	preferred map[string]int
+}
+
+func newCommitLogParser(patterns []CommitLogPattern) *commitLogParser {
+	return &commitLogParser{patterns: patterns, preferred: make(map[string]int)}
+}
+
+// Parse parses a commit log line of `podName`, see parseCommitLog. It
+// returns errNoCommitLog for the lines matching none of the patterns, or
+// only ones the pod is known to log a preferred variant of.
+func (p *commitLogParser) Parse(podName, logEntry string, timestamp time.Time) (*LogData, error) {
+	for i, pattern := range p.patterns {
+		if preferred, ok := p.preferred[podName]; ok && i > preferred {
+			break
+		}
+		if !pattern.Re.MatchString(logEntry) {
+			continue
+		}
+		if preferred, ok := p.preferred[podName]; !ok || i < preferred {
+			p.preferred[podName] = i
+		}
+		slog.Debug("commit log matched", "pod_name", podName, "pattern", pattern.Name)
+		return parseCommitLog(pattern.Re, podName, logEntry, timestamp)
+	}
+	return nil, errNoCommitLog
+}
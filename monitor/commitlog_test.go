@@ -0,0 +1,264 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCommitLog(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	re := DefaultCommitLogPatterns()[0].Re
+
+	tests := []struct {
+		name string
+		line string
+		want *LogData
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{
+			name: "well-formed",
+			line: "finalizing commit of block                module=consensus height=42 hash=abcdef root=0123ab num_txs=3",
+			want: &LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3, PodName: "pod-0", Timestamp: timestamp},
+		},
+		{
+			name: "mixed-case hex",
+			line: "finalizing commit of block module=consensus height=42 hash=AbCdEf root=0123AB num_txs=3",
+			want: &LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3, PodName: "pod-0", Timestamp: timestamp},
+		},
+		{
+			name: "extra surrounding text",
+			line: `I[2024-01-02|03:04:05.000] finalizing commit of block                module=consensus height=42 hash=abcdef root=0123ab num_txs=3 extra="ignored"`,
+			want: &LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3, PodName: "pod-0", Timestamp: timestamp},
+		},
+		{
+			name: "height beyond 32 bits",
+			line: "finalizing commit of block module=consensus height=9223372036854775807 hash=abcdef root=0123ab num_txs=4294967296",
+			want: &LogData{Height: 9223372036854775807, Hash: "abcdef", Root: "0123ab", NumTxs: 4294967296, PodName: "pod-0", Timestamp: timestamp},
+		},
+		{
+			name: "missing root",
+			line: "finalizing commit of block module=consensus height=42 hash=abcdef num_txs=3",
+			err:  errNoCommitLog.Error(),
+		},
+		{
+			name: "non-numeric height",
+			line: "finalizing commit of block module=consensus height=forty-two hash=abcdef root=0123ab num_txs=3",
+			err:  errNoCommitLog.Error(),
+		},
+		{
+			name: "height out of range",
+			line: "finalizing commit of block module=consensus height=99999999999999999999 hash=abcdef root=0123ab num_txs=3",
+			err:  "parsing height",
+		},
+		{
+			name: "num_txs out of range",
+			line: "finalizing commit of block module=consensus height=42 hash=abcdef root=0123ab num_txs=99999999999999999999",
+			err:  "parsing num_txs",
+		},
+		{
+			name: "empty string",
+			line: "",
+			err:  errNoCommitLog.Error(),
+		},
+		{
+			name: "other log line",
+			line: "received proposal                          module=consensus proposal=\"Proposal{42/0}\"",
+			err:  errNoCommitLog.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCommitLog(re, "pod-0", tt.line, timestamp)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseCommitLog() = %+v, %v, want error %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCommitLog() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCommitLog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultCommitLogPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		line    string
+		want    LogData
+	}{
+		{
+			pattern: "finalizing-commit",
+			line:    "I[2024-01-02|03:04:05.000] finalizing commit of block                module=consensus height=42 hash=ABCDEF root=0123AB num_txs=3",
+			want:    LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3},
+		},
+		{
+			pattern: "finalizing-commit-legacy",
+			line:    "I[2020-01-02|03:04:05.000] Finalizing commit of block with 3 txs     module=consensus height=42 hash=ABCDEF root=0123AB",
+			want:    LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3},
+		},
+		{
+			pattern: "finalizing-commit-json",
+			line:    `{"_msg":"finalizing commit of block","hash":"ABCDEF","height":42,"level":"info","module":"consensus","num_txs":3,"root":"0123AB"}`,
+			want:    LogData{Height: 42, Hash: "abcdef", Root: "0123ab", NumTxs: 3},
+		},
+		{
+			pattern: "committed-state-block-app-hash",
+			line:    "I[2024-01-02|03:04:05.000] committed state                            module=state height=42 block_app_hash=0123AB",
+			want:    LogData{Height: 42, Root: "0123ab", NumTxsUnknown: true},
+		},
+		{
+			// The app hash resulting from block 41 is the root of block 42.
+			pattern: "committed-state",
+			line:    "I[2024-01-02|03:04:05.000] committed state                            module=state height=41 num_txs=3 app_hash=0123AB",
+			want:    LogData{Height: 42, Root: "0123ab", NumTxsUnknown: true},
+		},
+		{
+			pattern: "committed-state-legacy",
+			line:    "I[2020-01-02|03:04:05.000] Committed state                            module=state height=41 txs=3 appHash=0123AB",
+			want:    LogData{Height: 42, Root: "0123ab", NumTxsUnknown: true},
+		},
+	}
+	if len(tests) != len(defaultCommitLogPatterns) {
+		t.Fatalf("%d patterns tested, want every one of the %d default patterns", len(tests), len(defaultCommitLogPatterns))
+	}
+
+	patterns := DefaultCommitLogPatterns()
+	for i, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if patterns[i].Name != tt.pattern {
+				t.Fatalf("default pattern %d is %s, want %s", i, patterns[i].Name, tt.pattern)
+			}
+			for j, pattern := range patterns {
+				if j != i && pattern.Re.MatchString(tt.line) {
+					t.Errorf("%s line also matches %s", tt.pattern, pattern.Name)
+				}
+			}
+
+			got, err := newCommitLogParser(patterns).Parse("pod-0", tt.line, time.Time{})
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			tt.want.PodName = "pod-0"
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitLogParserPreferredPattern(t *testing.T) {
+	const (
+		finalizing = "finalizing commit of block module=consensus height=42 hash=abcdef root=0123ab num_txs=3"
+		committed  = "committed state module=state height=42 num_txs=3 app_hash=4567cd"
+	)
+	parser := newCommitLogParser(DefaultCommitLogPatterns())
+
+	steps := []struct {
+		podName string
+		line    string
+		// height is the height parsed, zero if the line is ignored.
+		height int64
+	}{
+		// pod-0 logs only the app hash resulting from the blocks.
+		{"pod-0", committed, 43},
+		// pod-1 logs both variants, the resulting app hash is ignored once
+		// the root of the block was seen.
+		{"pod-1", finalizing, 42},
+		{"pod-1", committed, 0},
+		// pod-0 switching to the preferred variant sticks to it.
+		{"pod-0", finalizing, 42},
+		{"pod-0", committed, 0},
+	}
+	for i, step := range steps {
+		got, err := parser.Parse(step.podName, step.line, time.Time{})
+		if step.height == 0 {
+			if !errors.Is(err, errNoCommitLog) {
+				t.Errorf("step %d: Parse(%s) = %+v, %v, want the line ignored", i, step.podName, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("step %d: Parse(%s) error: %v", i, step.podName, err)
+		}
+		if got.Height != step.height {
+			t.Errorf("step %d: Parse(%s) height = %d, want %d", i, step.podName, got.Height, step.height)
+		}
+	}
+}
+
+func TestCompileCommitLogPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{`height=(?P<height>\d+) root=(?P<root>\w+)`, ""},
+		{`height=(?P<height>\d+) app_hash=(?P<next_root>\w+)`, ""},
+		{`root=(?P<root>\w+)`, "height"},
+		{`height=(?P<height>\d+)`, "root or next_root"},
+		{`height=(?P<height>\d+`, "invalid commit log pattern"},
+	}
+	for _, tt := range tests {
+		_, err := compileCommitLogPattern(tt.pattern)
+		if tt.err == "" && err != nil {
+			t.Errorf("compileCommitLogPattern(%q) error: %v", tt.pattern, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("compileCommitLogPattern(%q) = %v, want an error about %q", tt.pattern, err, tt.err)
+		}
+	}
+}
+
+func TestCommitLogParserLogsPattern(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	parser := newCommitLogParser(DefaultCommitLogPatterns())
+	if _, err := parser.Parse("pod-0", "committed state module=state height=41 num_txs=3 app_hash=0123ab", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "pattern=committed-state"; !strings.Contains(logs.String(), want) {
+		t.Errorf("debug logs %q do not mention %s", logs.String(), want)
+	}
+}
+
+func TestProcessCommitLogsMixedVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		// committed is the app hash pod-1 logs after block 41.
+		committed string
+		want      []string
+		confirmed int64
+	}{
+		{"agreeing", "0123ab", nil, 42},
+		{"diverging", "4567cd", []string{"Root mismatch"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := newRootsAPI()
+			notifier := processEntries(t, testConfig(t), tmDeps{Roots: roots},
+				NewLogEntry("pod-0", "finalizing commit of block module=consensus height=42 hash=abcdef root=0123ab num_txs=3", time.Time{}),
+				NewLogEntry("pod-1", "Committed state module=state height=41 txs=3 appHash="+strings.ToUpper(tt.committed), time.Time{}),
+			)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+			if got := confirmedHeight(t, roots); got != tt.confirmed {
+				t.Errorf("confirmed height = %d, want %d", got, tt.confirmed)
+			}
+		})
+	}
+}
@@ -0,0 +1,809 @@
+package monitor
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"google.golang.org/api/option"
+)
+
+// Config holds the settings read from the flags and the environment.
+type Config struct {
+	Networks []NetworkConfig
+	// Credentials authenticate the logging client, nil for the Application
+	// Default Credentials. CredentialsMode tells which were selected.
+	Credentials     option.ClientOption
+	CredentialsMode string
+
+	DiscordWebhookURL string
+	// WebhookSigningSecret, when set, signs the body of every notification
+	// with HMAC-SHA256, sent in WebhookSignatureHeader.
+	WebhookSigningSecret   string
+	WebhookSignatureHeader string
+	// MinSeverities are the least severe alerts sent to each backend, keyed
+	// by backend name, e.g. "slack", see minSeverity for the defaults.
+	MinSeverities map[string]Severity
+	// AlertMention is prepended to the critical Discord messages.
+	AlertMention string
+	// EnvTag identifies the monitor instance, e.g. "prod" or "staging". It
+	// is prepended to the messages and labels the metrics and the logs.
+	EnvTag string
+	// DiscordSeverityWebhookURLs override DiscordWebhookURL for the
+	// messages of a given severity.
+	DiscordSeverityWebhookURLs map[Severity]string
+	SlackWebhookURL            string
+	PagerDutyRoutingKey        string
+	TelegramBotToken           string
+	TelegramChatID             string
+	// SNSTopicARN is the Amazon SNS topic receiving the alerts, published
+	// with the region of the topic and the credentials of SNSConfig.
+	SNSTopicARN string
+	SNSConfig   aws.Config
+	// WebhookURL receives a JSON body rendered from WebhookTemplate for
+	// every alert, with WebhookHeaders.
+	WebhookURL      string
+	WebhookTemplate *template.Template
+	WebhookHeaders  http.Header
+
+	MetricsAddr    string
+	ReadyStaleness time.Duration
+	// CommitLogPatterns are tried in order on every tm log line.
+	CommitLogPatterns []CommitLogPattern
+	// formatter renders the alert bodies, with the templates of
+	// ALERT_TEMPLATES_DIR if set.
+	formatter *messageFormatter
+	// PayloadField is the field holding the log line in JSON payloads.
+	PayloadField string
+	// PodNameLabel is the label the pod name is read from when an entry has
+	// no pod_name label.
+	PodNameLabel string
+	CacheWindow  int
+	// ExitOnMismatch stops the monitor once a root mismatch is alerted.
+	ExitOnMismatch bool
+	// EnableTM and EnablePD select the workers to run.
+	EnableTM         bool
+	EnablePD         bool
+	DryRun           bool
+	DedupWindow      time.Duration
+	NotifyRatePerMin int
+	// NotifyBurst is the number of alerts sent at once before the rate of
+	// NotifyRatePerMin applies.
+	NotifyBurst int
+	// LogBufferSize bounds the number of log entries buffered between a log
+	// source and its worker.
+	LogBufferSize int
+	// LogBufferDropErrors drops the oldest buffered pd errors once the
+	// buffer is full, instead of waiting for the pd worker. Commit logs are
+	// never dropped.
+	LogBufferDropErrors bool
+	// NotifyWorkers bounds the number of backends delivered to concurrently.
+	NotifyWorkers int
+	// NotifyBreakerFailures is the number of deliveries in a row to fail
+	// before the alerts to a backend are held for NotifyBreakerCooldown.
+	NotifyBreakerFailures int
+	NotifyBreakerCooldown time.Duration
+	// BatchAlerts combines the alerts raised within BatchInterval of each
+	// other into a single message.
+	BatchAlerts   bool
+	BatchInterval time.Duration
+	// NotifyTimeout bounds every request made to a notification backend.
+	NotifyTimeout time.Duration
+	// NotifyRootCAs, if set, are trusted by the notification backends in
+	// addition to the system ones. NotifyInsecureSkipVerify disables the
+	// certificate checks altogether, for testing only.
+	NotifyRootCAs            *x509.CertPool
+	NotifyInsecureSkipVerify bool
+
+	// MaxErrorChars bounds the length of the pd error payloads forwarded,
+	// UploadFullErrors attaches the full payload of the truncated ones.
+	MaxErrorChars    int
+	UploadFullErrors bool
+	// PDErrorHeightPattern extracts the height a pd error occurred at.
+	PDErrorHeightPattern *regexp.Regexp
+	// PDSeverityRules override the severity of the pd errors by payload.
+	PDSeverityRules SeverityRules
+
+	// OTLPEndpoint receives the traces of the pipeline, tracing is disabled
+	// when unset.
+	OTLPEndpoint string
+
+	// EventBufferSize is the number of recent events served at /events.
+	EventBufferSize int
+	// KafkaBrokers and KafkaTopic, when set, receive every event as JSON,
+	// KafkaBufferSize of them being queued at most.
+	KafkaBrokers    []string
+	KafkaTopic      string
+	KafkaBufferSize int
+	// ExplorerURL, when set, is queried for the canonical root of the
+	// confirmed heights, substituting {height} and {network}, at most
+	// ExplorerRatePerMin times a minute. The root is read from the
+	// ExplorerRootField of the JSON response, a dotted path.
+	ExplorerURL        string
+	ExplorerRootField  string
+	ExplorerRatePerMin int
+	// SummaryInterval is how often a summary of every network is posted,
+	// zero disables it.
+	SummaryInterval time.Duration
+
+	// MilestoneInterval announces every height that is a multiple of it,
+	// zero disables periodic milestones.
+	MilestoneInterval int
+	// MilestoneHeights are announced once, e.g. known upgrade heights.
+	MilestoneHeights map[int64]bool
+
+	// LivenessTimeout is how long a pod may go without reporting a new
+	// commit while others advance, zero disables the check.
+	LivenessTimeout time.Duration
+	// ChainStallTimeout is how long the highest reported height may stay
+	// unchanged before the chain is considered stalled, zero disables it.
+	ChainStallTimeout time.Duration
+	// StreamConfig controls how the log streams are established and
+	// re-established.
+	StreamConfig StreamConfig
+	// ProcessRatePerSec paces the processing of the commit logs, zero
+	// processes them as fast as they come.
+	ProcessRatePerSec int
+	// StartupTimeout is how long the tm worker may go without any commit
+	// log after starting before the filter is suspected, zero disables it.
+	StartupTimeout time.Duration
+
+	// ComparedPods selects the pods whose roots are compared.
+	ComparedPods PodFilter
+
+	// MismatchConfirmations is the number of divergent reports, observed
+	// within MismatchConfirmWindow, required before a mismatch is alerted.
+	MismatchConfirmations int
+	MismatchConfirmWindow time.Duration
+	// QuorumSize is the number of pods that must agree on the root of a
+	// height before it is confirmed.
+	QuorumSize int
+	// EscalateAfter, when set, alerts a mismatch at the error severity
+	// first, then at the critical one if it has not cleared after it.
+	EscalateAfter time.Duration
+
+	// RegressionTolerance is how far below its highest height a pod may
+	// report before it is flagged as having regressed.
+	RegressionTolerance int
+
+	// MaxLagBlocks alerts when a pod falls more than this many blocks behind
+	// the furthest-ahead pod, zero disables the alert.
+	MaxLagBlocks int
+
+	// EmptyBlockStreak alerts after this many consecutive empty blocks on a
+	// network that had transactions, zero disables the alert.
+	EmptyBlockStreak int
+
+	// MaxTxsAlert flags blocks with more transactions than this, zero
+	// disables the alert.
+	MaxTxsAlert int
+
+	// SQLitePath, when set, is a database recording every commit log parsed.
+	SQLitePath string
+	// AuditLogPath, when set, is a file recording every alert, rotated once
+	// it reaches AuditMaxSizeMB.
+	AuditLogPath   string
+	AuditMaxSizeMB int
+	// MaintenanceWindow is the length of the maintenance windows opened
+	// without an end, e.g. with SIGUSR1.
+	MaintenanceWindow time.Duration
+
+	// ReplayFile, when set, is replayed instead of tailing the GCP logs.
+	ReplayFile string
+	// IngestToken, when set, authenticates the log entries pushed to the
+	// ingest endpoint, which are processed instead of tailing the GCP logs.
+	IngestToken string
+	// BackfillFrom and BackfillTo, when set, bound the past logs audited
+	// instead of tailing the GCP logs.
+	BackfillFrom, BackfillTo time.Time
+	// SinceHeight, when set, skips the commit logs below it. With
+	// SinceHeightAuto, it is seeded from the first commit logs instead.
+	SinceHeight     int64
+	SinceHeightAuto bool
+}
+
+// Settings resolves the settings read by LoadConfig, named after their
+// environment variables, e.g. GCP_PROJECT_ID.
+type Settings interface {
+	// Lookup returns the value of the setting `name` and whether it was set
+	// at all.
+	Lookup(name string) (string, bool)
+	// Get returns the value of the setting `name`, or an empty string if
+	// unset.
+	Get(name string) string
+}
+
+// LoadConfig reads the configuration from `s`. Every problem found is
+// reported at once rather than stopping at the first one. When `replayFile`
+// or INGEST_TOKEN is set, the GCP settings are not required.
+func LoadConfig(s Settings, replayFile string) (*Config, error) {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	cfg := &Config{
+		DiscordWebhookURL:      s.Get("DISCORD_WEBHOOK_URL"),
+		SlackWebhookURL:        s.Get("SLACK_WEBHOOK_URL"),
+		PagerDutyRoutingKey:    s.Get("PAGERDUTY_ROUTING_KEY"),
+		TelegramBotToken:       s.Get("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:         s.Get("TELEGRAM_CHAT_ID"),
+		WebhookURL:             s.Get("WEBHOOK_URL"),
+		SNSTopicARN:            s.Get("SNS_TOPIC_ARN"),
+		OTLPEndpoint:           s.Get("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		MetricsAddr:            s.Get("METRICS_ADDR"),
+		SQLitePath:             s.Get("SQLITE_PATH"),
+		AuditLogPath:           s.Get("AUDIT_LOG_PATH"),
+		AlertMention:           strings.TrimSpace(s.Get("ALERT_MENTION")),
+		EnvTag:                 strings.TrimSpace(s.Get("ENV_TAG")),
+		WebhookSigningSecret:   s.Get("WEBHOOK_SIGNING_SECRET"),
+		WebhookSignatureHeader: s.Get("WEBHOOK_SIGNATURE_HEADER"),
+		ReplayFile:             replayFile,
+		IngestToken:            s.Get("INGEST_TOKEN"),
+	}
+	replaying := replayFile != ""
+	// Pushed logs come from wherever the pusher reads them.
+	ingesting := cfg.IngestToken != "" && !replaying
+	if cfg.WebhookSignatureHeader == "" {
+		cfg.WebhookSignatureHeader = defaultSignatureHeader
+	}
+	if cfg.MetricsAddr == "" {
+		cfg.MetricsAddr = ":9090"
+	}
+
+	if cfg.DiscordWebhookURL == "" && cfg.SlackWebhookURL == "" && cfg.PagerDutyRoutingKey == "" && cfg.TelegramBotToken == "" && cfg.WebhookURL == "" && cfg.SNSTopicARN == "" {
+		problemf("no notifier configured, set at least one of DISCORD_WEBHOOK_URL, SLACK_WEBHOOK_URL, PAGERDUTY_ROUTING_KEY, TELEGRAM_BOT_TOKEN, WEBHOOK_URL or SNS_TOPIC_ARN")
+	}
+	if cfg.SQLitePath != "" && !sqliteAvailable() {
+		problemf("SQLITE_PATH requires a binary built with -tags sqlite, which links in the SQLite driver")
+	}
+	if cfg.SNSTopicARN != "" {
+		// The AWS credentials are read from the environment, the shared
+		// files or the instance role, like the AWS CLI does.
+		region, err := snsRegion(cfg.SNSTopicARN)
+		if err != nil {
+			problemf("SNS_TOPIC_ARN: %v", err)
+		} else if cfg.SNSConfig, err = awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(region)); err != nil {
+			problemf("loading the AWS configuration for SNS_TOPIC_ARN: %v", err)
+		}
+	}
+	if (cfg.TelegramBotToken == "") != (cfg.TelegramChatID == "") {
+		problemf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set together")
+	}
+	for _, name := range []string{"DISCORD_WEBHOOK_URL", "SLACK_WEBHOOK_URL", "WEBHOOK_URL", "OTEL_EXPORTER_OTLP_ENDPOINT"} {
+		if err := validateURL(s.Get(name)); err != nil {
+			problemf("%s: %v", name, err)
+		}
+	}
+
+	if cfg.WebhookURL != "" {
+		var err error
+		if text := s.Get("WEBHOOK_PAYLOAD_TEMPLATE"); text == "" {
+			problemf("WEBHOOK_URL requires WEBHOOK_PAYLOAD_TEMPLATE")
+		} else if cfg.WebhookTemplate, err = parseWebhookTemplate(text); err != nil {
+			problemf("WEBHOOK_PAYLOAD_TEMPLATE: %v", err)
+		}
+		if cfg.WebhookHeaders, err = parseHeaders(s.Get("WEBHOOK_HEADERS")); err != nil {
+			problemf("WEBHOOK_HEADERS: %v", err)
+		}
+	}
+
+	if err := validateMention(cfg.AlertMention); err != nil {
+		problemf("ALERT_MENTION: %v", err)
+	}
+	cfg.MinSeverities = make(map[string]Severity)
+	for _, backend := range notifierBackends {
+		name := strings.ToUpper(backend) + "_MIN_SEVERITY"
+		if v := s.Get(name); v != "" {
+			severity, err := parseSeverity(strings.ToLower(v))
+			if err != nil {
+				problemf("%s: %v", name, err)
+				continue
+			}
+			cfg.MinSeverities[backend] = severity
+		}
+	}
+	if cfg.EnvTag != "" && !envTagPattern.MatchString(cfg.EnvTag) {
+		problemf("ENV_TAG must be letters, digits, '.', '_' or '-', got %q", cfg.EnvTag)
+	}
+
+	cfg.DiscordSeverityWebhookURLs = make(map[Severity]string)
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical} {
+		name := "DISCORD_WEBHOOK_URL_" + strings.ToUpper(severity.String())
+		v := s.Get(name)
+		if v == "" {
+			continue
+		}
+		if err := validateURL(v); err != nil {
+			problemf("%s: %v", name, err)
+		}
+		if cfg.DiscordWebhookURL == "" {
+			problemf("%s requires DISCORD_WEBHOOK_URL, used for the other severities", name)
+		}
+		cfg.DiscordSeverityWebhookURLs[severity] = v
+	}
+
+	// Credentials are not needed to replay a file or ingest pushed logs.
+	var credentialsErr error
+	if !replaying && !ingesting {
+		cfg.Credentials, cfg.CredentialsMode, credentialsErr = loadCredentials(s)
+		if credentialsErr != nil {
+			problemf("%v", credentialsErr)
+		}
+	}
+
+	if (replaying || ingesting) && s.Get("NETWORKS_CONFIG") == "" {
+		network := s.Get("PENUMBRA_NETWORK")
+		if network == "" && replaying {
+			network = "replay"
+		} else if network == "" {
+			problemf("PENUMBRA_NETWORK is unset or empty")
+		}
+		cfg.Networks = []NetworkConfig{{Name: network}}
+	} else if path := s.Get("NETWORKS_CONFIG"); path != "" {
+		networks, err := loadNetworks(path, s.Get("TM_LOG_FILTER"), s.Get("PD_LOG_FILTER"))
+		if err != nil {
+			problemf("NETWORKS_CONFIG: %v", err)
+		}
+		cfg.Networks = networks
+	} else {
+		projectID := s.Get("GCP_PROJECT_ID")
+		var projectIDs []string
+		if v := s.Get("GCP_PROJECT_IDS"); v != "" {
+			for _, id := range strings.Split(v, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					projectIDs = append(projectIDs, id)
+				}
+			}
+		}
+		network := s.Get("PENUMBRA_NETWORK")
+		if projectID == "" && len(projectIDs) == 0 {
+			problemf("GCP_PROJECT_ID and GCP_PROJECT_IDS are unset or empty")
+		}
+		if network == "" {
+			problemf("PENUMBRA_NETWORK is unset or empty")
+		}
+		cfg.Networks = []NetworkConfig{{
+			Name:                 network,
+			Cluster:              "testnet",
+			PodPrefix:            "penumbra-" + network,
+			ProjectID:            projectID,
+			ProjectIDs:           projectIDs,
+			StateFile:            s.Get("STATE_FILE"),
+			TMLogFilter:          s.Get("TM_LOG_FILTER"),
+			PDLogFilter:          s.Get("PD_LOG_FILTER"),
+			PubSubSubscription:   s.Get("PUBSUB_SUBSCRIPTION"),
+			PubSubPDSubscription: s.Get("PUBSUB_PD_SUBSCRIPTION"),
+		}}
+		if v := s.Get("EXPECTED_PODS"); v != "" {
+			for _, podName := range strings.Split(v, ",") {
+				cfg.Networks[0].ExpectedPods = append(cfg.Networks[0].ExpectedPods, strings.TrimSpace(podName))
+			}
+		}
+	}
+
+	var err error
+	cfg.ComparedPods.Include, err = parsePodGlobs(s.Get("INCLUDE_PODS"))
+	if err != nil {
+		problemf("INCLUDE_PODS: %v", err)
+	}
+	cfg.ComparedPods.Exclude, err = parsePodGlobs(s.Get("EXCLUDE_PODS"))
+	if err != nil {
+		problemf("EXCLUDE_PODS: %v", err)
+	}
+
+	if v := strings.TrimSpace(s.Get("REFERENCE_POD")); v != "" {
+		if s.Get("NETWORKS_CONFIG") != "" {
+			problemf("REFERENCE_POD applies to a single network, set reference_pod per network in NETWORKS_CONFIG")
+		} else if len(cfg.Networks) == 1 {
+			cfg.Networks[0].ReferencePod = v
+		}
+	}
+	for _, network := range cfg.Networks {
+		if network.ReferencePod != "" && !cfg.ComparedPods.Compared(network.ReferencePod) {
+			problemf("network %s: the reference pod %s is left out of the comparison by INCLUDE_PODS or EXCLUDE_PODS", network.Name, network.ReferencePod)
+		}
+	}
+
+	for _, name := range []string{"TM_LOG_FILTER", "PD_LOG_FILTER"} {
+		if v, ok := s.Lookup(name); ok && strings.TrimSpace(v) == "" {
+			problemf("%s is set but blank", name)
+		}
+	}
+
+	seconds, err := envInt(s, "READY_STALENESS_SECONDS", 300)
+	if err != nil {
+		problemf("%v", err)
+	}
+	cfg.ReadyStaleness = time.Duration(seconds) * time.Second
+
+	cfg.CacheWindow, err = envInt(s, "CACHE_WINDOW", 1000)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.NotifyRatePerMin, err = envInt(s, "NOTIFY_RATE_PER_MIN", 20)
+	if err != nil {
+		problemf("%v", err)
+	}
+	cfg.NotifyBurst, err = envInt(s, "NOTIFY_BURST", 5)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.LogBufferSize, err = envInt(s, "LOG_BUFFER_SIZE", 1000)
+	if err != nil {
+		problemf("%v", err)
+	}
+	if v := s.Get("LOG_BUFFER_DROP_ERRORS"); v != "" {
+		cfg.LogBufferDropErrors, err = strconv.ParseBool(v)
+		if err != nil {
+			problemf("LOG_BUFFER_DROP_ERRORS must be a boolean, got %q", v)
+		}
+	}
+
+	cfg.NotifyWorkers, err = envInt(s, "NOTIFY_WORKERS", 4)
+	if err != nil {
+		problemf("%v", err)
+	}
+	cfg.NotifyBreakerFailures, err = envInt(s, "NOTIFY_BREAKER_FAILURES", 5)
+	if err != nil {
+		problemf("%v", err)
+	}
+	cfg.NotifyBreakerCooldown, err = envDuration(s, "NOTIFY_BREAKER_COOLDOWN", time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.NotifyBreakerCooldown == 0 {
+		problemf("NOTIFY_BREAKER_COOLDOWN must be positive")
+	}
+
+	cfg.MilestoneInterval = 1000
+	if v := s.Get("MILESTONE_INTERVAL"); v != "" {
+		cfg.MilestoneInterval, err = strconv.Atoi(v)
+		if err != nil || cfg.MilestoneInterval < 0 {
+			problemf("MILESTONE_INTERVAL must be a non-negative integer, got %q", v)
+		}
+	}
+
+	cfg.MilestoneHeights = make(map[int64]bool)
+	if v := s.Get("MILESTONE_HEIGHTS"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			height, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil || height <= 0 {
+				problemf("MILESTONE_HEIGHTS must be a comma-separated list of heights, got %q", field)
+				continue
+			}
+			cfg.MilestoneHeights[height] = true
+		}
+	}
+
+	cfg.LivenessTimeout, err = envDuration(s, "LIVENESS_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.ChainStallTimeout, err = envDuration(s, "CHAIN_STALL_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.StreamConfig = DefaultStreamConfig()
+	cfg.StreamConfig.StartupJitter, err = envDuration(s, "STREAM_STARTUP_JITTER", cfg.StreamConfig.StartupJitter)
+	if err != nil {
+		problemf("%v", err)
+	}
+	if v := s.Get("STREAM_RECONNECT_JITTER"); v != "" {
+		cfg.StreamConfig.Jitter, err = strconv.ParseFloat(v, 64)
+		if err != nil || cfg.StreamConfig.Jitter < 0 || cfg.StreamConfig.Jitter > 1 {
+			problemf("STREAM_RECONNECT_JITTER must be a fraction between 0 and 1, got %q", v)
+		}
+	}
+
+	if v := s.Get("PROCESS_RATE_PER_SEC"); v != "" {
+		cfg.ProcessRatePerSec, err = strconv.Atoi(v)
+		if err != nil || cfg.ProcessRatePerSec < 0 {
+			problemf("PROCESS_RATE_PER_SEC must be a non-negative integer, got %q", v)
+		}
+	}
+
+	cfg.StartupTimeout, err = envDuration(s, "STARTUP_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	if v := s.Get("MAX_TXS_ALERT"); v != "" {
+		cfg.MaxTxsAlert, err = strconv.Atoi(v)
+		if err != nil || cfg.MaxTxsAlert < 0 {
+			problemf("MAX_TXS_ALERT must be a non-negative integer, got %q", v)
+		}
+	}
+
+	cfg.MismatchConfirmations, err = envInt(s, "MISMATCH_CONFIRMATIONS", 1)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.QuorumSize, err = envInt(s, "QUORUM_SIZE", 2)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.QuorumSize < 2 {
+		problemf("QUORUM_SIZE must be at least 2, got %d", cfg.QuorumSize)
+	}
+
+	cfg.EscalateAfter, err = envDuration(s, "ESCALATE_AFTER", 0)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.MismatchConfirmWindow, err = envDuration(s, "MISMATCH_CONFIRM_WINDOW", 5*time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.MismatchConfirmWindow == 0 {
+		problemf("MISMATCH_CONFIRM_WINDOW must be positive")
+	}
+
+	if v := s.Get("REGRESSION_TOLERANCE"); v != "" {
+		cfg.RegressionTolerance, err = strconv.Atoi(v)
+		if err != nil || cfg.RegressionTolerance < 0 {
+			problemf("REGRESSION_TOLERANCE must be a non-negative integer, got %q", v)
+		}
+	}
+
+	if v := s.Get("MAX_LAG_BLOCKS"); v != "" {
+		cfg.MaxLagBlocks, err = strconv.Atoi(v)
+		if err != nil || cfg.MaxLagBlocks < 0 {
+			problemf("MAX_LAG_BLOCKS must be a non-negative integer, got %q", v)
+		}
+	}
+
+	if v := s.Get("EMPTY_BLOCK_STREAK"); v != "" {
+		cfg.EmptyBlockStreak, err = strconv.Atoi(v)
+		if err != nil || cfg.EmptyBlockStreak < 0 {
+			problemf("EMPTY_BLOCK_STREAK must be a non-negative integer, got %q", v)
+		}
+	}
+
+	cfg.DedupWindow, err = envDuration(s, "DEDUP_WINDOW", 5*time.Minute)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.NotifyTimeout, err = envDuration(s, "NOTIFY_TIMEOUT", 10*time.Second)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.NotifyTimeout == 0 {
+		problemf("NOTIFY_TIMEOUT must be positive")
+	}
+
+	if path := s.Get("NOTIFY_CA_BUNDLE"); path != "" {
+		cfg.NotifyRootCAs, err = loadCABundle(path)
+		if err != nil {
+			problemf("NOTIFY_CA_BUNDLE: %v", err)
+		}
+	}
+	if v := s.Get("NOTIFY_INSECURE_SKIP_VERIFY"); v != "" {
+		cfg.NotifyInsecureSkipVerify, err = strconv.ParseBool(v)
+		if err != nil {
+			problemf("NOTIFY_INSECURE_SKIP_VERIFY must be a boolean, got %q", v)
+		}
+	}
+
+	cfg.MaxErrorChars, err = envInt(s, "MAX_ERROR_CHARS", defaultMaxErrorChars)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.SummaryInterval, err = envDuration(s, "SUMMARY_INTERVAL", 0)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.EventBufferSize, err = envInt(s, "EVENT_BUFFER_SIZE", 500)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.KafkaTopic = s.Get("KAFKA_TOPIC")
+	if v := s.Get("KAFKA_BROKERS"); v != "" {
+		cfg.KafkaBrokers, err = parseBrokers(v)
+		if err != nil {
+			problemf("KAFKA_BROKERS: %v", err)
+		}
+	}
+	if (len(cfg.KafkaBrokers) == 0) != (cfg.KafkaTopic == "") {
+		problemf("KAFKA_BROKERS and KAFKA_TOPIC must be set together")
+	}
+	cfg.KafkaBufferSize, err = envInt(s, "KAFKA_BUFFER_SIZE", 1000)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.ExplorerURL = s.Get("EXPLORER_URL")
+	if cfg.ExplorerURL != "" {
+		if !strings.Contains(cfg.ExplorerURL, "{height}") {
+			problemf("EXPLORER_URL must contain a {height} placeholder, got %q", cfg.ExplorerURL)
+		} else if err := validateURL(strings.NewReplacer("{height}", "1", "{network}", "network").Replace(cfg.ExplorerURL)); err != nil {
+			problemf("EXPLORER_URL: %v", err)
+		}
+	}
+	cfg.ExplorerRootField = "root"
+	if v := s.Get("EXPLORER_ROOT_FIELD"); v != "" {
+		cfg.ExplorerRootField = v
+	}
+	cfg.ExplorerRatePerMin, err = envInt(s, "EXPLORER_RATE_PER_MIN", 30)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.AuditMaxSizeMB, err = envInt(s, "AUDIT_MAX_SIZE_MB", 100)
+	if err != nil {
+		problemf("%v", err)
+	}
+
+	cfg.MaintenanceWindow, err = envDuration(s, "MAINTENANCE_WINDOW", time.Hour)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.MaintenanceWindow == 0 {
+		problemf("MAINTENANCE_WINDOW must be positive")
+	}
+
+	if v := s.Get("UPLOAD_FULL_ERRORS"); v != "" {
+		cfg.UploadFullErrors, err = strconv.ParseBool(v)
+		if err != nil {
+			problemf("UPLOAD_FULL_ERRORS must be a boolean, got %q", v)
+		}
+	}
+
+	if v := s.Get("BATCH_ALERTS"); v != "" {
+		cfg.BatchAlerts, err = strconv.ParseBool(v)
+		if err != nil {
+			problemf("BATCH_ALERTS must be a boolean, got %q", v)
+		}
+	}
+
+	cfg.BatchInterval, err = envDuration(s, "BATCH_INTERVAL", 2*time.Second)
+	if err != nil {
+		problemf("%v", err)
+	} else if cfg.BatchInterval == 0 {
+		problemf("BATCH_INTERVAL must be positive")
+	}
+
+	if v := s.Get("DRY_RUN"); v != "" {
+		cfg.DryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			problemf("DRY_RUN must be a boolean, got %q", v)
+		}
+	}
+
+	cfg.CommitLogPatterns = DefaultCommitLogPatterns()
+	if pattern := s.Get("COMMIT_LOG_PATTERN"); pattern != "" {
+		re, err := compileCommitLogPattern(pattern)
+		if err != nil {
+			problemf("COMMIT_LOG_PATTERN: %v", err)
+		}
+		cfg.CommitLogPatterns = []CommitLogPattern{{Name: "custom", Re: re}}
+	}
+
+	pattern := s.Get("PD_ERROR_HEIGHT_PATTERN")
+	if pattern == "" {
+		pattern = defaultPDErrorHeightPattern
+	}
+	cfg.PDErrorHeightPattern, err = compilePDErrorHeightPattern(pattern)
+	if err != nil {
+		problemf("PD_ERROR_HEIGHT_PATTERN: %v", err)
+	}
+
+	if path := s.Get("PD_SEVERITY_RULES"); path != "" {
+		cfg.PDSeverityRules, err = loadSeverityRules(path)
+		if err != nil {
+			problemf("PD_SEVERITY_RULES: %v", err)
+		}
+	}
+
+	cfg.formatter, err = newMessageFormatter(s.Get("ALERT_TEMPLATES_DIR"), cfg.EnvTag)
+	if err != nil {
+		problemf("ALERT_TEMPLATES_DIR: %v", err)
+	}
+
+	cfg.PodNameLabel = s.Get("POD_NAME_LABEL")
+
+	cfg.PayloadField = defaultPayloadField
+	if v := s.Get("LOG_PAYLOAD_FIELD"); v != "" {
+		cfg.PayloadField = v
+	}
+
+	if len(problems) > 0 {
+		err := fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+		if credentialsErr != nil {
+			// Told apart from the other problems, see ExitCode.
+			return nil, credentialsError(err)
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envInt reads a positive integer setting, falling back to `def` when it is
+// unset.
+func envInt(s Settings, name string, def int) (int, error) {
+	v := s.Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return def, fmt.Errorf("%s must be a positive integer, got %q", name, v)
+	}
+	return n, nil
+}
+
+// envDuration reads a non-negative duration setting (e.g. "5m"), falling
+// back to `def` when it is unset.
+func envDuration(s Settings, name string, def time.Duration) (time.Duration, error) {
+	v := s.Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return def, fmt.Errorf("%s must be a non-negative duration such as 5m, got %q", name, v)
+	}
+	return d, nil
+}
+
+// discordMention loosely matches a Discord user (<@id>, <@!id>), role
+// (<@&id>) or channel-wide (@here, @everyone) mention.
+var discordMention = regexp.MustCompile(`^(<@[!&]?\d+>|@here|@everyone)$`)
+
+var envTagPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// validateMention checks that `mention` is a space-separated list of Discord
+// mentions.
+func validateMention(mention string) error {
+	for _, field := range strings.Fields(mention) {
+		if !discordMention.MatchString(field) {
+			return fmt.Errorf("%q is not a Discord mention such as <@&role-id> or <@user-id>", field)
+		}
+	}
+	return nil
+}
+
+// validateURL checks that a non-empty `raw` is an absolute http(s) URL.
+func validateURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("not a valid URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+	return nil
+}
+
+// minSeverity returns the least severe alerts sent to `backend`: the
+// configured one, else critical for PagerDuty, which pages, and info for the
+// others.
+func (c *Config) minSeverity(backend string) Severity {
+	if severity, ok := c.MinSeverities[backend]; ok {
+		return severity
+	}
+	if backend == "pagerduty" {
+		return SeverityCritical
+	}
+	return SeverityInfo
+}
@@ -0,0 +1,340 @@
+package monitor
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// mapSettings are settings read from a map, unset if missing.
+type mapSettings map[string]string
+
+func (s mapSettings) Lookup(name string) (string, bool) {
+	v, ok := s[name]
+	return v, ok
+}
+
+func (s mapSettings) Get(name string) string {
+	return s[name]
+}
+
+// replaySettings returns the settings of a replay alerting on Discord,
+// along with `extra`.
+func replaySettings(extra map[string]string) mapSettings {
+	s := mapSettings{"DISCORD_WEBHOOK_URL": "https://discord.example/webhook"}
+	for name, v := range extra {
+		s[name] = v
+	}
+	return s
+}
+
+func TestLoadConfigProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings mapSettings
+		// want are the problems reported, all at once.
+		want []string
+	}{
+		{
+			name: "valid",
+			settings: mapSettings{
+				"DISCORD_WEBHOOK_URL": "https://discord.example/webhook",
+				"GCP_PROJECT_ID":      "project",
+				"PENUMBRA_NETWORK":    "testnet",
+			},
+		},
+		{
+			name:     "nothing set",
+			settings: mapSettings{},
+			want: []string{
+				"no notifier configured",
+				"GCP_PROJECT_ID and GCP_PROJECT_IDS are unset or empty",
+				"PENUMBRA_NETWORK is unset or empty",
+			},
+		},
+		{
+			name:     "GCP settings missing",
+			settings: mapSettings{"DISCORD_WEBHOOK_URL": "https://discord.example/webhook"},
+			want: []string{
+				"GCP_PROJECT_ID and GCP_PROJECT_IDS are unset or empty",
+				"PENUMBRA_NETWORK is unset or empty",
+			},
+		},
+		{
+			name: "invalid webhook URL and network missing",
+			settings: mapSettings{
+				"DISCORD_WEBHOOK_URL": "discord.example/webhook",
+				"GCP_PROJECT_ID":      "project",
+			},
+			want: []string{
+				"DISCORD_WEBHOOK_URL:",
+				"PENUMBRA_NETWORK is unset or empty",
+			},
+		},
+		{
+			name: "malformed credentials",
+			settings: mapSettings{
+				"SLACK_WEBHOOK_URL": "https://slack.example/webhook",
+				"GCP_PROJECT_ID":    "project",
+				"PENUMBRA_NETWORK":  "testnet",
+				"GCP_CREDENTIALS":   `{"type": "service_account"`,
+			},
+			want: []string{"GCP_CREDENTIALS is not well-formed JSON"},
+		},
+		{
+			name: "invalid numbers",
+			settings: mapSettings{
+				"DISCORD_WEBHOOK_URL": "https://discord.example/webhook",
+				"GCP_PROJECT_ID":      "project",
+				"PENUMBRA_NETWORK":    "testnet",
+				"QUORUM_SIZE":         "1",
+				"MAX_LAG_BLOCKS":      "-3",
+			},
+			want: []string{
+				"QUORUM_SIZE must be at least 2",
+				"MAX_LAG_BLOCKS must be a non-negative integer",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(tt.settings, "")
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("LoadConfig() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("LoadConfig() succeeded, want %q", tt.want)
+			}
+			if got := strings.Count(err.Error(), "\n  - "); got != len(tt.want) {
+				t.Errorf("LoadConfig() reported %d problems, want %d: %v", got, len(tt.want), err)
+			}
+			for _, problem := range tt.want {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("LoadConfig() = %v, want it to report %q", err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigSQLitePath(t *testing.T) {
+	_, err := LoadConfig(replaySettings(map[string]string{"SQLITE_PATH": "audit.db"}), "replay.log")
+	rejected := err != nil && strings.Contains(err.Error(), "SQLITE_PATH requires a binary built with -tags sqlite")
+	if rejected == sqliteAvailable() {
+		t.Errorf("LoadConfig() = %v with the SQLite driver linked in: %v", err, sqliteAvailable())
+	}
+}
+
+func TestLoadConfigMinSeverities(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     map[string]Severity
+		wantErr  bool
+	}{
+		{"unset", nil, map[string]Severity{}, false},
+		{"per backend", map[string]string{"SLACK_MIN_SEVERITY": "info", "PAGERDUTY_MIN_SEVERITY": "error"}, map[string]Severity{"slack": SeverityInfo, "pagerduty": SeverityError}, false},
+		{"uppercase", map[string]string{"WEBHOOK_MIN_SEVERITY": "WARNING"}, map[string]Severity{"webhook": SeverityWarning}, false},
+		{"unknown severity", map[string]string{"SLACK_MIN_SEVERITY": "urgent"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(replaySettings(tt.settings), "replay.log")
+			if tt.wantErr {
+				if err == nil {
+					t.Error("LoadConfig() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(cfg.MinSeverities, tt.want) {
+				t.Errorf("MinSeverities = %v, want %v", cfg.MinSeverities, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSeverityWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings mapSettings
+		want     map[Severity]string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{"unset", replaySettings(nil), map[Severity]string{}, ""},
+		{
+			name: "info and critical",
+			settings: replaySettings(map[string]string{
+				"DISCORD_WEBHOOK_URL_INFO":     "https://discord.example/info",
+				"DISCORD_WEBHOOK_URL_CRITICAL": "https://discord.example/critical",
+			}),
+			want: map[Severity]string{SeverityInfo: "https://discord.example/info", SeverityCritical: "https://discord.example/critical"},
+		},
+		{"invalid URL", replaySettings(map[string]string{"DISCORD_WEBHOOK_URL_ERROR": "not a url"}), nil, "DISCORD_WEBHOOK_URL_ERROR"},
+		{"without a default", mapSettings{"DISCORD_WEBHOOK_URL_INFO": "https://discord.example/info"}, nil, "DISCORD_WEBHOOK_URL_INFO requires DISCORD_WEBHOOK_URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(tt.settings, "replay.log")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("LoadConfig() = %v, want an error mentioning %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(cfg.DiscordSeverityWebhookURLs, tt.want) {
+				t.Errorf("DiscordSeverityWebhookURLs = %v, want %v", cfg.DiscordSeverityWebhookURLs, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigQuorumSize(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    int
+		wantErr bool
+	}{
+		{"", 2, false},
+		{"3", 3, false},
+		{"1", 0, true},
+		{"three", 0, true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadConfig(replaySettings(map[string]string{"QUORUM_SIZE": tt.v}), "replay.log")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QUORUM_SIZE=%q: LoadConfig() = %v, want error: %v", tt.v, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.QuorumSize != tt.want {
+			t.Errorf("QUORUM_SIZE=%q: QuorumSize = %d, want %d", tt.v, cfg.QuorumSize, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigReferencePod(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{name: "unset"},
+		{name: "set", settings: map[string]string{"REFERENCE_POD": " ref "}, want: "ref"},
+		{name: "excluded", settings: map[string]string{"REFERENCE_POD": "ref", "EXCLUDE_PODS": "re*"}, err: "left out of the comparison"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(replaySettings(tt.settings), "replay.log")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadConfig() = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Networks[0].ReferencePod; got != tt.want {
+				t.Errorf("ReferencePod = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvTag(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{" prod ", "prod", false},
+		{"eu-west.staging_2", "eu-west.staging_2", false},
+		{"prod env", "", true},
+		{"[prod]", "", true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadConfig(replaySettings(map[string]string{"ENV_TAG": tt.v}), "replay.log")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ENV_TAG=%q: LoadConfig() = %v, want error: %v", tt.v, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.EnvTag != tt.want {
+			t.Errorf("ENV_TAG=%q: EnvTag = %q, want %q", tt.v, cfg.EnvTag, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigLogBuffer(t *testing.T) {
+	tests := []struct {
+		settings map[string]string
+		wantSize int
+		wantDrop bool
+		wantErr  bool
+	}{
+		{nil, 1000, false, false},
+		{map[string]string{"LOG_BUFFER_SIZE": "50", "LOG_BUFFER_DROP_ERRORS": "true"}, 50, true, false},
+		{map[string]string{"LOG_BUFFER_SIZE": "many"}, 0, false, true},
+		{map[string]string{"LOG_BUFFER_DROP_ERRORS": "sometimes"}, 0, false, true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadConfig(replaySettings(tt.settings), "replay.log")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: LoadConfig() = %v, want error: %v", tt.settings, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (cfg.LogBufferSize != tt.wantSize || cfg.LogBufferDropErrors != tt.wantDrop) {
+			t.Errorf("%v: LogBufferSize, LogBufferDropErrors = %d, %v, want %d, %v", tt.settings, cfg.LogBufferSize, cfg.LogBufferDropErrors, tt.wantSize, tt.wantDrop)
+		}
+	}
+}
+
+func TestLoadConfigExplorer(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  map[string]string
+		wantField string
+		wantRate  int
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{name: "unset", wantField: "root", wantRate: 30},
+		{
+			name:      "set",
+			settings:  map[string]string{"EXPLORER_URL": "https://explorer.example/{network}/blocks/{height}", "EXPLORER_ROOT_FIELD": "result.app_hash", "EXPLORER_RATE_PER_MIN": "6"},
+			wantField: "result.app_hash",
+			wantRate:  6,
+		},
+		{name: "no height", settings: map[string]string{"EXPLORER_URL": "https://explorer.example/blocks/latest"}, err: "{height} placeholder"},
+		{name: "invalid URL", settings: map[string]string{"EXPLORER_URL": "explorer/{height}"}, err: "EXPLORER_URL"},
+		{name: "no rate", settings: map[string]string{"EXPLORER_RATE_PER_MIN": "0"}, err: "EXPLORER_RATE_PER_MIN must be a positive integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(replaySettings(tt.settings), "replay.log")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadConfig() = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ExplorerRootField != tt.wantField || cfg.ExplorerRatePerMin != tt.wantRate {
+				t.Errorf("ExplorerRootField, ExplorerRatePerMin = %q, %d, want %q, %d", cfg.ExplorerRootField, cfg.ExplorerRatePerMin, tt.wantField, tt.wantRate)
+			}
+		})
+	}
+}
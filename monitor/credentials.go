@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	logging "cloud.google.com/go/logging/apiv2"
+	"google.golang.org/api/option"
+)
+
+// Ways the logging client authenticates, by order of precedence.
+const (
+	credentialsInline = "GCP_CREDENTIALS"
+	credentialsFile   = "GOOGLE_APPLICATION_CREDENTIALS"
+	credentialsADC    = "application default credentials"
+)
+
+// credentialsOption selects the credentials of the logging client: the
+// service account key given inline, else the key file at `path`, else the
+// Application Default Credentials, e.g. the metadata server on GCP, for which
+// no option is needed. It also returns the mode selected.
+func credentialsOption(inlineJSON, path string) (option.ClientOption, string) {
+	switch {
+	case inlineJSON != "":
+		return option.WithCredentialsJSON([]byte(inlineJSON)), credentialsInline
+	case path != "":
+		return option.WithCredentialsFile(path), credentialsFile
+	default:
+		return nil, credentialsADC
+	}
+}
+
+// loadCredentials reads the credentials from `s`, checking that the inline
+// key is JSON and that the key file exists. Its errors wrap errCredentials.
+func loadCredentials(s Settings) (option.ClientOption, string, error) {
+	inlineJSON, path := s.Get("GCP_CREDENTIALS"), s.Get("GOOGLE_APPLICATION_CREDENTIALS")
+	credentials, mode := credentialsOption(inlineJSON, path)
+	switch mode {
+	case credentialsInline:
+		if !json.Valid([]byte(inlineJSON)) {
+			return nil, mode, fmt.Errorf("%w: GCP_CREDENTIALS is not well-formed JSON", errCredentials)
+		}
+	case credentialsFile:
+		if _, err := os.Stat(path); err != nil {
+			return nil, mode, fmt.Errorf("%w: GOOGLE_APPLICATION_CREDENTIALS: %v", errCredentials, err)
+		}
+	}
+	return credentials, mode, nil
+}
+
+// newLoggingClient creates a logging client authenticated with
+// `credentials`, or the Application Default Credentials if nil.
+func newLoggingClient(ctx context.Context, credentials option.ClientOption) (*logging.Client, error) {
+	var opts []option.ClientOption
+	if credentials != nil {
+		opts = append(opts, credentials)
+	}
+	client, err := logging.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errCredentials, err)
+	}
+	return client, nil
+}
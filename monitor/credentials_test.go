@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyFile, []byte(`{"type": "service_account"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		settings mapSettings
+		wantMode string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{
+			name:     "inline",
+			settings: mapSettings{"GCP_CREDENTIALS": `{"type": "service_account"}`},
+			wantMode: credentialsInline,
+		},
+		{
+			name:     "file",
+			settings: mapSettings{"GOOGLE_APPLICATION_CREDENTIALS": keyFile},
+			wantMode: credentialsFile,
+		},
+		{
+			name:     "application default credentials",
+			settings: mapSettings{},
+			wantMode: credentialsADC,
+		},
+		{
+			name:     "inline over file",
+			settings: mapSettings{"GCP_CREDENTIALS": `{"type": "service_account"}`, "GOOGLE_APPLICATION_CREDENTIALS": filepath.Join(t.TempDir(), "missing.json")},
+			wantMode: credentialsInline,
+		},
+		{
+			name:     "malformed inline",
+			settings: mapSettings{"GCP_CREDENTIALS": `{"type": "service_account"`},
+			wantMode: credentialsInline,
+			err:      "GCP_CREDENTIALS is not well-formed JSON",
+		},
+		{
+			name:     "missing file",
+			settings: mapSettings{"GOOGLE_APPLICATION_CREDENTIALS": filepath.Join(t.TempDir(), "missing.json")},
+			wantMode: credentialsFile,
+			err:      "GOOGLE_APPLICATION_CREDENTIALS:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentials, mode, err := loadCredentials(tt.settings)
+			if mode != tt.wantMode {
+				t.Errorf("loadCredentials() mode = %q, want %q", mode, tt.wantMode)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("loadCredentials() = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadCredentials() = %v, want nil", err)
+			}
+			// Only the Application Default Credentials need no option.
+			if (credentials == nil) != (tt.wantMode == credentialsADC) {
+				t.Errorf("loadCredentials() option = %v in mode %q", credentials, mode)
+			}
+		})
+	}
+}
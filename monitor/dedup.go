@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// deduplicator suppresses repeated (pod, payload) pairs seen within a window.
+type deduplicator struct {
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*dedupEntry
+	// evicted holds entries dropped to honour maxEntries, until they are
+	// returned by Expire.
+	evicted []dedupEntry
+}
+
+// dedupEntry tracks the occurrences of one payload within its window.
+type dedupEntry struct {
+	PodName    string
+	Payload    string
+	FirstSeen  time.Time
+	Suppressed int
+}
+
+func newDeduplicator(window time.Duration, maxEntries int) *deduplicator {
+	return &deduplicator{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]*dedupEntry),
+	}
+}
+
+func dedupKey(podName, payload string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(podName + "\x00" + payload))
+}
+
+// Seen records an occurrence of `payload` from `podName` and reports whether
+// it is the first one within the window, i.e. whether it should be forwarded.
+func (d *deduplicator) Seen(podName, payload string, now time.Time) bool {
+	if d.window <= 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := dedupKey(podName, payload)
+	if entry, ok := d.entries[key]; ok && now.Sub(entry.FirstSeen) < d.window {
+		entry.Suppressed++
+		return false
+	} else if ok {
+		d.evicted = append(d.evicted, *entry)
+	}
+
+	if len(d.entries) >= d.maxEntries {
+		d.evictOldest()
+	}
+	d.entries[key] = &dedupEntry{
+		PodName:   podName,
+		Payload:   payload,
+		FirstSeen: now,
+	}
+	return true
+}
+
+// Expire drops the entries whose window has closed and returns those that
+// suppressed at least one duplicate.
+func (d *deduplicator) Expire(now time.Time) []dedupEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var expired []dedupEntry
+	for _, entry := range d.evicted {
+		if entry.Suppressed > 0 {
+			expired = append(expired, entry)
+		}
+	}
+	d.evicted = nil
+
+	for key, entry := range d.entries {
+		if now.Sub(entry.FirstSeen) < d.window {
+			continue
+		}
+		if entry.Suppressed > 0 {
+			expired = append(expired, *entry)
+		}
+		delete(d.entries, key)
+	}
+	return expired
+}
+
+func (d *deduplicator) evictOldest() {
+	var oldestKey [sha256.Size]byte
+	var oldest *dedupEntry
+	for key, entry := range d.entries {
+		if oldest == nil || entry.FirstSeen.Before(oldest.FirstSeen) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if oldest != nil {
+		d.evicted = append(d.evicted, *oldest)
+		delete(d.entries, oldestKey)
+	}
+}
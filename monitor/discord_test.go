@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// discordReply is what the fake Discord webhook answers to a request.
+type discordReply struct {
+	status     int
+	retryAfter string
+}
+
+// scriptedServer answers the requests with `replies` in turn, the last one
+// again once they are exhausted, counting the requests.
+func scriptedServer(t *testing.T, replies ...discordReply) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		reply := replies[min(n, len(replies))-1]
+		if reply.retryAfter != "" {
+			w.Header().Set("Retry-After", reply.retryAfter)
+		}
+		w.WriteHeader(reply.status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestDiscordNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []discordReply
+		wantRequests int32
+		wantErr      bool
+		// wantDelay is the least time the deliveries should take.
+		wantDelay time.Duration
+	}{
+		{"delivered", []discordReply{{status: http.StatusNoContent}}, 1, false, 0},
+		{"server errors then delivered", []discordReply{{status: 500}, {status: 500}, {status: 200}}, 3, false, 0},
+		{"rate limited", []discordReply{{status: 429, retryAfter: "0.1"}, {status: 204}}, 2, false, 100 * time.Millisecond},
+		{"server errors exhaust the retries", []discordReply{{status: 502}}, 4, true, 0},
+		{"client error not retried", []discordReply{{status: 400}}, 1, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := scriptedServer(t, tt.replies...)
+			discord := newDiscordNotifier(server.URL, nil, "", server.Client())
+			discord.RetryBackoff = time.Millisecond
+			discord.metrics = newMetrics()
+
+			start := time.Now()
+			err := discord.Notify(context.Background(), Message{Title: "title", Body: "body"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify() = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("made %d attempts, want %d", got, tt.wantRequests)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantDelay {
+				t.Errorf("delivered after %s, want at least %s", elapsed, tt.wantDelay)
+			}
+			wantFailures := 0.0
+			if tt.wantErr {
+				wantFailures = 1
+			}
+			if got := counterValue(t, discord.metrics.discordFailures); got != wantFailures {
+				t.Errorf("counted %v failures, want %v", got, wantFailures)
+			}
+		})
+	}
+}
+
+func TestDiscordNotifierGivesUpOnCancel(t *testing.T) {
+	server, requests := scriptedServer(t, discordReply{status: 429, retryAfter: "60"})
+	discord := newDiscordNotifier(server.URL, nil, "", server.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := discord.Notify(ctx, Message{Body: "body"}); err == nil {
+		t.Error("Notify() = nil once cancelled while waiting for a retry, want an error")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("made %d attempts, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"0.25", 250 * time.Millisecond},
+		{"", 0},
+		{"-1", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordNotifierSeverityRouting(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		// want is the webhook posted to.
+		want string
+	}{
+		{SeverityInfo, "info"},
+		{SeverityWarning, "default"},
+		{SeverityError, "default"},
+		{SeverityCritical, "critical"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.severity.String(), func(t *testing.T) {
+			servers := make(map[string]*atomic.Int32)
+			urls := make(map[string]string)
+			for _, name := range []string{"default", "info", "critical"} {
+				server, requests := countingServer(t, http.StatusNoContent)
+				servers[name], urls[name] = requests, server.URL
+			}
+			discord := newDiscordNotifier(urls["default"], map[Severity]string{
+				SeverityInfo:     urls["info"],
+				SeverityCritical: urls["critical"],
+			}, "", http.DefaultClient)
+
+			if err := discord.Notify(context.Background(), Message{Severity: tt.severity, Body: "body"}); err != nil {
+				t.Fatal(err)
+			}
+			for name, requests := range servers {
+				want := int32(0)
+				if name == tt.want {
+					want = 1
+				}
+				if got := requests.Load(); got != want {
+					t.Errorf("posted %d times to the %s webhook, want %d", got, name, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package monitor
+
+// emptyBlocksAverageWeight is the weight of the latest block in the rolling
+// average of the number of transactions per block.
+const emptyBlocksAverageWeight = 0.1
+
+// emptyBlockTracker detects a network that normally has transactions and
+// starts producing empty blocks, e.g. because of a mempool or relayer
+// outage. It is fed the number of transactions of every new height.
+type emptyBlockTracker struct {
+	// streak is the number of consecutive empty blocks that triggers an
+	// alert.
+	streak int
+
+	empty    int
+	average  float64
+	seenTxs  bool
+	alerting bool
+}
+
+func newEmptyBlockTracker(streak int) *emptyBlockTracker {
+	return &emptyBlockTracker{streak: streak}
+}
+
+// Observe records the number of transactions of a new block. It reports
+// whether the empty streak just reached the threshold, and whether
+// transactions just resumed after an alert.
+func (t *emptyBlockTracker) Observe(numTxs int64) (started, resumed bool) {
+	if numTxs > 0 {
+		resumed = t.alerting
+		t.alerting = false
+		t.empty = 0
+		if t.seenTxs {
+			t.average += emptyBlocksAverageWeight * (float64(numTxs) - t.average)
+		} else {
+			t.average = float64(numTxs)
+			t.seenTxs = true
+		}
+		return false, resumed
+	}
+
+	t.empty++
+	if !t.seenTxs || t.alerting || t.empty < t.streak {
+		return false, false
+	}
+	t.alerting = true
+	return true, false
+}
+
+// Average returns the rolling average of transactions per block, as of the
+// last non-empty block.
+func (t *emptyBlockTracker) Average() float64 {
+	return t.average
+}
+
+// Empty returns the number of consecutive empty blocks.
+func (t *emptyBlockTracker) Empty() int {
+	return t.empty
+}
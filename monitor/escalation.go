@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"slices"
+	"time"
+)
+
+// mismatchEscalator tracks the mismatches that have not cleared yet, i.e.
+// the heights that were alerted before pods agreed again at a later height,
+// and tells when they have persisted long enough to be escalated.
+type mismatchEscalator struct {
+	after     time.Duration
+	active    map[int64]time.Time
+	escalated map[int64]bool
+}
+
+func newMismatchEscalator(after time.Duration) *mismatchEscalator {
+	return &mismatchEscalator{
+		after:     after,
+		active:    make(map[int64]time.Time),
+		escalated: make(map[int64]bool),
+	}
+}
+
+// Open records a mismatch alerted at `height`.
+func (e *mismatchEscalator) Open(height int64, now time.Time) {
+	if _, ok := e.active[height]; !ok {
+		e.active[height] = now
+	}
+}
+
+// Due returns the heights, in increasing order, of the mismatches that have
+// been active for longer than the escalation delay and were not escalated
+// yet. They are marked as escalated.
+func (e *mismatchEscalator) Due(now time.Time) []int64 {
+	var due []int64
+	for height, since := range e.active {
+		if !e.escalated[height] && now.Sub(since) >= e.after {
+			e.escalated[height] = true
+			due = append(due, height)
+		}
+	}
+	slices.Sort(due)
+	return due
+}
+
+// Resolve records that pods agreed at `height` and returns, in increasing
+// order, the mismatches below it, which are no longer active.
+func (e *mismatchEscalator) Resolve(height int64) []int64 {
+	var resolved []int64
+	for h := range e.active {
+		if h < height {
+			resolved = append(resolved, h)
+			delete(e.active, h)
+			delete(e.escalated, h)
+		}
+	}
+	slices.Sort(resolved)
+	return resolved
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMismatchEscalator(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	type step struct {
+		// after is the time elapsed since the first mismatch.
+		after time.Duration
+		// open, if set, is a mismatch alerted at that height.
+		open int64
+		// agreed, if set, is a height the pods agreed at.
+		agreed       int64
+		wantDue      []int64
+		wantResolved []int64
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "persistent fork",
+			steps: []step{
+				{open: 10},
+				{after: 30 * time.Second},
+				{after: time.Minute, wantDue: []int64{10}},
+				// Escalated once.
+				{after: 2 * time.Minute},
+			},
+		},
+		{
+			name: "cleared before the delay",
+			steps: []step{
+				{open: 10},
+				{after: 30 * time.Second, agreed: 11, wantResolved: []int64{10}},
+				{after: time.Minute},
+			},
+		},
+		{
+			name: "agreement below the mismatch",
+			steps: []step{
+				{open: 10},
+				{after: 30 * time.Second, agreed: 10},
+				{after: time.Minute, wantDue: []int64{10}},
+			},
+		},
+		{
+			name: "several mismatches",
+			steps: []step{
+				{open: 12},
+				{after: 30 * time.Second, open: 10},
+				{after: time.Minute, wantDue: []int64{12}},
+				{after: 90 * time.Second, wantDue: []int64{10}},
+				{after: 2 * time.Minute, agreed: 13, wantResolved: []int64{10, 12}},
+			},
+		},
+		{
+			name: "reopened after clearing",
+			steps: []step{
+				{open: 10},
+				{after: time.Minute, wantDue: []int64{10}},
+				{after: 2 * time.Minute, agreed: 11, wantResolved: []int64{10}},
+				{after: 3 * time.Minute, open: 10},
+				{after: 4 * time.Minute, wantDue: []int64{10}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newMismatchEscalator(time.Minute)
+			for i, step := range tt.steps {
+				now := start.Add(step.after)
+				if step.open != 0 {
+					e.Open(step.open, now)
+				}
+				if step.agreed != 0 {
+					if got := e.Resolve(step.agreed); !slices.Equal(got, step.wantResolved) {
+						t.Errorf("step %d: Resolve(%d) = %v, want %v", i, step.agreed, got, step.wantResolved)
+					}
+				}
+				if got := e.Due(now); !slices.Equal(got, step.wantDue) {
+					t.Errorf("step %d: Due() = %v after %s, want %v", i, got, step.after, step.wantDue)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of events kept in an eventBuffer.
+const (
+	eventKindCommit   = "commit"
+	eventKindMismatch = "mismatch"
+	eventKindPDError  = "pd_error"
+)
+
+// recentEvent is an event kept for context, e.g. the commits leading up to a
+// mismatch.
+type recentEvent struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Network string    `json:"network"`
+	PodName string    `json:"pod_name,omitempty"`
+	Height  int64     `json:"height,omitempty"`
+	Root    string    `json:"root,omitempty"`
+	NumTxs  int64     `json:"num_txs,omitempty"`
+	// Details is the pd error, or the roots of a mismatch.
+	Details string `json:"details,omitempty"`
+}
+
+// eventPublisher receives every event added to an eventBuffer, e.g. to feed
+// a data pipeline. Publish must not block.
+type eventPublisher interface {
+	Publish(event recentEvent)
+}
+
+// eventBuffer keeps the most recent events in a ring buffer shared by the
+// workers, and hands them to its publishers. A nil *eventBuffer records
+// nothing.
+type eventBuffer struct {
+	publishers []eventPublisher
+
+	mu     sync.RWMutex
+	events []recentEvent
+	// next is where the next event is written, once the buffer is full.
+	next int
+}
+
+func newEventBuffer(size int, publishers ...eventPublisher) *eventBuffer {
+	return &eventBuffer{events: make([]recentEvent, 0, size), publishers: publishers}
+}
+
+// Add records an event, overwriting the oldest one once the buffer is full.
+func (b *eventBuffer) Add(event recentEvent) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, publisher := range b.publishers {
+		publisher.Publish(event)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.events) < cap(b.events) {
+		b.events = append(b.events, event)
+		return
+	}
+	b.events[b.next] = event
+	b.next = (b.next + 1) % len(b.events)
+}
+
+// Recent returns the events matching `keep`, newest first.
+func (b *eventBuffer) Recent(keep func(recentEvent) bool) []recentEvent {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	events := make([]recentEvent, 0, len(b.events))
+	for i := len(b.events) - 1; i >= 0; i-- {
+		event := b.events[(b.next+i)%len(b.events)]
+		if keep(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Handler serves `GET /events`, newest first. `?pod=` keeps the events of a
+// pod and `?since_height=` those at or above a height.
+func (b *eventBuffer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := req.URL.Query()
+		pod := query.Get("pod")
+		var sinceHeight int64
+		if v := query.Get("since_height"); v != "" {
+			var err error
+			sinceHeight, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || sinceHeight <= 0 {
+				http.Error(w, "since_height must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events := b.Recent(func(event recentEvent) bool {
+			return (pod == "" || event.PodName == pod) && event.Height >= sinceHeight
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	})
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes, for supervisors to tell the failure modes apart.
+const (
+	// exitOK is a clean shutdown.
+	exitOK = 0
+	// exitConfig is an invalid configuration, or any failure not listed
+	// below.
+	exitConfig = 1
+	// exitAuth is a credentials or permissions failure.
+	exitAuth = 2
+	// exitMismatch is a root mismatch, with --exit-on-mismatch.
+	exitMismatch = 3
+	// exitStream is a log source that failed beyond recovery.
+	exitStream = 4
+)
+
+// ExitError is a failure ending the process with Code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// configError is an invalid configuration.
+func configError(err error) error {
+	return &ExitError{Code: exitConfig, Err: err}
+}
+
+// credentialsError is a configuration whose credentials are unusable.
+func credentialsError(err error) error {
+	return &ExitError{Code: exitAuth, Err: err}
+}
+
+// mismatchError is a root mismatch ending the process.
+func mismatchError(err error) error {
+	return &ExitError{Code: exitMismatch, Err: err}
+}
+
+// streamError is a log source that failed beyond recovery, which is a
+// credentials failure if access to the logs was refused.
+func streamError(err error) error {
+	if authFailure(err) {
+		return &ExitError{Code: exitAuth, Err: err}
+	}
+	return &ExitError{Code: exitStream, Err: err}
+}
+
+// errCredentials is wrapped by the errors of the clients that could not be
+// created from the credentials.
+var errCredentials = errors.New("invalid credentials")
+
+// authFailure reports whether `err` comes from missing credentials or from
+// an API refusing them.
+func authFailure(err error) bool {
+	if errors.Is(err, errCredentials) {
+		return true
+	}
+	if code := status.Code(err); code == codes.Unauthenticated || code == codes.PermissionDenied {
+		return true
+	}
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden)
+}
+
+// ExitCode maps an error returned by Monitor.Run, or met while setting it
+// up, to the exit code of the binary: 0 for none, 2 for a credentials
+// failure, 3 for a mismatch with ExitOnMismatch, 4 for a failed log source,
+// and 1 for anything else, e.g. an invalid configuration.
+func ExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return exitConfig
+}
@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loadConfigError returns the error of LoadConfig reading `s`.
+func loadConfigError(s Settings) error {
+	_, err := LoadConfig(s, "")
+	return err
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"clean shutdown", nil, 0},
+		{"config error", configError(errors.New("DISCORD_WEBHOOK_URL is required")), 1},
+		{"unclassified error", errors.New("boom"), 1},
+		{"invalid credentials", streamError(fmt.Errorf("NewClient error: %w", errCredentials)), 2},
+		{"permission denied", streamError(status.Error(codes.PermissionDenied, "no access")), 2},
+		{"unauthenticated", streamError(status.Error(codes.Unauthenticated, "token expired")), 2},
+		{"forbidden", streamError(&googleapi.Error{Code: http.StatusForbidden}), 2},
+		{"mismatch", mismatchError(errors.New("root mismatch at height 10")), 3},
+		{"stream failure", streamError(errors.New("source closed")), 4},
+		{"server error", streamError(&googleapi.Error{Code: http.StatusInternalServerError}), 4},
+		{"wrapped", fmt.Errorf("running monitor: %w", mismatchError(errors.New("mismatch"))), 3},
+		{"malformed credentials loaded", loadConfigError(mapSettings{"DISCORD_WEBHOOK_URL": "https://discord.example/webhook", "GCP_PROJECT_ID": "project", "PENUMBRA_NETWORK": "testnet", "GCP_CREDENTIALS": "{"}), 2},
+		{"missing key file loaded", loadConfigError(mapSettings{"DISCORD_WEBHOOK_URL": "discord.example/webhook", "GCP_PROJECT_ID": "project", "PENUMBRA_NETWORK": "testnet", "GOOGLE_APPLICATION_CREDENTIALS": "/nonexistent/key.json"}), 2},
+		{"invalid configuration loaded", loadConfigError(mapSettings{"GCP_PROJECT_ID": "project", "PENUMBRA_NETWORK": "testnet"}), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// explorerCacheSize bounds the number of roots cached from the explorer,
+	// the lowest heights being forgotten first.
+	explorerCacheSize = 1000
+	// explorerTimeout bounds a request to the explorer.
+	explorerTimeout = 10 * time.Second
+	// explorerIncidentKey identifies the incident of pods disagreeing with
+	// the explorer.
+	explorerIncidentKey = "explorer-mismatch"
+)
+
+// errExplorerUnknownHeight is returned by the explorer for heights it has not
+// indexed yet, which are checked again later.
+var errExplorerUnknownHeight = errors.New("height not known to the explorer")
+
+// explorerCheck cross-checks the roots the pods of a network agreed on with
+// the canonical ones an external block explorer reports, to catch pods that
+// agree with each other but are collectively wrong, e.g. partitioned from
+// the rest of the network. Explorer requests are made at most once every
+// `interval`, for the latest confirmed height, so that a fast chain does not
+// exceed the rate limits of the explorer, and its answers are cached by
+// height. A disagreement is alerted once, until the pods and the explorer
+// agree again.
+type explorerCheck struct {
+	network string
+	// url has a {height} placeholder, and optionally a {network} one.
+	url string
+	// rootField is the dotted path to the root in the JSON responses.
+	rootField string
+	interval  time.Duration
+	client    *http.Client
+	notifier  Notifier
+	metrics   *metrics
+
+	mu sync.Mutex
+	// pendingHeight is the latest confirmed height not checked yet, zero if
+	// none, and pendingRoot the root the pods agreed on.
+	pendingHeight int64
+	pendingRoot   string
+	roots         map[int64]string
+	mismatched    bool
+}
+
+func newExplorerCheck(network, url, rootField string, ratePerMin int, notifier Notifier, metrics *metrics) *explorerCheck {
+	return &explorerCheck{
+		network:   network,
+		url:       url,
+		rootField: rootField,
+		interval:  time.Minute / time.Duration(ratePerMin),
+		client:    &http.Client{Timeout: explorerTimeout},
+		notifier:  notifier,
+		metrics:   metrics,
+		roots:     make(map[int64]string),
+	}
+}
+
+// Observe records that the pods agreed on `root` at `height`, to be checked
+// once a request to the explorer is allowed. A nil *explorerCheck checks
+// nothing.
+func (e *explorerCheck) Observe(height int64, root string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if height > e.pendingHeight {
+		e.pendingHeight, e.pendingRoot = height, root
+	}
+}
+
+// Run checks the latest confirmed height every interval until `ctx` is
+// done. The alerts are delivered with `notifyCtx`.
+func (e *explorerCheck) Run(ctx, notifyCtx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	var backoffUntil time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.Before(backoffUntil) {
+				continue
+			}
+			e.mu.Lock()
+			height, root := e.pendingHeight, e.pendingRoot
+			e.pendingHeight, e.pendingRoot = 0, ""
+			e.mu.Unlock()
+			if height == 0 {
+				continue
+			}
+
+			retryAfter, err := e.check(ctx, notifyCtx, height, root)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			e.metrics.explorerChecks.WithLabelValues(e.network, "error").Inc()
+			slog.Warn("could not check the root with the explorer", "network", e.network, "height", height, "err", err)
+			// The height is checked again unless a newer one was confirmed
+			// meanwhile, after the delay the explorer asked for, if any.
+			e.Observe(height, root)
+			if retryAfter > 0 {
+				backoffUntil = now.Add(retryAfter)
+			}
+		}
+	}
+}
+
+// check compares `root`, agreed on by the pods at `height`, with the one of
+// the explorer. On failure, it returns how long the explorer asked to wait
+// before the next request, if it did.
+func (e *explorerCheck) check(ctx, notifyCtx context.Context, height int64, root string) (time.Duration, error) {
+	e.mu.Lock()
+	explorerRoot, cached := e.roots[height]
+	e.mu.Unlock()
+	if !cached {
+		var retryAfter time.Duration
+		var err error
+		explorerRoot, retryAfter, err = e.fetch(ctx, height)
+		if err != nil {
+			return retryAfter, err
+		}
+		e.cache(height, explorerRoot)
+	}
+
+	if normalizeRoot(explorerRoot) == normalizeRoot(root) {
+		e.metrics.explorerChecks.WithLabelValues(e.network, "agree").Inc()
+		slog.Debug("explorer agrees with the pods", "network", e.network, "height", height, "root", root)
+		if e.mismatched {
+			e.mismatched = false
+			slog.Info("pods agree with the explorer again", "event", "explorer_mismatch_cleared", "network", e.network, "height", height)
+			notify(notifyCtx, e.notifier, Message{
+				Severity:    SeverityInfo,
+				Title:       "Explorer agrees again",
+				Body:        fmt.Sprintf("the explorer reports the root the pods agreed on at block **%d**", height),
+				IncidentKey: explorerIncidentKey,
+				Resolved:    true,
+			})
+		}
+		return 0, nil
+	}
+
+	e.metrics.explorerChecks.WithLabelValues(e.network, "mismatch").Inc()
+	slog.Error("pods disagree with the explorer",
+		"event", "explorer_mismatch",
+		"network", e.network,
+		"height", height,
+		"root", root,
+		"explorer_root", explorerRoot,
+	)
+	if !e.mismatched {
+		e.mismatched = true
+		notify(notifyCtx, e.notifier, Message{
+			Severity:    SeverityCritical,
+			Title:       "Explorer mismatch",
+			Body:        fmt.Sprintf("the pods agreed on root _%s_ at block **%d**, but the explorer reports _%s_; the monitored pods may be partitioned from the canonical chain", root, height, explorerRoot),
+			IncidentKey: explorerIncidentKey,
+		})
+	}
+	return 0, nil
+}
+
+// fetch returns the root the explorer reports at `height`. A 429 response
+// returns the delay of its `Retry-After` header along with the error.
+func (e *explorerCheck) fetch(ctx context.Context, height int64) (string, time.Duration, error) {
+	url := strings.NewReplacer("{height}", strconv.FormatInt(height, 10), "{network}", e.network).Replace(e.url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("building explorer request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("querying the explorer: %v", err)
+	}
+	defer drainAndClose(resp)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", 0, errExplorerUnknownHeight
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "", parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("explorer rate limited: %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return "", 0, fmt.Errorf("explorer returned %s", resp.Status)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("decoding explorer response: %v", err)
+	}
+	root, ok := jsonField(body, e.rootField)
+	if !ok || root == "" {
+		return "", 0, fmt.Errorf("explorer response has no %q string field", e.rootField)
+	}
+	return root, 0, nil
+}
+
+// cache records the root of the explorer at `height`, forgetting the lowest
+// height beyond explorerCacheSize.
+func (e *explorerCheck) cache(height int64, root string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.roots[height] = root
+	if len(e.roots) <= explorerCacheSize {
+		return
+	}
+	lowest := height
+	for h := range e.roots {
+		lowest = min(lowest, h)
+	}
+	delete(e.roots, lowest)
+}
+
+// jsonField returns the string at the dotted `path` of a decoded JSON value,
+// e.g. "result.block.header.app_hash".
+func jsonField(value interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		value = object[key]
+	}
+	s, ok := value.(string)
+	return s, ok
+}
+
+// normalizeRoot returns `root` in the lowercase hex form the commit logs are
+// parsed into, so that an explorer answering in uppercase or with a 0x
+// prefix is not seen as disagreeing.
+func normalizeRoot(root string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(root)), "0x")
+}
@@ -0,0 +1,231 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// explorerServer stubs a block explorer answering `status` and `body` for
+// every height, recording the paths requested.
+type explorerServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	paths []string
+}
+
+func newExplorerServer(t *testing.T, status int, body string, header http.Header) *explorerServer {
+	t.Helper()
+	s := &explorerServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// requested returns the paths requested so far, in order.
+func (s *explorerServer) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.paths)
+}
+
+func TestExplorerCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		header    http.Header
+		rootField string
+		want      []string
+		// err is a substring of the error expected, if any.
+		err            string
+		wantRetryAfter time.Duration
+	}{
+		{name: "agreeing", status: http.StatusOK, body: `{"root": "aa"}`},
+		{name: "agreeing in another form", status: http.StatusOK, body: `{"root": "0xAA"}`},
+		{name: "nested field", status: http.StatusOK, body: `{"result": {"block": {"app_hash": "aa"}}}`, rootField: "result.block.app_hash"},
+		{name: "disagreeing", status: http.StatusOK, body: `{"root": "bb"}`, want: []string{"Explorer mismatch"}},
+		{name: "unknown height", status: http.StatusNotFound, err: errExplorerUnknownHeight.Error()},
+		{name: "rate limited", status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"30"}}, err: "rate limited", wantRetryAfter: 30 * time.Second},
+		{name: "server error", status: http.StatusInternalServerError, err: "500"},
+		{name: "invalid response", status: http.StatusOK, body: `<html>`, err: "decoding explorer response"},
+		{name: "missing field", status: http.StatusOK, body: `{"hash": "aa"}`, err: `no "root" string field`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newExplorerServer(t, tt.status, tt.body, tt.header)
+			rootField := tt.rootField
+			if rootField == "" {
+				rootField = "root"
+			}
+			notifier := &recordingNotifier{}
+			e := newExplorerCheck("testnet", server.URL+"/{network}/blocks/{height}", rootField, 60, notifier, newMetrics())
+
+			retryAfter, err := e.check(context.Background(), context.Background(), 10, "aa")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("check() = %v, want an error containing %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Errorf("check() = %v, want nil", err)
+			}
+			if retryAfter != tt.wantRetryAfter {
+				t.Errorf("check() retry after %s, want %s", retryAfter, tt.wantRetryAfter)
+			}
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+			if got, want := server.requested(), []string{"/testnet/blocks/10"}; !slices.Equal(got, want) {
+				t.Errorf("requested %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExplorerCheckCached(t *testing.T) {
+	server := newExplorerServer(t, http.StatusOK, `{"root": "bb"}`, nil)
+	notifier := &recordingNotifier{}
+	e := newExplorerCheck("testnet", server.URL+"/{height}", "root", 60, notifier, newMetrics())
+
+	for _, root := range []string{"aa", "aa", "bb"} {
+		if _, err := e.check(context.Background(), context.Background(), 10, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(server.requested()); got != 1 {
+		t.Errorf("requested the explorer %d times for a single height, want 1", got)
+	}
+	// Alerted once, then resolved.
+	if got, want := notifier.titles(), []string{"Explorer mismatch", "Explorer agrees again"}; !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+	if !notifier.messages[1].Resolved || notifier.messages[1].IncidentKey != notifier.messages[0].IncidentKey {
+		t.Errorf("notified %+v, want it resolving the incident of %+v", notifier.messages[1], notifier.messages[0])
+	}
+}
+
+func TestExplorerCheckCacheBounded(t *testing.T) {
+	e := newExplorerCheck("testnet", "https://explorer.example/{height}", "root", 60, &recordingNotifier{}, newMetrics())
+	for height := int64(1); height <= explorerCacheSize+1; height++ {
+		e.cache(height, "aa")
+	}
+	if len(e.roots) != explorerCacheSize {
+		t.Errorf("cached %d roots, want %d", len(e.roots), explorerCacheSize)
+	}
+	if _, ok := e.roots[1]; ok {
+		t.Error("lowest height still cached, want it forgotten")
+	}
+}
+
+func TestExplorerCheckRun(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprintf(w, `{"root": %q}`, "root"+strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer server.Close()
+	notifier := &recordingNotifier{}
+	// A request every 10ms.
+	e := newExplorerCheck("testnet", server.URL+"/{height}", "root", 6000, notifier, newMetrics())
+
+	// Only the latest height confirmed between two requests is checked.
+	for height := int64(1); height <= 5; height++ {
+		e.Observe(height, fmt.Sprintf("root%d", height))
+	}
+	e.Observe(4, "other")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, context.Background())
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	e.Observe(6, "diverged")
+	for len(notifier.titles()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requested the explorer %d times, want 2", got)
+	}
+	if got, want := notifier.titles(), []string{"Explorer mismatch"}; !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
+
+func TestProcessCommitLogsExplorer(t *testing.T) {
+	e := newExplorerCheck(t.Name(), "https://explorer.example/{height}", "root", 60, &recordingNotifier{}, newMetrics())
+	processEntries(t, testConfig(t), tmDeps{Explorer: e},
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "aa"),
+		commitEntry("pod-0", 11, "bb"),
+		commitEntry("pod-1", 11, "cc"),
+	)
+	// The mismatched height is left to the mismatch alert.
+	if e.pendingHeight != 10 || e.pendingRoot != "aa" {
+		t.Errorf("pending check at height %d of root %q, want 10 and aa", e.pendingHeight, e.pendingRoot)
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	value := map[string]interface{}{
+		"root":   "aa",
+		"height": 10.0,
+		"result": map[string]interface{}{"block": map[string]interface{}{"app_hash": "bb"}},
+	}
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"root", "aa", true},
+		{"result.block.app_hash", "bb", true},
+		{"height", "", false},
+		{"result.block", "", false},
+		{"root.nested", "", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := jsonField(value, tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("jsonField(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNormalizeRoot(t *testing.T) {
+	tests := []struct {
+		root string
+		want string
+	}{
+		{"0123ab", "0123ab"},
+		{"0x0123AB", "0123ab"},
+		{" 0123ab\n", "0123ab"},
+	}
+	for _, tt := range tests {
+		if got := normalizeRoot(tt.root); got != tt.want {
+			t.Errorf("normalizeRoot(%q) = %q, want %q", tt.root, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FilterTest prints the GCP log entries matching `filter`, across the
+// projects of the configured networks, to `w` as they are logged, without
+// parsing or alerting on them. It returns once `count` entries were printed,
+// if positive, or `ctx` is cancelled. With `asJSON`, each entry is printed
+// as a line of a replay file, which --replay-file can replay.
+func FilterTest(ctx context.Context, cfg *Config, filter string, count int, asJSON bool, w io.Writer) error {
+	var projects []string
+	for _, network := range cfg.Networks {
+		for _, projectID := range network.Projects() {
+			if !slices.Contains(projects, projectID) {
+				projects = append(projects, projectID)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	source := &gcpLogSource{Credentials: cfg.Credentials, ProjectIDs: projects, Filter: filter, PayloadField: cfg.PayloadField, Config: cfg.StreamConfig}
+	entries := make(chan LogEntry)
+	errc := make(chan error, 1)
+	go func() { errc <- source.Stream(ctx, entries) }()
+
+	printed := 0
+	for entry := range entries {
+		if count > 0 && printed >= count {
+			continue
+		}
+		if err := printFilterTestEntry(w, entry, asJSON); err != nil {
+			cancel()
+			return fmt.Errorf("printing log entry: %v", err)
+		}
+		printed++
+		if count > 0 && printed >= count {
+			cancel()
+		}
+	}
+	return <-errc
+}
+
+// printFilterTestEntry prints `entry` on a line, as a replay file line with
+// `asJSON`, and otherwise as its time, severity and sorted metadata followed
+// by its payload.
+func printFilterTestEntry(w io.Writer, entry LogEntry, asJSON bool) error {
+	if asJSON {
+		line, err := json.Marshal(replayEntry{Metadata: entry.metadata, Payload: entry.payload, Timestamp: entry.timestamp})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", line)
+		return err
+	}
+
+	keys := make([]string, 0, len(entry.metadata))
+	for key := range entry.metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := []string{entry.timestamp.UTC().Format(time.RFC3339Nano), entry.severity.String()}
+	for _, key := range keys {
+		fields = append(fields, key+"="+entry.metadata[key])
+	}
+	_, err := fmt.Fprintf(w, "%s: %s\n", strings.Join(fields, " "), entry.payload)
+	return err
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Alert events whose body is rendered from a template.
+const (
+	EventMilestone  = "milestone"
+	EventMismatch   = "mismatch"
+	EventRegression = "regression"
+	EventPDError    = "pd_error"
+)
+
+// defaultTemplates are used for the events without a template file.
+var defaultTemplates = map[string]string{
+	EventMilestone:  "**{{.PodName}}**, at height **{{.Height}}**, has apphash _{{.Root}}_",
+	EventMismatch:   "ROOT MISMATCH DETECTED AT BLOCK {{.Height}}\n{{.Roots}}",
+	EventRegression: "**{{.PodName}}** went back from height {{.PreviousHeight}} to {{.Height}}",
+	EventPDError:    "{{.PodName}}: {{.Payload}}",
+}
+
+// alertData is what alert templates are rendered with. The fields of the
+// commit log are promoted, e.g. {{.Height}} or {{.Root}}.
+type alertData struct {
+	LogData
+	Network string
+	// EnvTag identifies the monitor instance, e.g. "prod", empty if unset.
+	EnvTag string
+	// Roots lists the roots reported at the height, one per line, with the
+	// pods that reported them.
+	Roots string
+	// PreviousHeight is the height a regressing pod had reached.
+	PreviousHeight int64
+	// Payload is the raw log line, e.g. the pd error.
+	Payload string
+}
+
+// messageFormatter renders alert bodies from text/template templates, keyed
+// by event.
+type messageFormatter struct {
+	templates map[string]*template.Template
+	envTag    string
+}
+
+// newMessageFormatter loads the templates, overriding the defaults with the
+// `<event>.tmpl` files found in `dir`, if set. Every template is rendered
+// once to catch references to unknown fields early. `envTag` is available
+// to the templates as {{.EnvTag}}.
+func newMessageFormatter(dir, envTag string) (*messageFormatter, error) {
+	f := &messageFormatter{templates: make(map[string]*template.Template), envTag: envTag}
+	for event, text := range defaultTemplates {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, event+".tmpl"))
+			if err == nil {
+				text = strings.TrimRight(string(data), "\n")
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("reading %s template: %v", event, err)
+			}
+		}
+
+		tmpl, err := template.New(event).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s template: %v", event, err)
+		}
+		if err := tmpl.Execute(&strings.Builder{}, alertData{}); err != nil {
+			return nil, fmt.Errorf("checking %s template: %v", event, err)
+		}
+		f.templates[event] = tmpl
+	}
+	return f, nil
+}
+
+// Format renders the body of an `event` alert. If the template fails, the
+// error is logged and the default template is used instead.
+func (f *messageFormatter) Format(event string, data alertData) string {
+	data.EnvTag = f.envTag
+	var b strings.Builder
+	err := f.templates[event].Execute(&b, data)
+	if err == nil {
+		return b.String()
+	}
+
+	slog.Error("failed to render alert template, using the default one", "event", event, "err", err)
+	b.Reset()
+	template.Must(template.New(event).Parse(defaultTemplates[event])).Execute(&b, data)
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageFormatterDefaults(t *testing.T) {
+	data := alertData{
+		LogData:        LogData{Height: 42, Root: "0123ab", PodName: "pod-0"},
+		Roots:          "aa: pod-0\nbb: pod-1",
+		PreviousHeight: 50,
+		Payload:        "panic: out of gas",
+	}
+	tests := []struct {
+		event string
+		want  string
+	}{
+		{EventMilestone, "**pod-0**, at height **42**, has apphash _0123ab_"},
+		{EventMismatch, "ROOT MISMATCH DETECTED AT BLOCK 42\naa: pod-0\nbb: pod-1"},
+		{EventRegression, "**pod-0** went back from height 50 to 42"},
+		{EventPDError, "pod-0: panic: out of gas"},
+	}
+	f, err := newMessageFormatter("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			if got := f.Format(tt.event, data); got != tt.want {
+				t.Errorf("Format(%s) = %q, want %q", tt.event, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageFormatterTemplateFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		envTag   string
+		want     string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{"override", "<@&123> {{.Network}} forked at {{.Height}}\n", "", "<@&123> penumbra forked at 42", ""},
+		{"env tag", "[{{.EnvTag}}] {{.Height}}", "prod", "[prod] 42", ""},
+		{"syntax error", "{{.Height", "", "", "parsing mismatch template"},
+		{"unknown field", "{{.Block}}", "", "", "checking mismatch template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, EventMismatch+".tmpl"), []byte(tt.template), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := newMessageFormatter(dir, tt.envTag)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("newMessageFormatter() = %v, want an error mentioning %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Format(EventMismatch, alertData{LogData: LogData{Height: 42}, Network: "penumbra"}); got != tt.want {
+				t.Errorf("Format(mismatch) = %q, want %q", got, tt.want)
+			}
+			// The events without a template file keep the default.
+			if got := f.Format(EventPDError, alertData{LogData: LogData{PodName: "pod-0"}, Payload: "err"}); got != "pod-0: err" {
+				t.Errorf("Format(pd_error) = %q, want the default template", got)
+			}
+		})
+	}
+}
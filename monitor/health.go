@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthTracker records when each worker last received a log entry.
+type healthTracker struct {
+	mu       sync.RWMutex
+	lastSeen map[string]time.Time
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{lastSeen: make(map[string]time.Time)}
+}
+
+// Observe marks `worker` as having just received an entry.
+func (h *healthTracker) Observe(worker string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSeen[worker] = time.Now()
+}
+
+// Ready reports whether any worker received an entry within `staleness`.
+func (h *healthTracker) Ready(staleness time.Duration) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, t := range h.lastSeen {
+		if time.Since(t) <= staleness {
+			return true
+		}
+	}
+	return false
+}
+
+// healthzHandler reports the process as alive, along with the state of the
+// circuit breakers of the notification backends, e.g.
+// "notifier discord: open, 3 alerts held". A backend failing does not fail
+// the check, restarting the process would not fix it.
+func healthzHandler(breakers []*circuitBreaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "OK")
+		for _, b := range breakers {
+			state, held := b.State()
+			fmt.Fprintf(w, "\nnotifier %s: %s", b.backend, state)
+			if held > 0 {
+				fmt.Fprintf(w, ", %d alerts held", held)
+			}
+		}
+	}
+}
+
+func (h *healthTracker) readyzHandler(staleness time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.Ready(staleness) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "no log entry received in the last %s", staleness)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "OK")
+	}
+}
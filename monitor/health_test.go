@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	discord := newCircuitBreaker("discord", &switchNotifier{}, 1, time.Hour, newMetrics())
+	slack := newCircuitBreaker("slack", &switchNotifier{failing: true}, 1, time.Hour, newMetrics())
+	slack.Notify(context.Background(), Message{Title: "opening"})
+	slack.Notify(context.Background(), Message{Title: "held"})
+
+	rec := httptest.NewRecorder()
+	healthzHandler([]*circuitBreaker{discord, slack})(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("/healthz = %d, want %d", rec.Code, http.StatusOK)
+	}
+	want := "OK\nnotifier discord: closed\nnotifier slack: open, 1 alerts held"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("/healthz = %q, want %q", got, want)
+	}
+}
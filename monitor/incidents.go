@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxResolvedIncidents bounds the number of resolved incidents kept per
+// network, the oldest being forgotten first.
+const maxResolvedIncidents = 100
+
+// incident is a root mismatch as recorded for postmortems, from its
+// detection until pods agree again past it.
+type incident struct {
+	ID         string    `json:"id"`
+	Network    string    `json:"network"`
+	Height     int64     `json:"height"`
+	DetectedAt time.Time `json:"detected_at"`
+	// Roots maps each root reported at Height to the pods that reported it.
+	Roots map[string][]string `json:"roots"`
+	// ResolvedAt is nil while the mismatch has not cleared.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+// incidents records the mismatch incidents of every network. They are
+// persisted along with the state of the tm workers and served read-only.
+type incidents struct {
+	mu sync.RWMutex
+	// incidents are keyed by ID.
+	incidents map[string]*incident
+}
+
+func newIncidents() *incidents {
+	return &incidents{incidents: make(map[string]*incident)}
+}
+
+func incidentID(network string, height int64) string {
+	return fmt.Sprintf("%s-mismatch-%d", network, height)
+}
+
+// Open records the mismatch detected at `height` of `network`, with the pods
+// that reported each root, unless it is already recorded.
+func (i *incidents) Open(network string, height int64, roots map[string][]string, now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id := incidentID(network, height)
+	if _, ok := i.incidents[id]; ok {
+		return
+	}
+	i.incidents[id] = &incident{ID: id, Network: network, Height: height, DetectedAt: now.UTC(), Roots: roots}
+	i.pruneLocked(network)
+}
+
+// Update replaces the roots of the incident at `height` of `network`, if
+// any, e.g. once more pods reported that height.
+func (i *incidents) Update(network string, height int64, roots map[string][]string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if incident, ok := i.incidents[incidentID(network, height)]; ok {
+		incident.Roots = roots
+	}
+}
+
+// Resolve resolves the open incidents of `network` below `height`, with
+// `resolution` explaining why, and returns them.
+func (i *incidents) Resolve(network string, height int64, resolution string, now time.Time) []incident {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var resolved []incident
+	for _, incident := range i.incidents {
+		if incident.Network != network || incident.ResolvedAt != nil || incident.Height >= height {
+			continue
+		}
+		resolvedAt := now.UTC()
+		incident.ResolvedAt, incident.Resolution = &resolvedAt, resolution
+		resolved = append(resolved, *incident)
+	}
+	return resolved
+}
+
+// Restore adds the incidents of a network persisted earlier.
+func (i *incidents) Restore(incidents []incident) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, incident := range incidents {
+		incident := incident
+		i.incidents[incident.ID] = &incident
+	}
+}
+
+// Network returns the incidents of `network`, the most recent first.
+func (i *incidents) Network(network string) []incident {
+	return i.list(func(incident *incident) bool { return incident.Network == network })
+}
+
+func (i *incidents) list(keep func(*incident) bool) []incident {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	incidents := []incident{}
+	for _, incident := range i.incidents {
+		if keep(incident) {
+			incidents = append(incidents, *incident)
+		}
+	}
+	sort.Slice(incidents, func(a, b int) bool {
+		if !incidents[a].DetectedAt.Equal(incidents[b].DetectedAt) {
+			return incidents[a].DetectedAt.After(incidents[b].DetectedAt)
+		}
+		return incidents[a].ID < incidents[b].ID
+	})
+	return incidents
+}
+
+// pruneLocked forgets the oldest resolved incidents of `network` beyond
+// maxResolvedIncidents.
+func (i *incidents) pruneLocked(network string) {
+	var resolved []*incident
+	for _, incident := range i.incidents {
+		if incident.Network == network && incident.ResolvedAt != nil {
+			resolved = append(resolved, incident)
+		}
+	}
+	if len(resolved) <= maxResolvedIncidents {
+		return
+	}
+	sort.Slice(resolved, func(a, b int) bool { return resolved[a].DetectedAt.Before(resolved[b].DetectedAt) })
+	for _, incident := range resolved[:len(resolved)-maxResolvedIncidents] {
+		delete(i.incidents, incident.ID)
+	}
+}
+
+// Handler serves `GET /incidents`, the incidents of every network, the most
+// recent first. `?network=` selects a network, and `?open=true` the
+// incidents that have not been resolved.
+func (i *incidents) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := req.URL.Query()
+		network, open := query.Get("network"), query.Get("open") == "true"
+		incidents := i.list(func(incident *incident) bool {
+			return (network == "" || incident.Network == network) && (!open || incident.ResolvedAt == nil)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(incidents)
+	})
+}
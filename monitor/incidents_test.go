@@ -0,0 +1,175 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIncidentsLifecycle(t *testing.T) {
+	detected := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	i := newIncidents()
+	i.Open("testnet", 10, map[string][]string{"aa": {"pod-0"}, "bb": {"pod-1"}}, detected)
+	// Already recorded.
+	i.Open("testnet", 10, map[string][]string{"cc": {"pod-2"}}, detected.Add(time.Minute))
+	i.Update("testnet", 10, map[string][]string{"aa": {"pod-0", "pod-2"}, "bb": {"pod-1"}})
+	// No incident at that height.
+	i.Update("testnet", 11, map[string][]string{"cc": {"pod-2"}})
+	i.Open("mainnet", 10, map[string][]string{"aa": {"pod-0"}, "bb": {"pod-1"}}, detected)
+
+	if resolved := i.Resolve("testnet", 10, "pods agreed on the root of block 10", detected.Add(time.Hour)); len(resolved) != 0 {
+		t.Errorf("Resolve() at the height of the incident = %v, want nothing resolved", resolved)
+	}
+	resolvedAt := detected.Add(2 * time.Hour)
+	resolved := i.Resolve("testnet", 11, "pods agreed on the root of block 11", resolvedAt)
+	want := []incident{{
+		ID:         "testnet-mismatch-10",
+		Network:    "testnet",
+		Height:     10,
+		DetectedAt: detected,
+		Roots:      map[string][]string{"aa": {"pod-0", "pod-2"}, "bb": {"pod-1"}},
+		ResolvedAt: &resolvedAt,
+		Resolution: "pods agreed on the root of block 11",
+	}}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("Resolve() = %+v, want %+v", resolved, want)
+	}
+	if resolved := i.Resolve("testnet", 12, "again", resolvedAt); len(resolved) != 0 {
+		t.Errorf("Resolve() once resolved = %v, want nothing resolved", resolved)
+	}
+
+	if got := i.Network("testnet"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Network(testnet) = %+v, want %+v", got, want)
+	}
+	if got := i.Network("mainnet"); len(got) != 1 || got[0].ResolvedAt != nil {
+		t.Errorf("Network(mainnet) = %+v, want the open incident of mainnet", got)
+	}
+
+	restored := newIncidents()
+	restored.Restore(i.Network("testnet"))
+	if got := restored.Network("testnet"); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored Network(testnet) = %+v, want %+v", got, want)
+	}
+}
+
+func TestIncidentsPruned(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	i := newIncidents()
+	for height := int64(1); height <= maxResolvedIncidents+1; height++ {
+		i.Open("testnet", height, nil, start.Add(time.Duration(height)*time.Minute))
+	}
+	i.Resolve("testnet", maxResolvedIncidents+2, "pods agreed", start)
+	// Pruned as the next incident is opened.
+	i.Open("testnet", maxResolvedIncidents+10, nil, start.Add(time.Hour*24))
+
+	incidents := i.Network("testnet")
+	if len(incidents) != maxResolvedIncidents+1 {
+		t.Fatalf("kept %d incidents, want %d", len(incidents), maxResolvedIncidents+1)
+	}
+	if oldest := incidents[len(incidents)-1]; oldest.Height != 2 {
+		t.Errorf("oldest incident kept at height %d, want 2", oldest.Height)
+	}
+}
+
+func TestIncidentsHandler(t *testing.T) {
+	detected := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	i := newIncidents()
+	i.Open("testnet", 10, nil, detected)
+	i.Open("testnet", 20, nil, detected.Add(time.Minute))
+	i.Open("mainnet", 30, nil, detected.Add(2*time.Minute))
+	i.Resolve("testnet", 11, "pods agreed", detected.Add(time.Hour))
+	server := httptest.NewServer(i.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		method     string
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{http.MethodGet, "", http.StatusOK, []string{"mainnet-mismatch-30", "testnet-mismatch-20", "testnet-mismatch-10"}},
+		{http.MethodGet, "?network=testnet", http.StatusOK, []string{"testnet-mismatch-20", "testnet-mismatch-10"}},
+		{http.MethodGet, "?network=testnet&open=true", http.StatusOK, []string{"testnet-mismatch-20"}},
+		{http.MethodGet, "?network=unknown", http.StatusOK, []string{}},
+		{http.MethodPost, "", http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s %s = %s, want %d", tt.method, tt.query, resp.Status, tt.wantStatus)
+		}
+		if resp.StatusCode == http.StatusOK {
+			var incidents []incident
+			if err := json.NewDecoder(resp.Body).Decode(&incidents); err != nil {
+				t.Errorf("%s %s: decoding the response: %v", tt.method, tt.query, err)
+			}
+			ids := []string{}
+			for _, incident := range incidents {
+				ids = append(ids, incident.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("%s %s = %q, want %q", tt.method, tt.query, ids, tt.want)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestProcessCommitLogsIncidents(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		// wantResolution is the resolution of the incident, empty while open.
+		wantResolution string
+	}{
+		{
+			name:    "open",
+			entries: []LogEntry{commitEntry("pod-0", 10, "aa"), commitEntry("pod-1", 10, "bb")},
+		},
+		{
+			name:           "resolved",
+			entries:        []LogEntry{commitEntry("pod-0", 10, "aa"), commitEntry("pod-1", 10, "bb"), commitEntry("pod-0", 11, "cc"), commitEntry("pod-1", 11, "cc")},
+			wantResolution: "pods agreed on the root of block 11",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incidents := newIncidents()
+			processEntries(t, testConfig(t), tmDeps{Incidents: incidents}, tt.entries...)
+
+			recorded := incidents.Network(t.Name())
+			if len(recorded) != 1 {
+				t.Fatalf("recorded %d incidents, want 1", len(recorded))
+			}
+			got := recorded[0]
+			wantRoots := map[string][]string{"aa": {"pod-0"}, "bb": {"pod-1"}}
+			if got.Height != 10 || !reflect.DeepEqual(got.Roots, wantRoots) || got.Resolution != tt.wantResolution || (got.ResolvedAt != nil) != (tt.wantResolution != "") {
+				t.Errorf("recorded %+v, want at height 10 with roots %v and resolution %q", got, wantRoots, tt.wantResolution)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsIncidentsPersisted(t *testing.T) {
+	store := newFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	processEntries(t, testConfig(t), tmDeps{Store: store, Incidents: newIncidents()}, commitEntry("pod-0", 10, "aa"), commitEntry("pod-1", 10, "bb"))
+
+	// Restored after a restart, and resolved once the pods agree again.
+	incidents := newIncidents()
+	processEntries(t, testConfig(t), tmDeps{Store: store, Incidents: incidents}, commitEntry("pod-0", 11, "cc"), commitEntry("pod-1", 11, "cc"))
+	recorded := incidents.Network(t.Name())
+	if len(recorded) != 1 || recorded[0].Height != 10 || recorded[0].ResolvedAt == nil {
+		t.Errorf("recorded %+v after the restart, want the incident at height 10 resolved", recorded)
+	}
+}
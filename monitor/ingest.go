@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ingestMaxBodySize bounds the size of a batch of log entries pushed to the
+// ingest endpoint.
+const ingestMaxBodySize = 10 << 20
+
+// ingest receives the log entries pushed to `POST /ingest`, instead of
+// pulling them from GCP, and hands them to the workers of their network.
+type ingest struct {
+	token string
+	// sources are the sources of the workers, keyed by network and worker.
+	sources  map[ingestKey]*ingestLogSource
+	networks []string
+	metrics  *metrics
+}
+
+type ingestKey struct {
+	network, worker string
+}
+
+// newIngest creates the ingest endpoint, accepting the requests
+// authenticated with the bearer `token`.
+func newIngest(token string, metrics *metrics) *ingest {
+	return &ingest{token: token, sources: make(map[ingestKey]*ingestLogSource), metrics: metrics}
+}
+
+// Source returns the source of the entries pushed for the `worker`, "tm" or
+// "pd", of `network`. The sources are created before the endpoint is served.
+func (i *ingest) Source(network, worker string) *ingestLogSource {
+	key := ingestKey{network, worker}
+	if s, ok := i.sources[key]; ok {
+		return s
+	}
+	s := &ingestLogSource{entries: make(chan LogEntry), done: make(chan struct{})}
+	i.sources[key] = s
+	if !slices.Contains(i.networks, network) {
+		i.networks = append(i.networks, network)
+	}
+	return s
+}
+
+// Handler serves `POST /ingest?network=...&worker=...`, whose body is a JSON
+// array of log entries in the format of the replay files, e.g.
+// [{"metadata": {"pod_name": "..."}, "payload": "...", "timestamp": "..."}].
+// `network` may be omitted when a single network is monitored, `worker`
+// defaults to "tm". The request returns once every entry was handed to the
+// worker.
+func (i *ingest) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(i.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		query := req.URL.Query()
+		network := query.Get("network")
+		if network == "" && len(i.networks) == 1 {
+			network = i.networks[0]
+		}
+		worker := query.Get("worker")
+		if worker == "" {
+			worker = "tm"
+		}
+		source, ok := i.sources[ingestKey{network, worker}]
+		if !ok {
+			http.Error(w, fmt.Sprintf("no %s worker for network %q", worker, network), http.StatusNotFound)
+			return
+		}
+
+		var entries []replayEntry
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, ingestMaxBodySize)).Decode(&entries); err != nil {
+			http.Error(w, fmt.Sprintf("decoding log entries: %v", err), http.StatusBadRequest)
+			return
+		}
+		for n, entry := range entries {
+			if err := source.push(req.Context(), LogEntry{metadata: entry.Metadata, payload: entry.Payload, timestamp: entry.Timestamp}); err != nil {
+				http.Error(w, fmt.Sprintf("%d of %d entries ingested: %v", n, len(entries), err), http.StatusServiceUnavailable)
+				return
+			}
+			i.metrics.ingestedEntries.WithLabelValues(network, worker).Inc()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]int{"accepted": len(entries)})
+	})
+}
+
+// ingestLogSource streams the log entries pushed to the ingest endpoint for
+// a worker.
+type ingestLogSource struct {
+	entries chan LogEntry
+	// done is closed once the worker stopped taking entries.
+	done chan struct{}
+}
+
+// errIngestStopped is returned for the entries pushed once the worker
+// stopped.
+var errIngestStopped = errors.New("the worker stopped")
+
+func (s *ingestLogSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	defer close(out)
+	defer close(s.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-s.entries:
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// push waits for the worker to take `entry`, or for `ctx` to be done.
+func (s *ingestLogSource) push(ctx context.Context, entry LogEntry) error {
+	select {
+	case s.entries <- entry:
+		return nil
+	case <-s.done:
+		return errIngestStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
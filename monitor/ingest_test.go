@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIngestHandler(t *testing.T) {
+	const batch = `[
+		{"metadata": {"pod_name": "pod-0"}, "payload": "finalizing commit of block module=consensus height=10 hash=ab root=aaaa num_txs=1", "timestamp": "2024-03-01T12:00:00Z"},
+		{"metadata": {"pod_name": "pod-1"}, "payload": "finalizing commit of block module=consensus height=10 hash=ab root=bbbb num_txs=1", "timestamp": "2024-03-01T12:00:01Z"}
+	]`
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		query      string
+		body       string
+		wantStatus int
+		// want are the titles of the alerts raised by the pushed entries.
+		want []string
+	}{
+		{"mismatch", http.MethodPost, "secret", "", batch, http.StatusAccepted, []string{"Root mismatch"}},
+		{"explicit worker", http.MethodPost, "secret", "?worker=tm", batch, http.StatusAccepted, []string{"Root mismatch"}},
+		{"wrong token", http.MethodPost, "guess", "", batch, http.StatusUnauthorized, nil},
+		{"missing token", http.MethodPost, "", "", batch, http.StatusUnauthorized, nil},
+		{"not a post", http.MethodGet, "secret", "", "", http.StatusMethodNotAllowed, nil},
+		{"unknown network", http.MethodPost, "secret", "?network=other", batch, http.StatusNotFound, nil},
+		{"unknown worker", http.MethodPost, "secret", "?worker=pd", batch, http.StatusNotFound, nil},
+		{"invalid body", http.MethodPost, "secret", "", `{"payload": "not an array"}`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingest := newIngest("secret", newMetrics())
+			source := ingest.Source(t.Name(), "tm")
+			server := httptest.NewServer(ingest.Handler())
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan LogEntry)
+			go source.Stream(ctx, in)
+			notifier := &recordingNotifier{}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				processCommitLogs(ctx, context.Background(), in, notifier, testConfig(t), NetworkConfig{Name: t.Name()}, tmDeps{})
+			}()
+
+			req, err := http.NewRequest(tt.method, server.URL+tt.query, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("%s /ingest%s = %s, want %d", tt.method, tt.query, resp.Status, tt.wantStatus)
+			}
+
+			// Once the entries were accepted, stopping the worker flushes them.
+			cancel()
+			<-done
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngestWorkerStopped(t *testing.T) {
+	ingest := newIngest("secret", newMetrics())
+	source := ingest.Source("testnet", "tm")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source.Stream(ctx, make(chan LogEntry))
+
+	if err := source.push(context.Background(), NewLogEntry("pod-0", "payload", time.Time{})); err != errIngestStopped {
+		t.Errorf("push() = %v once the worker stopped, want %v", err, errIngestStopped)
+	}
+}
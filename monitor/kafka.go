@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// kafkaBatchSize is the maximum number of events written at once.
+	kafkaBatchSize = 100
+	// kafkaBatchTimeout is how long the first event of a batch waits for
+	// others before the batch is written.
+	kafkaBatchTimeout = time.Second
+)
+
+// kafkaWriter is the part of kafka.Writer used by kafkaSink.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink publishes every event, as JSON, to a Kafka topic, for the data
+// pipelines downstream. Events are queued in a bounded buffer and written in
+// batches by Run; when the brokers cannot keep up, the newest events are
+// dropped rather than slowing the workers down.
+type kafkaSink struct {
+	writer  kafkaWriter
+	queue   chan recentEvent
+	metrics *metrics
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// newKafkaSink creates a sink writing to `topic` on `brokers`, buffering at
+// most `bufferSize` events.
+func newKafkaSink(brokers []string, topic string, bufferSize int, metrics *metrics) *kafkaSink {
+	return newKafkaSinkWriter(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    kafkaBatchSize,
+		BatchTimeout: kafkaBatchTimeout,
+		RequiredAcks: kafka.RequireOne,
+	}, bufferSize, metrics)
+}
+
+// newKafkaSinkWriter creates a sink writing with `writer`.
+func newKafkaSinkWriter(writer kafkaWriter, bufferSize int, metrics *metrics) *kafkaSink {
+	return &kafkaSink{
+		writer:  writer,
+		queue:   make(chan recentEvent, bufferSize),
+		metrics: metrics,
+		done:    make(chan struct{}),
+	}
+}
+
+// Publish queues an event, dropping it if the buffer is full.
+func (k *kafkaSink) Publish(event recentEvent) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return
+	}
+	select {
+	case k.queue <- event:
+	default:
+		k.metrics.kafkaEventsDropped.Inc()
+		slog.Debug("kafka buffer full, dropping event", "kind", event.Kind, "network", event.Network, "height", event.Height)
+	}
+}
+
+// Run writes the queued events in batches until the sink is closed and its
+// buffer drained.
+func (k *kafkaSink) Run() {
+	defer close(k.done)
+
+	batch := make([]kafka.Message, 0, kafkaBatchSize)
+	for event := range k.queue {
+		batch = append(batch[:0], kafkaMessage(event))
+		// Take whatever else is queued, up to a batch.
+	fill:
+		for len(batch) < kafkaBatchSize {
+			select {
+			case event, ok := <-k.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, kafkaMessage(event))
+			default:
+				break fill
+			}
+		}
+
+		if err := k.writer.WriteMessages(context.Background(), batch...); err != nil {
+			k.metrics.kafkaEventsDropped.Add(float64(len(batch)))
+			slog.Error("failed to publish events to kafka", "events", len(batch), "err", err)
+		}
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be written,
+// until `ctx` is done.
+func (k *kafkaSink) Close(ctx context.Context) error {
+	k.mu.Lock()
+	if !k.closed {
+		k.closed = true
+		close(k.queue)
+	}
+	k.mu.Unlock()
+
+	select {
+	case <-k.done:
+	case <-ctx.Done():
+		return fmt.Errorf("%d events were not published: %v", len(k.queue), ctx.Err())
+	}
+	return k.writer.Close()
+}
+
+// kafkaMessage encodes an event, keyed by network and pod so that the events
+// of a pod stay ordered within a partition.
+func kafkaMessage(event recentEvent) kafka.Message {
+	value, _ := json.Marshal(event)
+	return kafka.Message{
+		Key:   []byte(event.Network + "/" + event.PodName),
+		Value: value,
+		Time:  event.Time,
+	}
+}
+
+// parseBrokers splits a comma-separated list of host:port broker addresses.
+func parseBrokers(s string) ([]string, error) {
+	var brokers []string
+	for _, broker := range strings.Split(s, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			continue
+		}
+		if !strings.Contains(broker, ":") {
+			return nil, fmt.Errorf("broker %q must be of the form host:port", broker)
+		}
+		brokers = append(brokers, broker)
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("no broker listed")
+	}
+	return brokers, nil
+}
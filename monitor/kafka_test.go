@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// mockProducer records the messages written, and fails with `err` if set.
+type mockProducer struct {
+	err error
+
+	mu      sync.Mutex
+	batches [][]kafka.Message
+	closed  bool
+}
+
+func (p *mockProducer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.batches = append(p.batches, msgs)
+	return p.err
+}
+
+func (p *mockProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// messages returns the messages written so far.
+func (p *mockProducer) messages() []kafka.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var msgs []kafka.Message
+	for _, batch := range p.batches {
+		msgs = append(msgs, batch...)
+	}
+	return msgs
+}
+
+func TestKafkaSinkPublishes(t *testing.T) {
+	producer := &mockProducer{}
+	sink := newKafkaSinkWriter(producer, 10, newMetrics())
+	go sink.Run()
+
+	logged := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []recentEvent{
+		{Time: logged, Kind: eventKindCommit, Network: "testnet", PodName: "pod-0", Height: 10, Root: "aa"},
+		{Time: logged, Kind: eventKindMismatch, Network: "testnet", Height: 11, Details: "aa: pod-0\nbb: pod-1"},
+		{Time: logged, Kind: eventKindPDError, Network: "testnet", PodName: "pod-1", Details: "panic"},
+	}
+	for _, event := range events {
+		sink.Publish(event)
+	}
+	// Closing flushes the queued events.
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !producer.closed {
+		t.Error("producer not closed")
+	}
+
+	msgs := producer.messages()
+	if len(msgs) != len(events) {
+		t.Fatalf("published %d events, want %d", len(msgs), len(events))
+	}
+	for i, msg := range msgs {
+		var got recentEvent
+		if err := json.Unmarshal(msg.Value, &got); err != nil {
+			t.Fatalf("decoding %s: %v", msg.Value, err)
+		}
+		if got != events[i] {
+			t.Errorf("published %+v, want %+v", got, events[i])
+		}
+		if want := "testnet/" + events[i].PodName; string(msg.Key) != want {
+			t.Errorf("key = %q, want %q", msg.Key, want)
+		}
+	}
+
+	// Events published once closed are ignored.
+	sink.Publish(events[0])
+}
+
+func TestKafkaSinkDrops(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want float64
+	}{
+		{"buffer full", nil, 3},
+		{"write failure", errors.New("broker down"), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newMetrics()
+			sink := newKafkaSinkWriter(&mockProducer{err: tt.err}, 2, metrics)
+			// Nothing is written until Run starts, only the first 2 events
+			// fit in the buffer.
+			for height := int64(1); height <= 5; height++ {
+				sink.Publish(recentEvent{Kind: eventKindCommit, Network: "testnet", Height: height})
+			}
+			go sink.Run()
+			if err := sink.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := counterValue(t, metrics.kafkaEventsDropped); got != tt.want {
+				t.Errorf("dropped %v events, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBrokers(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"kafka-0:9092", []string{"kafka-0:9092"}, false},
+		{" kafka-0:9092, kafka-1:9092 ,", []string{"kafka-0:9092", "kafka-1:9092"}, false},
+		{"kafka-0", nil, true},
+		{" , ", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBrokers(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBrokers(%q) = %v, want error: %v", tt.s, err, tt.wantErr)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseBrokers(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package monitor
+
+// lagTracker measures how far each pod is behind the furthest-ahead one.
+// Lags are computed from the highest height every pod reported, so that a
+// log line delivered late does not look like the pod fell behind.
+type lagTracker struct {
+	// maxLag is the lag above which a pod is flagged, zero disables it.
+	maxLag  int64
+	leader  int64
+	highest map[string]int64
+	lagging map[string]bool
+}
+
+func newLagTracker(maxLag int) *lagTracker {
+	return &lagTracker{
+		maxLag:  int64(maxLag),
+		highest: make(map[string]int64),
+		lagging: make(map[string]bool),
+	}
+}
+
+// Observe records that `podName` reported `height` and returns its lag. It
+// also reports whether the pod just went over the maximum lag, or just
+// caught up after that.
+func (l *lagTracker) Observe(podName string, height int64) (lag int64, fellBehind, caughtUp bool) {
+	if height > l.highest[podName] {
+		l.highest[podName] = height
+	}
+	if height > l.leader {
+		l.leader = height
+	}
+
+	lag = l.leader - l.highest[podName]
+	if l.maxLag <= 0 {
+		return lag, false, false
+	}
+
+	switch {
+	case lag > l.maxLag && !l.lagging[podName]:
+		l.lagging[podName] = true
+		return lag, true, false
+	case lag <= l.maxLag && l.lagging[podName]:
+		delete(l.lagging, podName)
+		return lag, false, true
+	}
+	return lag, false, false
+}
+
+// Reset forgets the heights reported so far, once the chain restarted from a
+// lower height.
+func (l *lagTracker) Reset() {
+	l.leader = 0
+	clear(l.highest)
+	clear(l.lagging)
+}
+
+// Leader returns the highest height reported by any pod.
+func (l *lagTracker) Leader() int64 {
+	return l.leader
+}
+
+// MaxLag returns the pod furthest behind the leader and its lag.
+func (l *lagTracker) MaxLag() (string, int64) {
+	var pod string
+	maxLag := int64(-1)
+	for podName, height := range l.highest {
+		if lag := l.leader - height; lag > maxLag || (lag == maxLag && podName < pod) {
+			pod, maxLag = podName, lag
+		}
+	}
+	if maxLag < 0 {
+		return "", 0
+	}
+	return pod, maxLag
+}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"time"
+)
+
+// livenessTracker detects pods that stopped reporting new commits while the
+// rest of the fleet kept advancing.
+type livenessTracker struct {
+	timeout time.Duration
+	pods    map[string]*podLiveness
+}
+
+type podLiveness struct {
+	height     int64
+	advancedAt time.Time
+	stale      bool
+}
+
+// stalePod describes a pod that has not advanced within the timeout.
+type stalePod struct {
+	PodName   string
+	Height    int64
+	Since     time.Time
+	TipHeight int64
+}
+
+// newLivenessTracker creates a tracker. Pods in `expected` are tracked from
+// the start, others are learned as they report.
+func newLivenessTracker(timeout time.Duration, expected []string, now time.Time) *livenessTracker {
+	l := &livenessTracker{
+		timeout: timeout,
+		pods:    make(map[string]*podLiveness),
+	}
+	for _, podName := range expected {
+		l.pods[podName] = &podLiveness{advancedAt: now}
+	}
+	return l
+}
+
+// Observe records that `podName` reported `height`. It reports whether the
+// pod was previously flagged as stale and has now recovered.
+func (l *livenessTracker) Observe(podName string, height int64, now time.Time) bool {
+	pod, ok := l.pods[podName]
+	if !ok {
+		l.pods[podName] = &podLiveness{height: height, advancedAt: now}
+		return false
+	}
+	if height <= pod.height {
+		return false
+	}
+
+	pod.height = height
+	pod.advancedAt = now
+	recovered := pod.stale
+	pod.stale = false
+	return recovered
+}
+
+// Reset forgets the heights reported so far, once the chain restarted from a
+// lower height. The pods are tracked from `now` again.
+func (l *livenessTracker) Reset(now time.Time) {
+	for _, pod := range l.pods {
+		*pod = podLiveness{advancedAt: now}
+	}
+}
+
+// Check returns the pods that became stale since the last call: they have
+// not advanced within the timeout while another pod reached a higher height.
+func (l *livenessTracker) Check(now time.Time) []stalePod {
+	if l.timeout <= 0 {
+		return nil
+	}
+
+	var tip int64
+	for _, pod := range l.pods {
+		if pod.height > tip {
+			tip = pod.height
+		}
+	}
+
+	var stale []stalePod
+	for podName, pod := range l.pods {
+		if pod.stale || pod.height >= tip || now.Sub(pod.advancedAt) < l.timeout {
+			continue
+		}
+		pod.stale = true
+		stale = append(stale, stalePod{
+			PodName:   podName,
+			Height:    pod.height,
+			Since:     pod.advancedAt,
+			TipHeight: tip,
+		})
+	}
+	return stale
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// logBufferWarnAfter is how long a log buffer may stay near full before
+	// a warning is logged.
+	logBufferWarnAfter = 30 * time.Second
+	// logBufferCheckInterval is how often the fill level of a log buffer is
+	// checked while no entry comes in or goes out.
+	logBufferCheckInterval = 5 * time.Second
+)
+
+// logBuffer relays the entries of a log source to a worker through a
+// bounded buffer, so that the source keeps reading while the worker is busy,
+// e.g. delivering alerts, and the GCP stream is not seen as stalled. Once
+// the buffer is full, the source waits for the worker, unless `dropOldest`
+// is set, in which case the oldest buffered entry is dropped.
+type logBuffer struct {
+	network, worker string
+	size            int
+	dropOldest      bool
+	metrics         *metrics
+
+	// nearFullSince is when the buffer last became near full, zero while it
+	// is not.
+	nearFullSince time.Time
+	warned        bool
+}
+
+func newLogBuffer(network, worker string, size int, dropOldest bool, metrics *metrics) *logBuffer {
+	return &logBuffer{network: network, worker: worker, size: size, dropOldest: dropOldest, metrics: metrics}
+}
+
+// relay returns the entries `source` streams to `in`, through the buffer,
+// except for a Pub/Sub subscription. Its messages are acknowledged once
+// their entry is taken by the worker: those not taken yet are left in the
+// subscription, which keeps them across restarts, rather than buffered and
+// lost with the process.
+func (b *logBuffer) relay(ctx context.Context, source LogSource, in <-chan LogEntry) <-chan LogEntry {
+	if _, ok := source.(*pubSubLogSource); ok {
+		return in
+	}
+	out := make(chan LogEntry)
+	go b.run(ctx, in, out)
+	return out
+}
+
+// run relays the entries received on `in` to `out`, which is closed once
+// `in` is closed and every buffered entry was taken, or once `ctx` is done.
+func (b *logBuffer) run(ctx context.Context, in <-chan LogEntry, out chan<- LogEntry) {
+	defer close(out)
+	defer b.metrics.logBufferFill.WithLabelValues(b.network, b.worker).Set(0)
+	ticker := time.NewTicker(logBufferCheckInterval)
+	defer ticker.Stop()
+
+	var queue []LogEntry
+	for in != nil || len(queue) > 0 {
+		recv := in
+		if len(queue) >= b.size && !b.dropOldest {
+			recv = nil
+		}
+		var send chan<- LogEntry
+		var next LogEntry
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-recv:
+			if !ok {
+				in = nil
+				break
+			}
+			if len(queue) >= b.size {
+				queue = queue[1:]
+				b.metrics.logBufferDropped.WithLabelValues(b.network, b.worker).Inc()
+			}
+			queue = append(queue, entry)
+		case send <- next:
+			queue = queue[1:]
+		case <-ticker.C:
+		}
+		b.observe(len(queue), time.Now())
+	}
+}
+
+// observe records the fill level of the buffer, warning once it has been
+// near full for logBufferWarnAfter, until it is half empty again.
+func (b *logBuffer) observe(n int, now time.Time) {
+	b.metrics.logBufferFill.WithLabelValues(b.network, b.worker).Set(float64(n))
+	switch {
+	case n*10 >= b.size*9:
+		if b.nearFullSince.IsZero() {
+			b.nearFullSince = now
+		}
+		if !b.warned && now.Sub(b.nearFullSince) >= logBufferWarnAfter {
+			b.warned = true
+			slog.Warn("log buffer near full, the worker is not keeping up", "network", b.network, "worker", b.worker, "buffered", n, "size", b.size, "drop_oldest", b.dropOldest)
+		}
+	case n*2 <= b.size:
+		if b.warned {
+			slog.Info("log buffer drained", "network", b.network, "worker", b.worker, "buffered", n)
+		}
+		b.nearFullSince, b.warned = time.Time{}, false
+	}
+}
@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogBuffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		dropOldest bool
+		// accepted is the number of entries taken while the worker is busy.
+		accepted int
+		want     []string
+		dropped  float64
+	}{
+		{"waiting for the worker", false, 3, []string{"0", "1", "2", "3", "4"}, 0},
+		{"dropping the oldest", true, 5, []string{"2", "3", "4"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newMetrics()
+			in, out := make(chan LogEntry), make(chan LogEntry)
+			go newLogBuffer("testnet", "pd", 3, tt.dropOldest, metrics).run(context.Background(), in, out)
+
+			// The worker is busy: the source sends until the buffer holds it.
+			accepted := 0
+			for ; accepted < 5; accepted++ {
+				select {
+				case in <- NewLogEntry("pod-0", strconv.Itoa(accepted), time.Time{}):
+					continue
+				case <-time.After(50 * time.Millisecond):
+				}
+				break
+			}
+			if accepted != tt.accepted {
+				t.Errorf("%d entries taken while the worker was busy, want %d", accepted, tt.accepted)
+			}
+			if got := counterValue(t, metrics.logBufferFill); got != 3 {
+				t.Errorf("fill level = %v, want 3", got)
+			}
+
+			// The worker catches up.
+			go func() {
+				for i := accepted; i < 5; i++ {
+					in <- NewLogEntry("pod-0", strconv.Itoa(i), time.Time{})
+				}
+				close(in)
+			}()
+			var got []string
+			for entry := range out {
+				got = append(got, entry.payload)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("relayed %q, want %q", got, tt.want)
+			}
+			if got := counterValue(t, metrics.logBufferDropped); got != tt.dropped {
+				t.Errorf("counted %v dropped entries, want %v", got, tt.dropped)
+			}
+			if got := counterValue(t, metrics.logBufferFill); got != 0 {
+				t.Errorf("fill level = %v once drained, want 0", got)
+			}
+		})
+	}
+}
+
+func TestLogBufferCancelled(t *testing.T) {
+	in, out := make(chan LogEntry), make(chan LogEntry)
+	ctx, cancel := context.WithCancel(context.Background())
+	go newLogBuffer("testnet", "tm", 3, false, newMetrics()).run(ctx, in, out)
+	in <- NewLogEntry("pod-0", "buffered", time.Time{})
+	cancel()
+
+	select {
+	case <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatal("output still open once cancelled")
+	}
+}
+
+func TestLogBufferObserve(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := newLogBuffer("testnet", "tm", 10, false, newMetrics())
+	tests := []struct {
+		buffered int
+		after    time.Duration
+		// want is the message logged, if any.
+		want string
+	}{
+		{9, 0, ""},
+		{10, logBufferWarnAfter / 2, ""},
+		{9, logBufferWarnAfter, "log buffer near full"},
+		// Warned once.
+		{10, 2 * logBufferWarnAfter, ""},
+		// Not drained yet.
+		{6, 2 * logBufferWarnAfter, ""},
+		{5, 3 * logBufferWarnAfter, "log buffer drained"},
+		// Near full again, not long enough.
+		{9, 4 * logBufferWarnAfter, ""},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		b.observe(tt.buffered, start.Add(tt.after))
+		if got := logs.String(); (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("observe(%d) after %s logged %q, want %q", tt.buffered, tt.after, got, tt.want)
+		}
+	}
+}
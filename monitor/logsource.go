@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+)
+
+// LogSource pushes log entries to `out`, closing it once the source is
+// exhausted or `ctx` is cancelled.
+type LogSource interface {
+	Stream(ctx context.Context, out chan<- LogEntry) error
+}
+
+// gcpLogSource tails the GCP log entries matching a filter, across one or
+// more projects.
+type gcpLogSource struct {
+	ProjectIDs []string
+	Filter     string
+	// Credentials authenticate the stream, nil for the Application Default
+	// Credentials.
+	Credentials option.ClientOption
+	// PayloadField is the field holding the log line in structured payloads,
+	// defaulting to "message".
+	PayloadField string
+	Config       StreamConfig
+	// Notifier, if set, is told when the stream cannot be established.
+	Notifier Notifier
+	// client tails the logs instead of a GCP logging client authenticated
+	// with Credentials, if set.
+	client tailClient
+	// tracer records a span for every tail stream, if set.
+	tracer trace.Tracer
+	// metrics count the established streams, if set.
+	metrics *metrics
+}
+
+func (s *gcpLogSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	field := s.PayloadField
+	if field == "" {
+		field = defaultPayloadField
+	}
+	return streamLogsWithFilter(ctx, s.client, s.Credentials, s.ProjectIDs, s.Filter, field, s.Config, s.Notifier, s.tracer, s.metrics, out)
+}
+
+// replayPodName is the pod plain-text replayed lines are attributed to.
+const replayPodName = "replay"
+
+// fileLogSource replays newline-delimited log entries from a file, or from
+// stdin when the path is "-". A line is either a raw payload, or a JSON
+// object of the form {"metadata": {"pod_name": "..."}, "payload": "..."},
+// optionally with an RFC 3339 "timestamp".
+type fileLogSource struct {
+	Path string
+}
+
+type replayEntry struct {
+	Metadata  map[string]string `json:"metadata"`
+	Payload   string            `json:"payload"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func (s *fileLogSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	defer close(out)
+
+	var r io.Reader = os.Stdin
+	if s.Path != "-" {
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return fmt.Errorf("opening replay file: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		select {
+		case out <- parseReplayLine(line):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading replay file: %v", err)
+	}
+
+	slog.Info("replay file exhausted", "path", s.Path)
+	return nil
+}
+
+func parseReplayLine(line string) LogEntry {
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var entry replayEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			return LogEntry{metadata: entry.Metadata, payload: entry.Payload, timestamp: entry.Timestamp}
+		}
+	}
+	return LogEntry{
+		metadata: map[string]string{"pod_name": replayPodName},
+		payload:  line,
+	}
+}
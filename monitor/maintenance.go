@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maintenanceIncidentKey identifies the maintenance window in the messages
+// entering and leaving it.
+const maintenanceIncidentKey = "maintenance"
+
+// maintenance is a window, e.g. a planned chain upgrade, during which the
+// alerts below critical are expected and suppressed. They are still counted
+// in the metrics and recorded in the audit log. A message is posted when
+// entering and when leaving the window.
+type maintenance struct {
+	// window is the length of the windows opened without an end.
+	window time.Duration
+
+	mu sync.Mutex
+	// notifier receives the messages entering and leaving the window.
+	notifier Notifier
+	// until is the end of the current window, zero outside of one.
+	until time.Time
+	timer *time.Timer
+}
+
+func newMaintenance(window time.Duration) *maintenance {
+	return &maintenance{window: window}
+}
+
+// setNotifier sets the notifier receiving the messages entering and leaving
+// the window.
+func (m *maintenance) setNotifier(notifier Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifier = notifier
+}
+
+// Active reports whether `now` is within a maintenance window.
+func (m *maintenance) Active(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return now.Before(m.until)
+}
+
+// Until returns the end of the current window, zero outside of one.
+func (m *maintenance) Until() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !time.Now().Before(m.until) {
+		return time.Time{}
+	}
+	return m.until
+}
+
+// Enable opens a window ending at `until`, or extends the current one to
+// it. A zero `until` opens the default window.
+func (m *maintenance) Enable(until time.Time) {
+	if until.IsZero() {
+		until = time.Now().Add(m.window)
+	}
+
+	m.mu.Lock()
+	entering := !time.Now().Before(m.until)
+	m.until = until
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(time.Until(until), func() { m.expire(until) })
+	notifier := m.notifier
+	m.mu.Unlock()
+
+	slog.Warn("maintenance window opened", "event", "maintenance", "until", until, "extended", !entering)
+	if notifier != nil {
+		title := "Maintenance started"
+		if !entering {
+			title = "Maintenance extended"
+		}
+		notify(context.Background(), notifier, Message{
+			Severity:    SeverityWarning,
+			Title:       title,
+			Body:        fmt.Sprintf("alerts below critical are suppressed until %s", until.UTC().Format(time.RFC3339)),
+			IncidentKey: maintenanceIncidentKey,
+		})
+	}
+}
+
+// Disable closes the current window, if any.
+func (m *maintenance) Disable() {
+	m.close(time.Time{}, "maintenance ended early")
+}
+
+// Toggle closes the current window, or opens the default one.
+func (m *maintenance) Toggle() {
+	if m.Active(time.Now()) {
+		m.Disable()
+	} else {
+		m.Enable(time.Time{})
+	}
+}
+
+// expire closes the window ending at `until`, unless it was changed since.
+func (m *maintenance) expire(until time.Time) {
+	m.close(until, "the maintenance window expired")
+}
+
+// close closes the current window, if it ends at `until` when set.
+func (m *maintenance) close(until time.Time, reason string) {
+	m.mu.Lock()
+	if m.until.IsZero() || (!until.IsZero() && !m.until.Equal(until)) {
+		m.mu.Unlock()
+		return
+	}
+	m.until = time.Time{}
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	notifier := m.notifier
+	m.mu.Unlock()
+
+	slog.Info("maintenance window closed", "event", "maintenance_ended", "reason", reason)
+	if notifier != nil {
+		notify(context.Background(), notifier, Message{
+			Severity:    SeverityInfo,
+			Title:       "Maintenance ended",
+			Body:        reason + ", alerts are delivered again",
+			IncidentKey: maintenanceIncidentKey,
+			Resolved:    true,
+		})
+	}
+}
+
+type maintenanceResponse struct {
+	Enabled bool       `json:"enabled"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// Handler serves `GET /maintenance`, the current window, and
+// `POST /maintenance?enabled=true&until=...`, which opens a window ending at
+// `until`, an RFC 3339 time or a duration from now, or the default one
+// without it. `enabled=false` closes the window.
+func (m *maintenance) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			query := req.URL.Query()
+			enabled, err := strconv.ParseBool(query.Get("enabled"))
+			if err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+			if !enabled {
+				m.Disable()
+				break
+			}
+			until, err := parseMaintenanceUntil(query.Get("until"), time.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			m.Enable(until)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := maintenanceResponse{}
+		if until := m.Until(); !until.IsZero() {
+			resp.Enabled, resp.Until = true, &until
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// parseMaintenanceUntil parses the end of a maintenance window, an RFC 3339
+// time or a duration from `now`. It returns a zero time if `s` is empty.
+func parseMaintenanceUntil(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	until, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		d, derr := time.ParseDuration(s)
+		if derr != nil {
+			return time.Time{}, fmt.Errorf("until must be an RFC 3339 time or a duration, got %q", s)
+		}
+		until = now.Add(d)
+	}
+	if !until.After(now) {
+		return time.Time{}, fmt.Errorf("until must be in the future")
+	}
+	return until, nil
+}
+
+// maintenanceNotifier drops the messages below critical during a
+// maintenance window.
+type maintenanceNotifier struct {
+	maintenance *maintenance
+	notifier    Notifier
+	metrics     *metrics
+}
+
+func (n maintenanceNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Severity < SeverityCritical && n.maintenance.Active(time.Now()) {
+		n.metrics.alertsSuppressed.WithLabelValues(msg.Severity.String()).Inc()
+		slog.Info("alert suppressed by maintenance", "title", msg.Title, "severity", msg.Severity.String())
+		return nil
+	}
+	return n.notifier.Notify(ctx, msg)
+}
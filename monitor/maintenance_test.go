@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceUntil(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2h", now.Add(2 * time.Hour), false},
+		{"2024-03-01T14:00:00Z", now.Add(2 * time.Hour), false},
+		{"2024-03-01T11:00:00Z", time.Time{}, true},
+		{"-1h", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMaintenanceUntil(tt.s, now)
+		if !got.Equal(tt.want) || (err != nil) != tt.wantErr {
+			t.Errorf("parseMaintenanceUntil(%q) = %v, %v, want %v, error: %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMaintenanceNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		severity Severity
+		active   bool
+		want     bool
+	}{
+		{"outside of a window", SeverityInfo, false, true},
+		{"milestone suppressed", SeverityInfo, true, false},
+		{"lag suppressed", SeverityWarning, true, false},
+		{"restart suppressed", SeverityError, true, false},
+		{"mismatch delivered", SeverityCritical, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMaintenance(time.Hour)
+			if tt.active {
+				m.Enable(time.Time{})
+				defer m.Disable()
+			}
+			delivered := &recordingNotifier{}
+			metrics := newMetrics()
+			n := maintenanceNotifier{maintenance: m, notifier: delivered, metrics: metrics}
+			if err := n.Notify(context.Background(), Message{Severity: tt.severity, Title: "alert"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(delivered.titles()) == 1; got != tt.want {
+				t.Errorf("delivered: %v, want %v", got, tt.want)
+			}
+			wantSuppressed := 0.0
+			if !tt.want {
+				wantSuppressed = 1
+			}
+			if got := counterValue(t, metrics.alertsSuppressed); got != wantSuppressed {
+				t.Errorf("counted %v suppressed alerts, want %v", got, wantSuppressed)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	notifier := &recordingNotifier{}
+	m := newMaintenance(time.Hour)
+	m.setNotifier(notifier)
+
+	m.Toggle()
+	if !m.Active(time.Now()) {
+		t.Fatal("Active() = false once toggled on, want true")
+	}
+	m.Enable(time.Now().Add(2 * time.Hour))
+	if until := m.Until(); time.Until(until) < time.Hour+time.Minute {
+		t.Errorf("Until() = %v once extended, want in 2 hours", until)
+	}
+	m.Toggle()
+	if m.Active(time.Now()) {
+		t.Error("Active() = true once toggled off, want false")
+	}
+	// Closing again is a no-op.
+	m.Disable()
+
+	// A window expires on its own.
+	m.Enable(time.Now().Add(20 * time.Millisecond))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(notifier.titles()) < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	want := []string{"Maintenance started", "Maintenance extended", "Maintenance ended", "Maintenance started", "Maintenance ended"}
+	if got := notifier.titles(); !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	m := newMaintenance(time.Hour)
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		method      string
+		query       string
+		wantStatus  int
+		wantEnabled bool
+	}{
+		{http.MethodGet, "", http.StatusOK, false},
+		{http.MethodPost, "?enabled=true&until=30m", http.StatusOK, true},
+		{http.MethodGet, "", http.StatusOK, true},
+		{http.MethodPost, "?enabled=maybe", http.StatusBadRequest, true},
+		{http.MethodPost, "?enabled=true&until=-1h", http.StatusBadRequest, true},
+		{http.MethodPost, "?enabled=false", http.StatusOK, false},
+		{http.MethodDelete, "", http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s %s = %s, want %d", tt.method, tt.query, resp.Status, tt.wantStatus)
+		}
+		if resp.StatusCode == http.StatusOK {
+			var body maintenanceResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Errorf("%s %s: decoding the response: %v", tt.method, tt.query, err)
+			}
+			if body.Enabled != tt.wantEnabled || (body.Until != nil) != tt.wantEnabled {
+				t.Errorf("%s %s = %+v, want enabled: %v", tt.method, tt.query, body, tt.wantEnabled)
+			}
+		}
+		resp.Body.Close()
+		if got := m.Active(time.Now()); got != tt.wantEnabled {
+			t.Errorf("%s %s: Active() = %v, want %v", tt.method, tt.query, got, tt.wantEnabled)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the metrics of a monitor, registered with a registry of its
+// own so that the monitors embedded in a process do not share them.
+type metrics struct {
+	commitLogsParsed       *prometheus.CounterVec
+	commitTxs              *prometheus.CounterVec
+	rootMismatches         *prometheus.CounterVec
+	heightRegressions      *prometheus.CounterVec
+	podConflicts           *prometheus.CounterVec
+	podLag                 *prometheus.GaugeVec
+	highestConfirmedHeight *prometheus.GaugeVec
+	discordFailures        prometheus.Counter
+	activeStreams          prometheus.Gauge
+	entriesWithoutPodName  *prometheus.CounterVec
+	kafkaEventsDropped     prometheus.Counter
+	numTxsMismatches       *prometheus.CounterVec
+	notifyDeliveries       *prometheus.CounterVec
+	ingestedEntries        *prometheus.CounterVec
+	referenceDivergences   *prometheus.CounterVec
+	notifyBreakerState     *prometheus.GaugeVec
+	notifyBreakerDropped   *prometheus.CounterVec
+	logBufferFill          *prometheus.GaugeVec
+	logBufferDropped       *prometheus.CounterVec
+	explorerChecks         *prometheus.CounterVec
+	alertsSuppressed       *prometheus.CounterVec
+	notifyDuration         *prometheus.HistogramVec
+}
+
+// newMetrics returns the metrics of a monitor, registered nowhere yet.
+func newMetrics() *metrics {
+	return &metrics{
+		commitLogsParsed:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_commit_logs_parsed_total", Help: "Number of commit logs parsed, by pod."}, []string{"pod"}),
+		commitTxs:              prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_commit_txs_total", Help: "Number of transactions in the committed blocks, by reporting pod."}, []string{"pod"}),
+		rootMismatches:         prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_root_mismatches_total", Help: "Number of root mismatches detected, by network."}, []string{"network"}),
+		heightRegressions:      prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_height_regressions_total", Help: "Number of times a pod reported a height below the one it had reached, by pod."}, []string{"pod"}),
+		podConflicts:           prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_pod_conflicting_roots_total", Help: "Number of times a pod reported a root different from the one it had reported at the same height, by pod."}, []string{"pod"}),
+		podLag:                 prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "apphash_pod_lag_blocks", Help: "Number of blocks a pod is behind the furthest-ahead pod of its network."}, []string{"pod"}),
+		highestConfirmedHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "apphash_confirmed_height", Help: "Highest height at which a quorum of pods reported the same root, by network."}, []string{"network"}),
+		discordFailures:        prometheus.NewCounter(prometheus.CounterOpts{Name: "apphash_discord_delivery_failures_total", Help: "Number of Discord messages that could not be delivered."}),
+		activeStreams:          prometheus.NewGauge(prometheus.GaugeOpts{Name: "apphash_active_log_streams", Help: "Number of currently established log streams."}),
+		entriesWithoutPodName:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_entries_without_pod_name_total", Help: "Number of log entries skipped for lacking a pod name label, by worker."}, []string{"worker"}),
+		kafkaEventsDropped:     prometheus.NewCounter(prometheus.CounterOpts{Name: "apphash_kafka_events_dropped_total", Help: "Number of events that could not be published to Kafka."}),
+		numTxsMismatches:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_num_txs_mismatches_total", Help: "Number of heights at which pods agreed on the root but not on the number of transactions, by network."}, []string{"network"}),
+		notifyDeliveries:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_notify_deliveries_total", Help: "Number of messages delivered to a notification backend, by backend, result and HTTP status of the last response."}, []string{"backend", "result", "status"}),
+		ingestedEntries:        prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_ingested_entries_total", Help: "Number of log entries pushed to the ingest endpoint, by network and worker."}, []string{"network", "worker"}),
+		referenceDivergences:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_reference_divergences_total", Help: "Number of roots that differed from the one of the reference pod, by network and pod."}, []string{"network", "pod"}),
+		notifyBreakerState:     prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "apphash_notify_breaker_state", Help: "State of the circuit breaker of a notification backend: 0 closed, 1 half-open, 2 open."}, []string{"backend"}),
+		notifyBreakerDropped:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_notify_breaker_dropped_total", Help: "Number of alerts dropped while the circuit breaker of a notification backend was open, by backend."}, []string{"backend"}),
+		logBufferFill:          prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "apphash_log_buffer_entries", Help: "Number of log entries buffered between a log source and its worker, by network and worker."}, []string{"network", "worker"}),
+		logBufferDropped:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_log_buffer_dropped_total", Help: "Number of log entries dropped from a full log buffer, by network and worker."}, []string{"network", "worker"}),
+		explorerChecks:         prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_explorer_checks_total", Help: "Number of confirmed roots checked with the explorer, by network and result: agree, mismatch or error."}, []string{"network", "result"}),
+		alertsSuppressed:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "apphash_alerts_suppressed_total", Help: "Number of alerts suppressed during a maintenance window, by severity."}, []string{"severity"}),
+		notifyDuration:         prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "apphash_notify_duration_seconds", Help: "Time taken to deliver a message to a notification backend, by backend.", Buckets: notifyDurationBuckets}, []string{"backend"}),
+	}
+}
+
+// metricsOrUnregistered returns `m`, or metrics registered nowhere if it is
+// nil, e.g. for the log sources of a filter test.
+func metricsOrUnregistered(m *metrics) *metrics {
+	if m == nil {
+		return newMetrics()
+	}
+	return m
+}
+
+// notifyDurationBuckets span from a fast webhook to a request about to time
+// out.
+var notifyDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// newMetricsRegistry returns a registry of `m`, along with the metrics of
+// the Go runtime and the process. Every sample is labelled with `envTag`, if
+// set.
+func newMetricsRegistry(m *metrics, envTag string) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	var registerer prometheus.Registerer = registry
+	if envTag != "" {
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels{"env": envTag}, registry)
+	}
+	registerer.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.commitLogsParsed,
+		m.commitTxs,
+		m.rootMismatches,
+		m.heightRegressions,
+		m.podConflicts,
+		m.podLag,
+		m.highestConfirmedHeight,
+		m.discordFailures,
+		m.activeStreams,
+		m.entriesWithoutPodName,
+		m.kafkaEventsDropped,
+		m.numTxsMismatches,
+		m.notifyDeliveries,
+		m.notifyDuration,
+		m.notifyBreakerState,
+		m.notifyBreakerDropped,
+		m.logBufferFill,
+		m.logBufferDropped,
+		m.alertsSuppressed,
+		m.ingestedEntries,
+		m.referenceDivergences,
+		m.explorerChecks,
+	)
+	return registry
+}
+
+// metricsHandler serves the metrics of `registry` in the Prometheus
+// exposition format.
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrape returns the value of the sample `sample`, e.g.
+// `metric{label="value"}`, served by `url`, zero if absent.
+func scrape(t *testing.T, url, sample string) float64 {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == sample {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parsing %s: %v", scanner.Text(), err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestMetricsMismatchCounter(t *testing.T) {
+	metrics := newMetrics()
+	server := httptest.NewServer(metricsHandler(newMetricsRegistry(metrics, "")))
+	defer server.Close()
+	sample := `apphash_root_mismatches_total{network="` + t.Name() + `"}`
+
+	processEntries(t, testConfig(t), tmDeps{Metrics: metrics},
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "bb"),
+	)
+	if got := scrape(t, server.URL+"/metrics", sample); got != 1 {
+		t.Errorf("%s = %v after a mismatch, want 1", sample, got)
+	}
+}
+
+func TestMetricsEnvLabel(t *testing.T) {
+	metrics := newMetrics()
+	server := httptest.NewServer(metricsHandler(newMetricsRegistry(metrics, "staging")))
+	defer server.Close()
+
+	metrics.discordFailures.Inc()
+	if v := scrape(t, server.URL, `apphash_discord_delivery_failures_total{env="staging"}`); v != 1 {
+		t.Errorf("apphash_discord_delivery_failures_total{env=\"staging\"} = %v, want 1", v)
+	}
+}
+
+func TestMetricsPerMonitor(t *testing.T) {
+	first, second := New(Config{}, nil), New(Config{}, nil)
+	servers := make([]*httptest.Server, 2)
+	for i, m := range []*Monitor{first, second} {
+		servers[i] = httptest.NewServer(metricsHandler(newMetricsRegistry(m.metrics, "")))
+		defer servers[i].Close()
+	}
+
+	first.metrics.discordFailures.Inc()
+	const sample = "apphash_discord_delivery_failures_total"
+	if v := scrape(t, servers[0].URL, sample); v != 1 {
+		t.Errorf("%s = %v for the first monitor, want 1", sample, v)
+	}
+	if v := scrape(t, servers[1].URL, sample); v != 0 {
+		t.Errorf("%s = %v for the second monitor, want 0", sample, v)
+	}
+}
+
+// counterValue returns the sum of the samples of the counter or gauge `c`,
+// across its labels.
+func counterValue(t *testing.T, c prometheus.Collector) float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			sum += metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+		}
+	}
+	return sum
+}
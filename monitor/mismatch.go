@@ -0,0 +1,162 @@
+package monitor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// divergence is a report whose root differs from one already reported at
+// the same height.
+type divergence struct {
+	Height  int64
+	Records []rootHashRecord
+	At      time.Time
+}
+
+// mismatchConfirmer holds divergences until enough of them were observed
+// within a window to rule out a stray log entry, e.g. a late duplicate from
+// before a node restart. A genuine fork keeps producing divergences, either
+// from several pods at one height or from the same pod at later heights.
+type mismatchConfirmer struct {
+	confirmations int
+	window        time.Duration
+	pending       []divergence
+}
+
+func newMismatchConfirmer(confirmations int, window time.Duration) *mismatchConfirmer {
+	return &mismatchConfirmer{
+		confirmations: confirmations,
+		window:        window,
+	}
+}
+
+// Observe records a divergence at `height`, where `records` are all the
+// reports seen at that height. Once the divergences observed within the
+// window reach the confirmation threshold, they are returned and cleared.
+func (m *mismatchConfirmer) Observe(height int64, records []rootHashRecord, now time.Time) ([]divergence, bool) {
+	kept := m.pending[:0]
+	for _, d := range m.pending {
+		if now.Sub(d.At) < m.window {
+			kept = append(kept, d)
+		}
+	}
+	m.pending = append(kept, divergence{Height: height, Records: records, At: now})
+
+	if len(m.pending) < m.confirmations {
+		return nil, false
+	}
+	confirmed := m.pending
+	m.pending = nil
+	return confirmed, true
+}
+
+// Reset forgets the divergences awaiting confirmation.
+func (m *mismatchConfirmer) Reset() {
+	m.pending = nil
+}
+
+// Pending returns the number of divergences awaiting confirmation.
+func (m *mismatchConfirmer) Pending() int {
+	return len(m.pending)
+}
+
+// divergencesString lists the roots reported at every height involved in
+// `divergences`, using the latest reports for each height.
+func divergencesString(divergences []divergence) string {
+	latest := make(map[int64][]rootHashRecord)
+	for _, d := range divergences {
+		latest[d.Height] = d.Records
+	}
+	if len(latest) == 1 {
+		return knownRootHashesString(divergences[len(divergences)-1].Records)
+	}
+
+	heights := make([]int64, 0, len(latest))
+	for height := range latest {
+		heights = append(heights, height)
+	}
+	slices.Sort(heights)
+
+	parts := make([]string, 0, len(heights))
+	for _, height := range heights {
+		parts = append(parts, fmt.Sprintf("height %d:\n%s", height, knownRootHashesString(latest[height])))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// mismatchAlerts remembers the heights a mismatch was alerted at, so that
+// pods reporting the same height afterwards do not raise new alerts. Their
+// reports are rolled into a single summary per height instead.
+type mismatchAlerts struct {
+	alerted    map[int64]bool
+	summarized map[int64]bool
+	updates    map[int64][]rootHashRecord
+}
+
+func newMismatchAlerts() *mismatchAlerts {
+	return &mismatchAlerts{
+		alerted:    make(map[int64]bool),
+		summarized: make(map[int64]bool),
+		updates:    make(map[int64][]rootHashRecord),
+	}
+}
+
+// Alerted reports whether a mismatch was already alerted at `height`.
+func (m *mismatchAlerts) Alerted(height int64) bool {
+	return m.alerted[height]
+}
+
+// MarkAlerted records that the mismatches of `divergences` were alerted.
+func (m *mismatchAlerts) MarkAlerted(divergences []divergence) {
+	for _, d := range divergences {
+		m.alerted[d.Height] = true
+	}
+}
+
+// Heights returns the heights a mismatch was alerted at, in ascending order.
+func (m *mismatchAlerts) Heights() []int64 {
+	return sortedHeights(m.alerted)
+}
+
+// Restore marks `heights` as alerted, e.g. by a previous run. Their reports
+// are not summarized again.
+func (m *mismatchAlerts) Restore(heights []int64) {
+	for _, height := range heights {
+		m.alerted[height] = true
+		m.summarized[height] = true
+	}
+}
+
+// Update records the reports at an already alerted `height`, to be included
+// in its summary. It returns false once the height was summarized.
+func (m *mismatchAlerts) Update(height int64, records []rootHashRecord) bool {
+	if m.summarized[height] {
+		return false
+	}
+	m.updates[height] = records
+	return true
+}
+
+// Summaries returns the reports received at every alerted height since the
+// alert, and marks these heights as summarized.
+func (m *mismatchAlerts) Summaries() map[int64][]rootHashRecord {
+	updates := m.updates
+	for height := range updates {
+		m.summarized[height] = true
+	}
+	m.updates = make(map[int64][]rootHashRecord)
+	return updates
+}
+
+// Prune forgets the heights at or below `floor`.
+func (m *mismatchAlerts) Prune(floor int64) {
+	for height := range m.alerted {
+		if height <= floor {
+			delete(m.alerted, height)
+			delete(m.summarized, height)
+			delete(m.updates, height)
+		}
+	}
+}
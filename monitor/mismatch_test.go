@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMismatchAlerts(t *testing.T) {
+	reports := []rootHashRecord{
+		{PodName: "pod-0", Root: "aa"},
+		{PodName: "pod-1", Root: "bb"},
+		{PodName: "pod-2", Root: "cc"},
+	}
+	m := newMismatchAlerts()
+	m.MarkAlerted([]divergence{{Height: 10, Records: reports[:2]}})
+
+	tests := []struct {
+		name    string
+		height  int64
+		alerted bool
+	}{
+		{"alerted height", 10, true},
+		{"other height", 11, false},
+	}
+	for _, tt := range tests {
+		if got := m.Alerted(tt.height); got != tt.alerted {
+			t.Errorf("%s: Alerted(%d) = %v, want %v", tt.name, tt.height, got, tt.alerted)
+		}
+	}
+
+	// The third disagreeing pod is rolled into the summary of height 10.
+	if !m.Update(10, reports) {
+		t.Fatal("Update(10) = false before the summary, want true")
+	}
+	summaries := m.Summaries()
+	if len(summaries) != 1 || len(summaries[10]) != 3 {
+		t.Fatalf("Summaries() = %v, want the 3 reports at height 10", summaries)
+	}
+	if m.Update(10, append(reports, rootHashRecord{PodName: "pod-3", Root: "dd"})) {
+		t.Error("Update(10) = true once summarized, want false")
+	}
+	if got := m.Summaries(); len(got) != 0 {
+		t.Errorf("Summaries() = %v once summarized, want none", got)
+	}
+
+	m.Prune(10)
+	if m.Alerted(10) {
+		t.Error("Alerted(10) = true once pruned, want false")
+	}
+}
+
+func TestMismatchAlertsRestore(t *testing.T) {
+	m := newMismatchAlerts()
+	m.Restore([]int64{12, 10})
+	if got, want := m.Heights(), []int64{10, 12}; !slices.Equal(got, want) {
+		t.Errorf("Heights() = %v, want %v", got, want)
+	}
+	// The previous run already alerted these heights, summarizing them
+	// again would repeat its alerts.
+	if m.Update(10, []rootHashRecord{{PodName: "pod-2", Root: "cc"}}) {
+		t.Error("Update(10) = true for a restored height, want false")
+	}
+}
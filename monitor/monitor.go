@@ -0,0 +1,1707 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// LogEntry is a line logged by a pod, as read by a LogSource.
+type LogEntry struct {
+	metadata map[string]string
+	payload  string
+	// timestamp is when the line was logged, zero if unknown.
+	timestamp time.Time
+	// severity is the GCP severity of the entry, DEFAULT if unknown.
+	severity ltype.LogSeverity
+	// generation counts the streams opened by the source before the one
+	// the entry was received on, to tell entries redelivered after a
+	// reconnect.
+	generation int
+}
+
+// NewLogEntry returns the entry of `payload`, logged by `podName` at
+// `timestamp`, zero if unknown, for the log sources of an embedder.
+func NewLogEntry(podName, payload string, timestamp time.Time) LogEntry {
+	return LogEntry{metadata: map[string]string{podNameLabel: podName}, payload: payload, timestamp: timestamp}
+}
+
+// podNameLabel is the resource label holding the name of the pod that logged
+// an entry.
+const podNameLabel = "pod_name"
+
+// podName returns the name of the pod that logged the entry, read from the
+// pod_name label, else from `fallbackLabel` if set.
+func (e LogEntry) podName(fallbackLabel string) (string, bool) {
+	if podName := e.metadata[podNameLabel]; podName != "" {
+		return podName, true
+	}
+	if fallbackLabel != "" {
+		if podName := e.metadata[fallbackLabel]; podName != "" {
+			return podName, true
+		}
+	}
+	return "", false
+}
+
+type LogData struct {
+	Height int64
+	// Hash and Root are trimmed and lowercased, so that nodes logging hex
+	// in different cases are not reported as diverging.
+	Hash   string
+	Root   string
+	NumTxs int64
+	// NumTxsUnknown is set when the commit log does not count the
+	// transactions of the block, NumTxs is then zero.
+	NumTxsUnknown bool
+	PodName       string
+	// Timestamp is when the commit was logged, zero if unknown.
+	Timestamp time.Time
+}
+
+type rootHashRecord struct {
+	PodName       string
+	Root          string
+	NumTxs        int64
+	NumTxsUnknown bool
+	Timestamp     time.Time
+}
+
+// compileCommitLogPattern compiles a commit log pattern, checking that it has
+// the named groups `parseCommitLog` relies on. The `height` group is
+// required, along with either `root`, the app hash in the header of the
+// block, or `next_root`, the app hash resulting from the block. `hash` and
+// `num_txs` are optional.
+func compileCommitLogPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit log pattern: %v", err)
+	}
+
+	var missing []string
+	if re.SubexpIndex("height") < 0 {
+		missing = append(missing, "height")
+	}
+	if re.SubexpIndex("root") < 0 && re.SubexpIndex("next_root") < 0 {
+		missing = append(missing, "root or next_root")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("commit log pattern is missing the named group(s) %s", strings.Join(missing, ", "))
+	}
+
+	return re, nil
+}
+
+// errNoCommitLog is returned by parseCommitLog for lines that are not commit
+// logs, as opposed to commit logs with malformed fields.
+var errNoCommitLog = errors.New("not a commit log")
+
+// parseCommitLog parses a commit log line. The line carries no timestamp, so
+// the time it was logged at is passed alongside.
+func parseCommitLog(re *regexp.Regexp, podName, logEntry string, timestamp time.Time) (*LogData, error) {
+	match := re.FindStringSubmatch(logEntry)
+
+	if len(match) == 0 {
+		return nil, errNoCommitLog
+	}
+
+	group := func(name string) string {
+		if i := re.SubexpIndex(name); i >= 0 {
+			return match[i]
+		}
+		return ""
+	}
+
+	height, err := strconv.ParseInt(group("height"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing height: %v", err)
+	}
+
+	hash := strings.ToLower(strings.TrimSpace(group("hash")))
+	root := strings.ToLower(strings.TrimSpace(group("root")))
+	// The app hash resulting from a block is the root of the next one,
+	// whose transactions the line does not count.
+	next := re.SubexpIndex("next_root") >= 0
+	if next {
+		height++
+		hash = ""
+		root = strings.ToLower(strings.TrimSpace(group("next_root")))
+	}
+	if root == "" {
+		return nil, fmt.Errorf("empty root")
+	}
+
+	var numTxs int64
+	numTxsUnknown := next || re.SubexpIndex("num_txs") < 0
+	if s := group("num_txs"); s != "" && !numTxsUnknown {
+		numTxs, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing num_txs: %v", err)
+		}
+	}
+
+	return &LogData{
+		Height:        height,
+		Hash:          hash,
+		Root:          root,
+		NumTxs:        numTxs,
+		NumTxsUnknown: numTxsUnknown,
+		PodName:       podName,
+		Timestamp:     timestamp,
+	}, nil
+}
+
+// Monitor checks that the pods of the configured networks agree on the root
+// of every block, and forwards the errors pd logs, alerting through its
+// notifier. It is what the check-apphash binary runs, and can be embedded in
+// another service.
+type Monitor struct {
+	cfg         *liveConfig
+	notifier    Notifier
+	maintenance *maintenance
+	// tracer records the spans of the pipeline, set by Run.
+	tracer trace.Tracer
+	// metrics are served on cfg.MetricsAddr.
+	metrics *metrics
+
+	// mu serializes the reloads, see Reload.
+	mu sync.Mutex
+	// sources are the GCP log sources reconnected when their filter is
+	// reloaded.
+	sources []*reloadableSource
+}
+
+// New creates a monitor of the networks of `cfg`, usually loaded with
+// LoadConfig, alerting through `notifier`. A nil notifier delivers to the
+// backends configured in `cfg`, e.g. DiscordWebhookURL. Whichever it is, its
+// alerts are rate limited, and batched if cfg.BatchAlerts is set.
+func New(cfg Config, notifier Notifier) *Monitor {
+	setDefaults(&cfg)
+	return &Monitor{cfg: newLiveConfig(&cfg), notifier: notifier, maintenance: newMaintenance(cfg.MaintenanceWindow), metrics: newMetrics()}
+}
+
+// setDefaults sets the settings of `cfg` left unset to their defaults.
+func setDefaults(cfg *Config) {
+	if cfg.StreamConfig == (StreamConfig{}) {
+		cfg.StreamConfig = DefaultStreamConfig()
+	}
+	if len(cfg.CommitLogPatterns) == 0 {
+		cfg.CommitLogPatterns = DefaultCommitLogPatterns()
+	}
+	if cfg.CacheWindow == 0 {
+		cfg.CacheWindow = 1000
+	}
+	if cfg.QuorumSize == 0 {
+		cfg.QuorumSize = 2
+	}
+	if cfg.MismatchConfirmations == 0 {
+		cfg.MismatchConfirmations = 1
+	}
+	if cfg.MismatchConfirmWindow == 0 {
+		cfg.MismatchConfirmWindow = 5 * time.Minute
+	}
+	if cfg.MaintenanceWindow == 0 {
+		cfg.MaintenanceWindow = time.Hour
+	}
+	if cfg.NotifyWorkers == 0 {
+		cfg.NotifyWorkers = 4
+	}
+	if cfg.NotifyRatePerMin == 0 {
+		cfg.NotifyRatePerMin = 20
+	}
+	if cfg.NotifyBurst == 0 {
+		cfg.NotifyBurst = 5
+	}
+	if cfg.LogBufferSize == 0 {
+		cfg.LogBufferSize = 1000
+	}
+	if cfg.NotifyBreakerFailures == 0 {
+		cfg.NotifyBreakerFailures = 5
+	}
+	if cfg.NotifyBreakerCooldown == 0 {
+		cfg.NotifyBreakerCooldown = time.Minute
+	}
+	if cfg.ExplorerRootField == "" {
+		cfg.ExplorerRootField = "root"
+	}
+	if cfg.ExplorerRatePerMin == 0 {
+		cfg.ExplorerRatePerMin = 30
+	}
+	if cfg.EventBufferSize == 0 {
+		cfg.EventBufferSize = 500
+	}
+	if cfg.MaxErrorChars == 0 {
+		cfg.MaxErrorChars = defaultMaxErrorChars
+	}
+	if cfg.PDErrorHeightPattern == nil {
+		cfg.PDErrorHeightPattern = regexp.MustCompile(defaultPDErrorHeightPattern)
+	}
+	if cfg.formatter == nil {
+		// The default templates always parse.
+		cfg.formatter, _ = newMessageFormatter("", cfg.EnvTag)
+	}
+}
+
+// ToggleMaintenance closes the current maintenance window, or opens one of
+// cfg.MaintenanceWindow, during which the alerts below critical are
+// suppressed. The binary calls it on SIGUSR1.
+func (m *Monitor) ToggleMaintenance() {
+	m.maintenance.Toggle()
+}
+
+// Run monitors the networks until `ctx` is cancelled, or the logs are
+// exhausted when replaying a file or auditing past logs, then delivers the
+// pending alerts. It also serves the metrics on cfg.MetricsAddr, and the
+// health, roots, events, incidents, maintenance and version endpoints on
+// port 8080, along with the ingest endpoint if cfg.IngestToken is set. It
+// returns nil on a clean shutdown, else the failure that stopped the
+// monitor, whose exit code for the binary is given by ExitCode.
+func (m *Monitor) Run(ctx context.Context) error {
+	cfg := m.cfg.Load()
+	// A failure stops every worker, and is what Run returns.
+	ctx, fail := context.WithCancelCause(ctx)
+	defer fail(nil)
+
+	slog.Info("log relayer starting up!", "version", version, "commit", commit, "build_date", buildDate)
+
+	var names []string
+	for _, network := range cfg.Networks {
+		names = append(names, network.Name)
+	}
+	slog.Info("starting log relayer", "networks", names)
+	var ingest *ingest
+	if cfg.ReplayFile == "" && cfg.IngestToken != "" {
+		ingest = newIngest(cfg.IngestToken, m.metrics)
+		slog.Info("ingesting the logs pushed to /ingest instead of tailing GCP")
+	} else if cfg.ReplayFile == "" {
+		slog.Info("authenticating to GCP", "credentials", cfg.CredentialsMode)
+	}
+
+	tracerProvider, stopTracing, err := newTracerProvider(cfg.OTLPEndpoint)
+	if err != nil {
+		return configError(err)
+	}
+	m.tracer = tracerProvider.Tracer(tracingServiceName)
+	if cfg.OTLPEndpoint != "" {
+		slog.Info("exporting traces", "endpoint", cfg.OTLPEndpoint)
+	}
+
+	backends := m.notifier
+	if backends == nil {
+		backends = buildNotifier(m.cfg, m.tracer, m.metrics)
+	}
+	// Backends are delivered to concurrently, so that a slow one does not
+	// hold up the others.
+	pool := newNotifyPool(backends, cfg.NotifyWorkers, notifyQueueSize)
+	var delivered Notifier = pool
+	if cfg.EnvTag != "" {
+		delivered = envTagNotifier{tag: cfg.EnvTag, notifier: pool}
+	}
+	limiter := newRateLimitedNotifier(delivered, cfg.NotifyRatePerMin, cfg.NotifyBurst, notifyQueueSize)
+	limiterCtx, stopLimiter := context.WithCancel(context.Background())
+	defer stopLimiter()
+	go pool.Run(limiterCtx)
+	go limiter.Run(limiterCtx)
+	var notifier Notifier = limiter
+	var batcher *batchingNotifier
+	if cfg.BatchAlerts {
+		batcher = newBatchingNotifier(limiter, cfg.BatchInterval, discordMaxMessageLen)
+		notifier = batcher
+	}
+	// The messages entering and leaving maintenance are never suppressed.
+	m.maintenance.setNotifier(notifier)
+	notifier = maintenanceNotifier{maintenance: m.maintenance, notifier: notifier, metrics: m.metrics}
+	// Alerts are recorded as they are raised, before being batched or
+	// rate limited, and even when suppressed by maintenance.
+	var auditLog *auditLogger
+	if cfg.AuditLogPath != "" {
+		var err error
+		auditLog, err = openAuditLogger(cfg.AuditLogPath, int64(cfg.AuditMaxSizeMB)<<20)
+		if err != nil {
+			return configError(err)
+		}
+		notifier = auditingNotifier{log: auditLog, notifier: notifier}
+		slog.Info("recording alerts", "path", cfg.AuditLogPath, "max_size_mb", cfg.AuditMaxSizeMB)
+	}
+
+	// Alerts are delivered with a context that outlives `ctx` by a grace
+	// period, so that in-flight posts are not abandoned on shutdown.
+	notifyCtx := withGracePeriod(ctx, shutdownGracePeriod)
+
+	health := newHealthTracker()
+	roots := newRootsAPI()
+	incidents := newIncidents()
+	var publishers []eventPublisher
+	var kafkaSink *kafkaSink
+	if len(cfg.KafkaBrokers) > 0 {
+		kafkaSink = newKafkaSink(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaBufferSize, m.metrics)
+		go kafkaSink.Run()
+		publishers = append(publishers, kafkaSink)
+		slog.Info("publishing events to kafka", "brokers", cfg.KafkaBrokers, "topic", cfg.KafkaTopic)
+	}
+	events := newEventBuffer(cfg.EventBufferSize, publishers...)
+
+	var audit *sqliteStore
+	if cfg.SQLitePath != "" {
+		var err error
+		audit, err = openSQLiteStore(cfg.SQLitePath)
+		if err != nil {
+			return configError(fmt.Errorf("opening the audit database %s: %v", cfg.SQLitePath, err))
+		}
+		defer audit.Close()
+		slog.Info("recording commit logs", "path", cfg.SQLitePath)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, network := range cfg.Networks {
+		network := network
+		networkNotifier := networkNotifier{network: network.Name, notifier: notifier}
+
+		var store stateStore
+		if network.StateFile != "" {
+			slog.Info("persisting state", "network", network.Name, "path", network.StateFile)
+			store = newFileStateStore(network.StateFile)
+		}
+
+		tip := &chainTip{}
+		summary := newSummary()
+		mismatchHeights := newMismatchHeights()
+		deps := tmDeps{Config: m.cfg, Tracer: m.tracer, Health: health, Store: store, Audit: audit, Roots: roots, Tip: tip, Events: events, Summary: summary, Mismatches: mismatchHeights, Incidents: incidents, Metrics: m.metrics, Fail: fail}
+		if cfg.ExplorerURL != "" && cfg.EnableTM {
+			// The cross-check samples the confirmed heights, it stops with
+			// the monitor rather than holding up a replay.
+			deps.Explorer = newExplorerCheck(network.Name, cfg.ExplorerURL, cfg.ExplorerRootField, cfg.ExplorerRatePerMin, networkNotifier, m.metrics)
+			go deps.Explorer.Run(ctx, notifyCtx)
+		}
+		pd := pdDeps{Config: m.cfg, Health: health, Events: events, Summary: summary, Mismatches: mismatchHeights, Metrics: m.metrics, Fail: fail}
+
+		if cfg.ReplayFile != "" {
+			// Replay the file through the tm worker only, and stop once
+			// it is exhausted.
+			slog.Info("replaying commit logs", "network", network.Name, "path", cfg.ReplayFile)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tmWorker(ctx, notifyCtx, cfg, network, &fileLogSource{Path: cfg.ReplayFile}, networkNotifier, deps)
+			}()
+			continue
+		}
+
+		if !cfg.BackfillFrom.IsZero() {
+			// Audit the past logs through the workers, and stop once they
+			// are exhausted. The saved state is that of the live monitor,
+			// it is left alone.
+			slog.Info("auditing past logs", "network", network.Name, "from", cfg.BackfillFrom, "to", cfg.BackfillTo)
+			deps.Store = nil
+			if cfg.EnableTM {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					source := &listLogSource{Credentials: cfg.Credentials, ProjectIDs: network.Projects(), Filter: network.CommitLogFilter(), From: cfg.BackfillFrom, To: cfg.BackfillTo, PayloadField: cfg.PayloadField}
+					tmWorker(ctx, notifyCtx, cfg, network, source, networkNotifier, deps)
+				}()
+			}
+			if cfg.EnablePD {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					source := &listLogSource{Credentials: cfg.Credentials, ProjectIDs: network.Projects(), Filter: network.ErrorLogFilter(), From: cfg.BackfillFrom, To: cfg.BackfillTo, PayloadField: cfg.PayloadField}
+					pdWorker(ctx, notifyCtx, cfg, network, source, networkNotifier, pd)
+				}()
+			}
+			continue
+		}
+
+		if cfg.EnableTM {
+			var source LogSource
+			if network.TMLogSource != nil {
+				source = network.TMLogSource
+			} else if ingest != nil {
+				source = ingest.Source(network.Name, "tm")
+			} else if subscription := network.CommitSubscription(); subscription != "" {
+				slog.Info("tm subscription", "network", network.Name, "subscription", subscription)
+				source = &pubSubLogSource{Credentials: cfg.Credentials, Subscription: subscription, PayloadField: cfg.PayloadField, Config: cfg.StreamConfig, tracer: m.tracer}
+			} else {
+				slog.Info("tm filter", "network", network.Name, "filter", network.CommitLogFilter())
+				source = m.reloadableSource(network.Name, NetworkConfig.CommitLogFilter, networkNotifier)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tmWorker(ctx, notifyCtx, cfg, network, source, networkNotifier, deps)
+			}()
+
+			// The chain tip is fed by the tm worker.
+			if cfg.ChainStallTimeout > 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					stallWatcher(ctx, notifyCtx, network, tip, cfg.ChainStallTimeout, networkNotifier)
+				}()
+			}
+		} else {
+			slog.Info("tm worker disabled", "network", network.Name)
+		}
+
+		if cfg.EnablePD {
+			var source LogSource
+			if network.PDLogSource != nil {
+				source = network.PDLogSource
+			} else if ingest != nil {
+				source = ingest.Source(network.Name, "pd")
+			} else if subscription := network.ErrorSubscription(); subscription != "" {
+				slog.Info("pd subscription", "network", network.Name, "subscription", subscription)
+				source = &pubSubLogSource{Credentials: cfg.Credentials, Subscription: subscription, PayloadField: cfg.PayloadField, Config: cfg.StreamConfig, tracer: m.tracer}
+			} else {
+				slog.Info("pd filter", "network", network.Name, "filter", network.ErrorLogFilter())
+				source = m.reloadableSource(network.Name, NetworkConfig.ErrorLogFilter, networkNotifier)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pdWorker(ctx, notifyCtx, cfg, network, source, networkNotifier, pd)
+			}()
+		} else {
+			slog.Info("pd worker disabled", "network", network.Name)
+		}
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metricsHandler(newMetricsRegistry(m.metrics, cfg.EnvTag)))
+
+	// Digital ocean deploy fails unless it can ping a health endpoint
+	healthMux := http.NewServeMux()
+	var breakers []*circuitBreaker
+	if multi, ok := backends.(multiNotifier); ok {
+		for _, n := range multi {
+			if gate, ok := n.(severityGate); ok {
+				n = gate.notifier
+			}
+			if b, ok := n.(*circuitBreaker); ok {
+				breakers = append(breakers, b)
+			}
+		}
+	}
+	healthMux.HandleFunc("/health", healthzHandler(breakers))
+	healthMux.HandleFunc("/healthz", healthzHandler(breakers))
+	healthMux.HandleFunc("/readyz", health.readyzHandler(cfg.ReadyStaleness))
+	healthMux.Handle("/roots/", roots.Handler())
+	healthMux.HandleFunc("/version", versionHandler)
+	healthMux.Handle("/events", events.Handler())
+	healthMux.Handle("/maintenance", m.maintenance.Handler())
+	healthMux.Handle("/incidents", incidents.Handler())
+	if ingest != nil {
+		healthMux.Handle("/ingest", ingest.Handler())
+	}
+
+	// The servers are a side job of the workers, which keep running if one
+	// fails, e.g. on a port already in use. The health server is required
+	// only when the logs are pushed to it.
+	servers := []struct {
+		name     string
+		server   *http.Server
+		required bool
+	}{
+		{"metrics", &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}, false},
+		{"health", &http.Server{Addr: ":8080", Handler: healthMux}, ingest != nil},
+	}
+	for _, s := range servers {
+		s := s
+		go func() {
+			slog.Info("serving "+s.name, "addr", s.server.Addr)
+			err := s.server.ListenAndServe()
+			if err == http.ErrServerClosed {
+				return
+			}
+			if s.required {
+				fail(fmt.Errorf("%s server failed: %v", s.name, err))
+				return
+			}
+			slog.Warn(s.name+" server failed, the monitor keeps running without it", "event", "server_failed", "addr", s.server.Addr, "err", err)
+			notify(notifyCtx, notifier, Message{
+				Severity: SeverityWarning,
+				Title:    "Endpoints unavailable",
+				Body:     fmt.Sprintf("the %s server failed on %s, the monitor keeps watching the logs without it: %v", s.name, s.server.Addr, err),
+			})
+		}()
+	}
+
+	wg.Wait()
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	notify(shutdownCtx, notifier, Message{
+		Severity: SeverityWarning,
+		Title:    "Shutdown",
+		Body:     fmt.Sprintf("monitor shutting down for networks %s", strings.Join(names, ", ")),
+	})
+	if batcher != nil {
+		if err := batcher.Flush(shutdownCtx); err != nil {
+			slog.Warn("failed to flush batched alerts", "err", err)
+		}
+	}
+	if err := limiter.Flush(shutdownCtx); err != nil {
+		slog.Warn("some alerts were not delivered before shutdown", "err", err)
+	} else if err := pool.Flush(shutdownCtx); err != nil {
+		slog.Warn("some alerts were not delivered before shutdown", "err", err)
+	}
+	if kafkaSink != nil {
+		if err := kafkaSink.Close(shutdownCtx); err != nil {
+			slog.Warn("failed to flush the events to kafka", "err", err)
+		}
+	}
+	if err := auditLog.Close(); err != nil {
+		slog.Warn("failed to close the audit log", "err", err)
+	}
+	if err := stopTracing(shutdownCtx); err != nil {
+		slog.Warn("failed to export spans", "err", err)
+	}
+	for _, s := range servers {
+		s.server.Shutdown(shutdownCtx)
+	}
+
+	// The cancellation of the parent context is a clean shutdown.
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// notifierBackends are the names of the notifier backends, e.g. in the
+// metrics and the settings.
+var notifierBackends = []string{"discord", "slack", "telegram", "pagerduty", "sns", "webhook"}
+
+// buildNotifier assembles the configured notifier backends, their
+// deliveries traced with `tracer` if not nil and measured in `metrics`. In
+// dry-run mode every backend is swapped for one that only logs what it
+// would send.
+func buildNotifier(live *liveConfig, tracer trace.Tracer, metrics *metrics) multiNotifier {
+	cfg := live.Load()
+	type backend struct {
+		name     string
+		notifier Notifier
+	}
+
+	// A single client is shared by every backend so that connections are reused.
+	client := &http.Client{Timeout: cfg.NotifyTimeout}
+	var transport http.RoundTripper = &statusTransport{base: newNotifyTransport(cfg)}
+	if tracer != nil {
+		transport = &tracingTransport{base: transport}
+	}
+	if cfg.WebhookSigningSecret != "" {
+		transport = &signingTransport{
+			secret: []byte(cfg.WebhookSigningSecret),
+			header: cfg.WebhookSignatureHeader,
+			base:   transport,
+		}
+	}
+	client.Transport = transport
+
+	var backends []backend
+	if cfg.DiscordWebhookURL != "" {
+		discord := newDiscordNotifier(cfg.DiscordWebhookURL, cfg.DiscordSeverityWebhookURLs, cfg.AlertMention, client)
+		discord.mention = func() string { return live.Load().AlertMention }
+		discord.metrics = metrics
+		backends = append(backends, backend{"discord", discord})
+	}
+	if cfg.SlackWebhookURL != "" {
+		backends = append(backends, backend{"slack", newSlackNotifier(cfg.SlackWebhookURL, client)})
+	}
+	if cfg.TelegramBotToken != "" {
+		backends = append(backends, backend{"telegram", newTelegramNotifier(cfg.TelegramBotToken, cfg.TelegramChatID, client)})
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		pagerDuty := newPagerDutyNotifier(cfg.PagerDutyRoutingKey, client)
+		// The severity gate applies PAGERDUTY_MIN_SEVERITY, as reloaded.
+		pagerDuty.MinSeverity = SeverityInfo
+		backends = append(backends, backend{"pagerduty", pagerDuty})
+	}
+	if cfg.SNSTopicARN != "" {
+		awsCfg := cfg.SNSConfig.Copy()
+		awsCfg.HTTPClient = client
+		backends = append(backends, backend{"sns", newSNSNotifier(cfg.SNSTopicARN, awsCfg)})
+	}
+	if cfg.WebhookURL != "" {
+		backends = append(backends, backend{"webhook", newWebhookNotifier(cfg.WebhookURL, cfg.WebhookHeaders, cfg.WebhookTemplate, client)})
+	}
+
+	if cfg.DryRun {
+		slog.Warn("DRY RUN: alerts are logged and NOT sent")
+	}
+
+	var notifier multiNotifier
+	for _, b := range backends {
+		var backendNotifier Notifier = b.notifier
+		if cfg.DryRun {
+			renderer, _ := b.notifier.(payloadRenderer)
+			backendNotifier = &dryRunNotifier{Backend: b.name, Renderer: renderer}
+		} else if tracer != nil {
+			backendNotifier = tracingNotifier{backend: b.name, tracer: tracer, notifier: b.notifier}
+		}
+		instrumented := instrumentedNotifier{backend: b.name, notifier: backendNotifier, metrics: metrics}
+		breaker := newCircuitBreaker(b.name, instrumented, cfg.NotifyBreakerFailures, cfg.NotifyBreakerCooldown, metrics)
+		notifier = append(notifier, newSeverityGate(b.name, live, breaker))
+	}
+	return notifier
+}
+
+// shutdownGracePeriod bounds how long pending alerts may take to be
+// delivered once a shutdown has been requested.
+const shutdownGracePeriod = 10 * time.Second
+
+// withGracePeriod returns a context that is cancelled `grace` after `parent` is done.
+func withGracePeriod(parent context.Context, grace time.Duration) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-parent.Done()
+		time.Sleep(grace)
+		cancel()
+	}()
+	return ctx
+}
+
+// notify delivers msg, logging rather than propagating a delivery failure.
+func notify(ctx context.Context, notifier Notifier, msg Message) {
+	if !msg.spanContext.IsValid() {
+		msg.spanContext = trace.SpanContextFromContext(ctx)
+	}
+	if err := notifier.Notify(ctx, msg); err != nil {
+		slog.Error("failed to deliver alert", "title", msg.Title, "severity", msg.Severity.String(), "err", err)
+	}
+}
+
+const (
+	// stateSaveInterval is how often the tm worker persists its state.
+	stateSaveInterval = 30 * time.Second
+	// stateWindow is the number of recent heights kept in the saved state.
+	stateWindow = 100
+)
+
+const (
+	// dedupMaxEntries bounds the number of distinct pd errors tracked for
+	// deduplication.
+	dedupMaxEntries = 1000
+	// dedupFlushInterval is how often closed deduplication windows are
+	// summarized.
+	dedupFlushInterval = 10 * time.Second
+	// livenessCheckInterval is how often pods are checked for liveness gaps.
+	livenessCheckInterval = 15 * time.Second
+)
+
+// tmDeps are the collaborators of the tm worker shared with the rest of the
+// monitor. They are all optional.
+type tmDeps struct {
+	// Config, if set, is the configuration as reloaded, whose settings read
+	// for every commit log take over those the worker started with.
+	Config *liveConfig
+	// Tracer records a span for every log entry processed, if set.
+	Tracer trace.Tracer
+	Health *healthTracker
+	Store  stateStore
+	Audit  *sqliteStore
+	Roots  *rootsAPI
+	Tip    *chainTip
+	Events *eventBuffer
+	// Summary is reported every cfg.SummaryInterval, if set.
+	Summary *summary
+	// Mismatches receives the heights of the alerted mismatches, for the pd
+	// worker to correlate its errors with.
+	Mismatches *mismatchHeights
+	// Incidents records the mismatches for postmortems.
+	Incidents *incidents
+	// Explorer cross-checks the confirmed roots with a block explorer.
+	Explorer *explorerCheck
+	// Metrics are those of the monitor, unregistered ones if nil.
+	Metrics *metrics
+	// Fail stops the monitor with an error, see stopMonitor.
+	Fail func(error)
+}
+
+// pdDeps are the collaborators of the pd worker shared with the rest of the
+// monitor.
+type pdDeps struct {
+	// Config, if set, is the configuration as reloaded, see tmDeps.
+	Config  *liveConfig
+	Health  *healthTracker
+	Events  *eventBuffer
+	Summary *summary
+	// Mismatches are the heights of the alerted mismatches, which the pd
+	// errors are correlated with.
+	Mismatches *mismatchHeights
+	// Metrics are those of the monitor, see tmDeps.
+	Metrics *metrics
+	// Fail stops the monitor with an error, see stopMonitor.
+	Fail func(error)
+}
+
+// stopMonitor stops the monitor with `err` through `fail`, which is set by
+// Monitor.Run. Without it, the error is only logged.
+func stopMonitor(fail func(error), err error) {
+	if fail == nil {
+		slog.Error("monitor failed", "err", err)
+		return
+	}
+	fail(err)
+}
+
+// tmWorker follows the CometBFT commit logs and alerts on root mismatches.
+func tmWorker(ctx, notifyCtx context.Context, cfg *Config, network NetworkConfig, source LogSource, notifier Notifier, deps tmDeps) {
+	slog.Info("started tm log relay", "network", network.Name)
+	deps.Metrics = metricsOrUnregistered(deps.Metrics)
+
+	// Commit logs are never dropped, a dropped one could hide a mismatch.
+	entries := make(chan LogEntry)
+	commitLogs := newLogBuffer(network.Name, "tm", cfg.LogBufferSize, false, deps.Metrics).relay(ctx, source, entries)
+	go func() {
+		if err := source.Stream(ctx, entries); err != nil {
+			stopMonitor(deps.Fail, streamError(fmt.Errorf("tm log source failed for %s: %w", network.Name, err)))
+		}
+	}()
+
+	processCommitLogs(ctx, notifyCtx, commitLogs, notifier, cfg, network, deps)
+	slog.Info("tm worker exiting", "network", network.Name)
+}
+
+// processCommitLogs checks the commit logs received on `in` until it is
+// closed: root mismatches, milestones, height regressions, lag, liveness and
+// busy or empty blocks.
+func processCommitLogs(ctx, notifyCtx context.Context, in <-chan LogEntry, notifier Notifier, cfg *Config, network NetworkConfig, deps tmDeps) {
+	health, store, audit, roots, tip, events, summary, mismatchHeights, incidents := deps.Health, deps.Store, deps.Audit, deps.Roots, deps.Tip, deps.Events, deps.Summary, deps.Mismatches, deps.Incidents
+	if health == nil {
+		health = newHealthTracker()
+	}
+	if roots == nil {
+		roots = newRootsAPI()
+	}
+	if tip == nil {
+		tip = &chainTip{}
+	}
+	if summary == nil {
+		summary = newSummary()
+	}
+	if incidents == nil {
+		incidents = newIncidents()
+	}
+	tracer := tracerOrNoop(deps.Tracer)
+	metrics := metricsOrUnregistered(deps.Metrics)
+
+	// Map the block height to a list of `rootHashRecord` that store the pod name
+	// and reported root hash, along with the highest height at which a quorum
+	// of pods agreed on the root. The stream reconnects transparently, so the
+	// state is kept across reconnects.
+	monitor := newMonitorState(cfg.CacheWindow)
+	roots.Track(network.Name, monitor)
+	// Milestone heights that were already announced.
+	announcedMilestones := make(map[int64]bool)
+	// Heights whose differing transaction counts were already alerted.
+	numTxsAlerted := make(map[int64]bool)
+	// Pods whose divergence from the reference pod was already alerted, by
+	// height.
+	referenceAlerted := make(map[int64]map[string]bool)
+	mismatchAlerts := newMismatchAlerts()
+	// Reports of the pods that went back below the confirmed height, by pod
+	// and height, held until a chain restart is detected.
+	rewound := make(map[string]map[int64]rootHashRecord)
+
+	if store != nil {
+		state, err := store.Load()
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Info("no saved state, starting fresh", "network", network.Name)
+		} else if err != nil {
+			slog.Warn("could not load saved state, starting fresh", "network", network.Name, "err", err)
+		} else {
+			monitor.Restore(state)
+			for _, height := range state.AnnouncedMilestones {
+				announcedMilestones[height] = true
+			}
+			mismatchAlerts.Restore(state.AlertedMismatches)
+			incidents.Restore(state.Incidents)
+			metrics.highestConfirmedHeight.WithLabelValues(network.Name).Set(float64(state.ConfirmedHeight))
+			slog.Info("restored state", "network", network.Name, "confirmed_height", state.ConfirmedHeight, "cached_heights", monitor.CachedHeights(), "alerted_mismatches", len(state.AlertedMismatches))
+		}
+	}
+	saveState := func() {
+		if store == nil {
+			return
+		}
+		state := monitor.Snapshot(stateWindow)
+		state.AnnouncedMilestones = sortedHeights(announcedMilestones)
+		state.AlertedMismatches = mismatchAlerts.Heights()
+		state.Incidents = incidents.Network(network.Name)
+		if err := store.Save(state); err != nil {
+			slog.Error("failed to save state", "network", network.Name, "err", err)
+		}
+	}
+	lastSave := time.Now()
+
+	liveness := newLivenessTracker(cfg.LivenessTimeout, network.ExpectedPods, time.Now())
+	regressions := newRegressionTracker(cfg.RegressionTolerance)
+	mismatches := newMismatchConfirmer(cfg.MismatchConfirmations, cfg.MismatchConfirmWindow)
+	emptyBlocks := newEmptyBlockTracker(cfg.EmptyBlockStreak)
+	parser := newCommitLogParser(cfg.CommitLogPatterns)
+	lags := newLagTracker(cfg.MaxLagBlocks)
+	redeliveries := newRedeliveryFilter()
+	pacer := newPacer(cfg.ProcessRatePerSec)
+	floor := newHeightFloor(cfg.SinceHeight, cfg.SinceHeightAuto)
+	if cfg.SinceHeight > 0 {
+		slog.Info("skipping commit logs below height", "network", network.Name, "since_height", cfg.SinceHeight)
+	}
+	escalations := newMismatchEscalator(cfg.EscalateAfter)
+	livenessTicker := time.NewTicker(livenessCheckInterval)
+	defer livenessTicker.Stop()
+	// The summary doubles as a heartbeat of the monitor, its ticker is nil
+	// when disabled.
+	var summaryTick <-chan time.Time
+	if cfg.SummaryInterval > 0 {
+		summaryTicker := time.NewTicker(cfg.SummaryInterval)
+		defer summaryTicker.Stop()
+		summaryTick = summaryTicker.C
+	}
+	// The startup watchdog suspects a misconfigured filter until the first
+	// commit log is received, its channel is nil once disarmed.
+	var startupTimeout <-chan time.Time
+	startupAlerted := false
+	if cfg.StartupTimeout > 0 {
+		startupTimer := time.NewTimer(cfg.StartupTimeout)
+		defer startupTimer.Stop()
+		startupTimeout = startupTimer.C
+	}
+
+	// The span of the entry being processed is ended at the top of the loop,
+	// whichever way its processing ended, and once the loop exits. It
+	// records nothing until the first entry.
+	entrySpan := trace.SpanFromContext(context.Background())
+	defer func() { entrySpan.End() }()
+
+loop:
+	for {
+		entrySpan.End()
+		if deps.Config != nil {
+			cfg = deps.Config.Load()
+		}
+
+		var logEntry LogEntry
+		select {
+		case now := <-livenessTicker.C:
+			for _, pod := range liveness.Check(now) {
+				slog.Warn("pod stopped reporting commits",
+					"event", "liveness_gap",
+					"network", network.Name,
+					"pod_name", pod.PodName,
+					"height", pod.Height,
+					"tip_height", pod.TipHeight,
+				)
+				notify(notifyCtx, notifier, Message{
+					Severity: SeverityWarning,
+					Title:    "Pod stopped reporting",
+					Body:     fmt.Sprintf("**%s** has not reported a new commit since %s, stuck at height %d while the fleet reached %d", pod.PodName, pod.Since.UTC().Format(time.RFC3339), pod.Height, pod.TipHeight),
+				})
+			}
+
+			// Reports received at heights whose mismatch was already
+			// alerted are summarized at most once per height.
+			for height, records := range mismatchAlerts.Summaries() {
+				incidents.Update(network.Name, height, groupByRoot(records))
+				notify(notifyCtx, notifier, Message{
+					Severity:    SeverityError,
+					Title:       "Root mismatch (update)",
+					Body:        fmt.Sprintf("more pods reported block %d since the mismatch alert:\n%s", height, knownRootHashesString(records)),
+					IncidentKey: fmt.Sprintf("mismatch-%d", height),
+				})
+			}
+			mismatchAlerts.Prune(lags.Leader() - int64(cfg.CacheWindow))
+			for height := range announcedMilestones {
+				if height <= lags.Leader()-int64(cfg.CacheWindow) {
+					delete(announcedMilestones, height)
+				}
+			}
+			for height := range numTxsAlerted {
+				if height <= lags.Leader()-int64(cfg.CacheWindow) {
+					delete(numTxsAlerted, height)
+				}
+			}
+			for height := range referenceAlerted {
+				if height <= lags.Leader()-int64(cfg.CacheWindow) {
+					delete(referenceAlerted, height)
+				}
+			}
+			mismatchHeights.Prune(lags.Leader() - int64(cfg.CacheWindow))
+
+			for _, height := range escalations.Due(now) {
+				records, _ := monitor.Roots(height)
+				slog.Error("root mismatch persists", "event", "mismatch_escalated", "network", network.Name, "height", height, "roots", groupByRoot(records))
+				notify(notifyCtx, notifier, Message{
+					Severity:    SeverityCritical,
+					Title:       "Root mismatch persists",
+					Body:        fmt.Sprintf("the mismatch at block **%d** has not cleared after %s, pods have not agreed on a later block since:\n%s", height, cfg.EscalateAfter, knownRootHashesString(records)),
+					IncidentKey: fmt.Sprintf("mismatch-%d", height),
+					Event:       EventMismatch,
+					Commit:      &LogData{Height: height},
+				})
+			}
+			continue
+		case <-startupTimeout:
+			startupTimeout = nil
+			startupAlerted = true
+			slog.Warn("no commit log received since starting", "event", "no_logs", "network", network.Name, "timeout", cfg.StartupTimeout)
+			notify(notifyCtx, notifier, Message{
+				Severity:    SeverityWarning,
+				Title:       "No commit logs",
+				Body:        fmt.Sprintf("no log entries matched filter — check configuration: no commit log was received within %s of starting", cfg.StartupTimeout),
+				IncidentKey: "no-logs",
+			})
+			continue
+		case <-summaryTick:
+			laggingPod, maxLag := lags.MaxLag()
+			notify(notifyCtx, notifier, Message{
+				Severity: SeverityInfo,
+				Title:    "Summary",
+				Body:     summaryBody(summary.Reset(), cfg.SummaryInterval, monitor.ConfirmedHeight(), laggingPod, maxLag),
+			})
+			continue
+		case entry, ok := <-in:
+			if !ok {
+				break loop
+			}
+			logEntry = entry
+		}
+		pacer.Wait(ctx)
+
+		entryCtx, span := tracer.Start(ctx, "process_log_entry")
+		entrySpan = span
+		// Alerts raised by the entry are traced as its children.
+		notifyCtx := trace.ContextWithSpan(notifyCtx, entrySpan)
+
+		health.Observe(network.Name + "/tm")
+
+		if time.Since(lastSave) >= stateSaveInterval {
+			saveState()
+			lastSave = time.Now()
+		}
+
+		podName, exists := logEntry.podName(cfg.PodNameLabel)
+		if !exists {
+			metrics.entriesWithoutPodName.WithLabelValues("tm").Inc()
+			slog.Debug("pod name not found", "network", network.Name, "labels", logEntry.metadata)
+			continue
+		}
+		entrySpan.SetAttributes(attribute.String("network", network.Name), attribute.String("pod_name", podName))
+
+		_, parseSpan := tracer.Start(entryCtx, "parse_commit_log")
+		commitLog, err := parser.Parse(podName, logEntry.payload, logEntry.timestamp)
+		if err != errNoCommitLog {
+			setSpanError(parseSpan, err)
+		}
+		parseSpan.End()
+		if err != nil {
+			if err != errNoCommitLog {
+				slog.Warn("malformed commit log", "network", network.Name, "pod_name", podName, "err", err)
+			}
+			continue
+		}
+
+		entrySpan.SetAttributes(attribute.Int64("height", commitLog.Height))
+
+		// Commit logs below the floor are left alone entirely, the filter
+		// matching them is enough to disarm the startup watchdog.
+		seeding := floor.Seeding()
+		skip := floor.Skip(commitLog.Height)
+		if seeding && !floor.Seeding() {
+			slog.Info("skipping commit logs below the highest of the first ones", "network", network.Name, "since_height", floor.Height())
+		}
+		if skip {
+			startupTimeout = nil
+			continue
+		}
+
+		if redeliveries.Redelivered(logEntry.generation, commitLog.PodName, commitLog.Height) {
+			slog.Debug("skipping redelivered commit log", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height)
+			continue
+		}
+
+		metrics.commitLogsParsed.WithLabelValues(commitLog.PodName).Inc()
+		startupTimeout = nil
+		if startupAlerted {
+			startupAlerted = false
+			slog.Info("commit logs received", "event", "no_logs_recovered", "network", network.Name, "height", commitLog.Height)
+			notify(notifyCtx, notifier, Message{
+				Severity:    SeverityInfo,
+				Title:       "Commit logs received",
+				Body:        fmt.Sprintf("**%s** reported block %d, the filter matches commit logs", commitLog.PodName, commitLog.Height),
+				IncidentKey: "no-logs",
+				Resolved:    true,
+			})
+		}
+		summary.ObserveCommit(commitLog.PodName)
+
+		if err := audit.Record(ctx, network.Name, commitLog, time.Now()); err != nil {
+			slog.Warn("failed to record commit log", "network", network.Name, "pod_name", commitLog.PodName, "err", err)
+		}
+		events.Add(recentEvent{
+			Time:    commitLog.Timestamp,
+			Kind:    eventKindCommit,
+			Network: network.Name,
+			PodName: commitLog.PodName,
+			Height:  commitLog.Height,
+			Root:    commitLog.Root,
+			NumTxs:  commitLog.NumTxs,
+		})
+
+		tip.Observe(commitLog.Height, time.Now())
+
+		if previous, regressed := regressions.Observe(commitLog.PodName, commitLog.Height); regressed {
+			metrics.heightRegressions.WithLabelValues(commitLog.PodName).Inc()
+			slog.Warn("pod height regressed",
+				"event", "height_regression",
+				"network", network.Name,
+				"pod_name", commitLog.PodName,
+				"previous_height", previous,
+				"height", commitLog.Height,
+			)
+			notify(notifyCtx, notifier, Message{
+				Severity: SeverityError,
+				Title:    "Height regression",
+				Event:    EventRegression,
+				Commit:   commitLog,
+				Body: cfg.formatter.Format(EventRegression, alertData{
+					LogData:        *commitLog,
+					Network:        network.Name,
+					PreviousHeight: previous,
+				}),
+			})
+
+			// Logs delivered late move a single pod back, while a chain
+			// restarted from a lower height moves a quorum of them back
+			// below the confirmed height.
+			if confirmed := monitor.ConfirmedHeight(); regressions.Rewound(confirmed-int64(cfg.RegressionTolerance)) >= cfg.QuorumSize {
+				slog.Warn("chain restart detected",
+					"event", "chain_restart",
+					"network", network.Name,
+					"confirmed_height", confirmed,
+					"height", commitLog.Height,
+				)
+				body := fmt.Sprintf("%d pods went back below the confirmed height %d, e.g. **%s** to height %d, the roots are compared from scratch", cfg.QuorumSize, confirmed, commitLog.PodName, commitLog.Height)
+				// However many roots of the previous chain are cached at the
+				// height, possibly a single one or none, are listed.
+				if previous, _ := monitor.Roots(commitLog.Height); len(previous) > 0 {
+					body += fmt.Sprintf("; before the restart, block %d had:\n%s", commitLog.Height, knownRootHashesString(previous))
+				}
+				// Everything tracked per height is of the previous chain.
+				monitor.Restart()
+				// The held reports are of the new chain, they are compared
+				// to the reports that follow.
+				for _, reports := range rewound {
+					for height, held := range reports {
+						monitor.RecordRoot(height, held)
+					}
+				}
+				clear(rewound)
+				regressions.Restarted()
+				redeliveries.Reset()
+				tip.Reset()
+				lags.Reset()
+				liveness.Reset(time.Now())
+				mismatches.Reset()
+				mismatchAlerts.Prune(math.MaxInt64)
+				mismatchHeights.Prune(math.MaxInt64)
+				clear(announcedMilestones)
+				clear(numTxsAlerted)
+				clear(referenceAlerted)
+				incidents.Resolve(network.Name, math.MaxInt64, "the chain restarted", time.Now())
+				metrics.highestConfirmedHeight.WithLabelValues(network.Name).Set(0)
+				notify(notifyCtx, notifier, Message{
+					Severity: SeverityWarning,
+					Title:    "Chain restart",
+					Body:     body,
+				})
+				for _, height := range escalations.Resolve(math.MaxInt64) {
+					notify(notifyCtx, notifier, Message{
+						Severity:    SeverityInfo,
+						Title:       "Root mismatch cleared",
+						Body:        fmt.Sprintf("the chain restarted from block %d, past the mismatch at block %d", commitLog.Height, height),
+						IncidentKey: fmt.Sprintf("mismatch-%d", height),
+						Resolved:    true,
+					})
+				}
+				saveState()
+				lastSave = time.Now()
+			}
+		}
+
+		lag, fellBehind, caughtUp := lags.Observe(commitLog.PodName, commitLog.Height)
+		metrics.podLag.WithLabelValues(commitLog.PodName).Set(float64(lag))
+		if fellBehind {
+			slog.Warn("pod lagging behind", "event", "lag", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height, "leader_height", lags.Leader(), "lag", lag)
+			notify(notifyCtx, notifier, Message{
+				Severity:    SeverityWarning,
+				Title:       "Pod lagging",
+				Body:        fmt.Sprintf("**%s** is %d blocks behind, at height %d while the leader reached %d", commitLog.PodName, lag, commitLog.Height, lags.Leader()),
+				IncidentKey: "lag-" + commitLog.PodName,
+			})
+		}
+		if caughtUp {
+			slog.Info("pod caught up", "event", "lag_recovered", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height)
+			notify(notifyCtx, notifier, Message{
+				Severity:    SeverityInfo,
+				Title:       "Pod caught up",
+				Body:        fmt.Sprintf("**%s** caught up, %d blocks behind at height %d", commitLog.PodName, lag, commitLog.Height),
+				IncidentKey: "lag-" + commitLog.PodName,
+				Resolved:    true,
+			})
+		}
+
+		if liveness.Observe(commitLog.PodName, commitLog.Height, time.Now()) {
+			slog.Info("pod resumed reporting commits", "event", "liveness_recovered", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height)
+			notify(notifyCtx, notifier, Message{
+				Severity: SeverityInfo,
+				Title:    "Pod resumed reporting",
+				Body:     fmt.Sprintf("**%s** is reporting commits again, at height %d", commitLog.PodName, commitLog.Height),
+			})
+		}
+
+		record := rootHashRecord{
+			PodName:       commitLog.PodName,
+			Root:          commitLog.Root,
+			NumTxs:        commitLog.NumTxs,
+			NumTxsUnknown: commitLog.NumTxsUnknown,
+			Timestamp:     commitLog.Timestamp,
+		}
+
+		slog.Info("commit",
+			"event", "commit",
+			"network", network.Name,
+			"pod_name", commitLog.PodName,
+			"height", commitLog.Height,
+			"root", commitLog.Root,
+			"num_txs", commitLog.NumTxs,
+			"timestamp", commitLog.Timestamp,
+		)
+		if !commitLog.NumTxsUnknown {
+			metrics.commitTxs.WithLabelValues(commitLog.PodName).Add(float64(commitLog.NumTxs))
+		}
+
+		// Milestones are announced by the first pod to reach them, the
+		// others are only logged.
+		oneShot := cfg.MilestoneHeights[commitLog.Height]
+		periodic := cfg.MilestoneInterval > 0 && commitLog.Height%int64(cfg.MilestoneInterval) == 0
+		if oneShot || periodic {
+			slog.Info("milestone reached", "event", "milestone", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height, "announced", announcedMilestones[commitLog.Height])
+		}
+		if (oneShot || periodic) && !announcedMilestones[commitLog.Height] {
+			announcedMilestones[commitLog.Height] = true
+			notify(notifyCtx, notifier, Message{
+				Severity: SeverityInfo,
+				Title:    "Milestone",
+				Event:    EventMilestone,
+				Commit:   commitLog,
+				Body:     cfg.formatter.Format(EventMilestone, alertData{LogData: *commitLog, Network: network.Name}),
+			})
+			// Saved right away, so that a restart does not announce it again.
+			saveState()
+			lastSave = time.Now()
+		}
+
+		if !cfg.ComparedPods.Compared(commitLog.PodName) {
+			slog.Debug("pod left out of the mismatch comparison", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height)
+			continue
+		}
+
+		// A pod that went back below the confirmed height may be the first
+		// of a chain restart, detected once a quorum of pods went back: its
+		// reports are held until then, rather than compared to the roots of
+		// the previous chain. A pod rewound alone is compared again once it
+		// reaches the confirmed height, its held reports are dropped.
+		if regressions.Behind(commitLog.PodName, monitor.ConfirmedHeight()-int64(cfg.RegressionTolerance)) {
+			slog.Debug("pod went back below the confirmed height, awaiting a chain restart", "network", network.Name, "pod_name", commitLog.PodName, "height", commitLog.Height, "confirmed_height", monitor.ConfirmedHeight())
+			if rewound[commitLog.PodName] == nil {
+				rewound[commitLog.PodName] = make(map[int64]rootHashRecord)
+			}
+			if len(rewound[commitLog.PodName]) < cfg.CacheWindow {
+				rewound[commitLog.PodName][commitLog.Height] = record
+			}
+			continue
+		}
+		delete(rewound, commitLog.PodName)
+
+		prev := monitor.RecordRoot(commitLog.Height, record)
+
+		// The reports of a height are compared to the reference pod's once
+		// it reported it, whichever comes first.
+		if network.ReferencePod != "" {
+			reference, diverging, reported := divergingFromReference(network.ReferencePod, append(prev[:len(prev):len(prev)], record))
+			if !reported {
+				slog.Debug("awaiting the reference pod", "network", network.Name, "height", commitLog.Height, "reference_pod", network.ReferencePod)
+			}
+			for _, d := range diverging {
+				if referenceAlerted[commitLog.Height][d.PodName] {
+					continue
+				}
+				if referenceAlerted[commitLog.Height] == nil {
+					referenceAlerted[commitLog.Height] = make(map[string]bool)
+				}
+				referenceAlerted[commitLog.Height][d.PodName] = true
+				metrics.referenceDivergences.WithLabelValues(network.Name, d.PodName).Inc()
+				slog.Error("pod diverged from the reference pod",
+					"event", "reference_divergence",
+					"network", network.Name,
+					"height", commitLog.Height,
+					"pod_name", d.PodName,
+					"root", d.Root,
+					"reference_pod", reference.PodName,
+					"reference_root", reference.Root,
+				)
+				notify(notifyCtx, notifier, Message{
+					Severity:    SeverityCritical,
+					Title:       "Divergence from reference",
+					Body:        fmt.Sprintf("**%s** reported root _%s_ at height **%d**, the reference **%s** reported _%s_", d.PodName, d.Root, commitLog.Height, reference.PodName, reference.Root),
+					IncidentKey: fmt.Sprintf("reference-%s-%d", d.PodName, commitLog.Height),
+				})
+			}
+		}
+
+		if len(prev) > 0 {
+			// The diverging record is kept so that later reports at this
+			// height are compared against every root seen so far.
+			records := append(prev[:len(prev):len(prev)], record)
+
+			// A pod contradicting itself points at a faulty replica rather
+			// than at a divergence across the fleet, so its earlier reports
+			// are left out of the mismatch check.
+			if earlier, conflicting := podConflict(record, prev); conflicting {
+				metrics.podConflicts.WithLabelValues(commitLog.PodName).Inc()
+				slog.Error("pod reported conflicting roots",
+					"event", "pod_conflict",
+					"network", network.Name,
+					"pod_name", commitLog.PodName,
+					"height", commitLog.Height,
+					"root", commitLog.Root,
+					"previous_root", earlier.Root,
+				)
+				notify(notifyCtx, notifier, Message{
+					Severity: SeverityError,
+					Title:    "Conflicting reports",
+					Body:     fmt.Sprintf("**%s** reported root _%s_ at height **%d**, after reporting _%s_; the pod may be a faulty or duplicate replica", commitLog.PodName, commitLog.Root, commitLog.Height, earlier.Root),
+					Commit:   commitLog,
+				})
+			}
+			prev = otherPods(prev, commitLog.PodName)
+
+			if !consistentRecords(record, prev) && mismatchAlerts.Alerted(commitLog.Height) {
+				if mismatchAlerts.Update(commitLog.Height, records) {
+					slog.Warn("ongoing root mismatch", "event", "mismatch_update", "network", network.Name, "height", commitLog.Height, "roots", groupByRoot(records))
+				} else {
+					slog.Debug("ongoing root mismatch, already summarized", "network", network.Name, "height", commitLog.Height, "pod_name", commitLog.PodName)
+				}
+			} else if !consistentRecords(record, prev) {
+				divergences, confirmed := mismatches.Observe(commitLog.Height, records, time.Now())
+				if !confirmed {
+					slog.Warn("root divergence awaiting confirmation",
+						"event", "divergence",
+						"network", network.Name,
+						"height", commitLog.Height,
+						"roots", groupByRoot(records),
+						"pending", mismatches.Pending(),
+						"confirmations", cfg.MismatchConfirmations,
+					)
+					continue
+				}
+
+				firstHeight := divergences[0].Height
+				mismatchAlerts.MarkAlerted(divergences)
+				mismatchHeights.Record(divergences)
+				incidents.Open(network.Name, firstHeight, groupByRoot(divergences[0].Records), time.Now())
+				metrics.rootMismatches.WithLabelValues(network.Name).Inc()
+				summary.CountMismatch()
+				events.Add(recentEvent{
+					Kind:    eventKindMismatch,
+					Network: network.Name,
+					PodName: commitLog.PodName,
+					Height:  firstHeight,
+					Details: divergencesString(divergences),
+				})
+				data := alertData{LogData: *commitLog, Network: network.Name, Roots: divergencesString(divergences)}
+				data.Height = firstHeight
+				// With escalation, a mismatch only pages if it persists.
+				severity := SeverityCritical
+				if cfg.EscalateAfter > 0 {
+					severity = SeverityError
+					escalations.Open(firstHeight, time.Now())
+				}
+				notify(notifyCtx, notifier, Message{
+					Severity:    severity,
+					Title:       "Root mismatch",
+					Body:        cfg.formatter.Format(EventMismatch, data),
+					IncidentKey: fmt.Sprintf("mismatch-%d", firstHeight),
+					Event:       EventMismatch,
+					Commit:      &data.LogData,
+				})
+				slog.Error("root mismatch",
+					"event", "mismatch",
+					"network", network.Name,
+					"height", commitLog.Height,
+					"first_height", firstHeight,
+					"roots", groupByRoot(records),
+				)
+				// Saved right away, so that a restart does not alert it again.
+				saveState()
+				lastSave = time.Now()
+				if cfg.ExitOnMismatch {
+					stopMonitor(deps.Fail, mismatchError(fmt.Errorf("root mismatch at height %d on %s", firstHeight, network.Name)))
+				}
+			} else {
+				if agreeingPods(record, prev) >= cfg.QuorumSize && monitor.Confirm(commitLog.Height) {
+					metrics.highestConfirmedHeight.WithLabelValues(network.Name).Set(float64(commitLog.Height))
+					deps.Explorer.Observe(commitLog.Height, commitLog.Root)
+				}
+				// The same root with different transaction counts is no
+				// fork, but points at a logging or parsing anomaly.
+				if other, differ := numTxsConflict(record, prev); differ && !numTxsAlerted[commitLog.Height] {
+					numTxsAlerted[commitLog.Height] = true
+					metrics.numTxsMismatches.WithLabelValues(network.Name).Inc()
+					slog.Warn("pods agree on the root but not on the number of transactions",
+						"event", "num_txs_mismatch",
+						"network", network.Name,
+						"height", commitLog.Height,
+						"root", commitLog.Root,
+						"pod_name", commitLog.PodName,
+						"num_txs", commitLog.NumTxs,
+						"other_pod_name", other.PodName,
+						"other_num_txs", other.NumTxs,
+					)
+					notify(notifyCtx, notifier, Message{
+						Severity: SeverityWarning,
+						Title:    "Transaction count mismatch",
+						Body:     fmt.Sprintf("**%s** reported %d transactions in block **%d**, and **%s** %d, with the same root _%s_; the logs or their parsing may be off", commitLog.PodName, commitLog.NumTxs, commitLog.Height, other.PodName, other.NumTxs, commitLog.Root),
+						Commit:   commitLog,
+					})
+				}
+				// Pods agreeing past a mismatch means it cleared. A pod
+				// repeating itself is no agreement.
+				if len(prev) > 0 {
+					for _, height := range escalations.Resolve(commitLog.Height) {
+						slog.Info("root mismatch cleared", "event", "mismatch_cleared", "network", network.Name, "height", height, "agreed_height", commitLog.Height)
+						notify(notifyCtx, notifier, Message{
+							Severity:    SeverityInfo,
+							Title:       "Root mismatch cleared",
+							Body:        fmt.Sprintf("pods agreed on the root of block **%d**, past the mismatch at block %d", commitLog.Height, height),
+							IncidentKey: fmt.Sprintf("mismatch-%d", height),
+							Resolved:    true,
+						})
+					}
+					if resolved := incidents.Resolve(network.Name, commitLog.Height, fmt.Sprintf("pods agreed on the root of block %d", commitLog.Height), time.Now()); len(resolved) > 0 {
+						slog.Info("mismatch incidents resolved", "network", network.Name, "incidents", len(resolved), "agreed_height", commitLog.Height)
+						saveState()
+						lastSave = time.Now()
+					}
+				}
+			}
+		} else {
+
+			// Only the first report of a height is checked, so that a busy
+			// block is flagged once rather than once per pod.
+			if cfg.MaxTxsAlert > 0 && commitLog.NumTxs > int64(cfg.MaxTxsAlert) {
+				notify(notifyCtx, notifier, Message{
+					Severity: SeverityWarning,
+					Title:    "Busy block",
+					Body:     fmt.Sprintf("block **%d** has %d transactions (threshold %d), reported by %s", commitLog.Height, commitLog.NumTxs, cfg.MaxTxsAlert, commitLog.PodName),
+				})
+			}
+
+			if cfg.EmptyBlockStreak > 0 && !commitLog.NumTxsUnknown {
+				started, resumed := emptyBlocks.Observe(commitLog.NumTxs)
+				if started {
+					slog.Warn("empty block streak", "event", "empty_blocks", "network", network.Name, "height", commitLog.Height, "empty_blocks", emptyBlocks.Empty())
+					notify(notifyCtx, notifier, Message{
+						Severity:    SeverityWarning,
+						Title:       "Empty blocks",
+						Body:        fmt.Sprintf("the last %d blocks, up to **%d**, had no transactions (%.1f per block before)", emptyBlocks.Empty(), commitLog.Height, emptyBlocks.Average()),
+						IncidentKey: "empty-blocks",
+					})
+				}
+				if resumed {
+					slog.Info("transactions resumed", "event", "empty_blocks_recovered", "network", network.Name, "height", commitLog.Height)
+					notify(notifyCtx, notifier, Message{
+						Severity:    SeverityInfo,
+						Title:       "Transactions resumed",
+						Body:        fmt.Sprintf("block **%d** has %d transactions", commitLog.Height, commitLog.NumTxs),
+						IncidentKey: "empty-blocks",
+						Resolved:    true,
+					})
+				}
+			}
+		}
+
+	}
+	saveState()
+}
+
+// defaultMaxErrorChars leaves room, within Discord's 2000-character limit,
+// for the title and the pod name around a pd error.
+const defaultMaxErrorChars = 1800
+
+// truncatedSuffix marks a truncated pd error.
+const truncatedSuffix = "…(truncated)"
+
+// truncate cuts `s` down to its first `max` characters, marking it as
+// truncated.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + truncatedSuffix
+}
+
+// pdWorker forwards the pd error logs to the notifier.
+func pdWorker(ctx, notifyCtx context.Context, cfg *Config, network NetworkConfig, source LogSource, notifier Notifier, deps pdDeps) {
+	health, events, summary, mismatches := deps.Health, deps.Events, deps.Summary, deps.Mismatches
+	metrics := metricsOrUnregistered(deps.Metrics)
+	slog.Info("started pd worker", "network", network.Name)
+	entries := make(chan LogEntry)
+	errorLogs := newLogBuffer(network.Name, "pd", cfg.LogBufferSize, cfg.LogBufferDropErrors, metrics).relay(ctx, source, entries)
+	go func() {
+		if err := source.Stream(ctx, entries); err != nil {
+			stopMonitor(deps.Fail, streamError(fmt.Errorf("pd log source failed for %s: %w", network.Name, err)))
+		}
+	}()
+
+	dedup := newDeduplicator(cfg.DedupWindow, dedupMaxEntries)
+	notifyRepeated := func(entries []dedupEntry) {
+		for _, entry := range entries {
+			severity, _ := cfg.PDSeverityRules.Classify(entry.Payload, SeverityError)
+			notify(notifyCtx, notifier, Message{
+				Severity: severity,
+				Title:    "pd error (repeated)",
+				Body:     fmt.Sprintf("%s: repeated %d times within %s: %s", entry.PodName, entry.Suppressed, cfg.DedupWindow, truncate(entry.Payload, cfg.MaxErrorChars)),
+			})
+		}
+	}
+
+	ticker := time.NewTicker(dedupFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		if deps.Config != nil {
+			cfg = deps.Config.Load()
+		}
+		select {
+		case now := <-ticker.C:
+			notifyRepeated(dedup.Expire(now))
+		case logEntry, ok := <-errorLogs:
+			if !ok {
+				notifyRepeated(dedup.Expire(time.Now().Add(cfg.DedupWindow)))
+				slog.Info("pd worker exiting", "network", network.Name)
+				return
+			}
+
+			health.Observe(network.Name + "/pd")
+
+			podName, exists := logEntry.podName(cfg.PodNameLabel)
+			if !exists {
+				metrics.entriesWithoutPodName.WithLabelValues("pd").Inc()
+				slog.Debug("pod name not found", "network", network.Name, "labels", logEntry.metadata)
+				continue
+			}
+
+			severity, alerted := cfg.PDSeverityRules.Classify(logEntry.payload, logSeverity(logEntry.severity))
+			if !alerted {
+				slog.Debug("dropped pd error", "network", network.Name, "pod_name", podName)
+				continue
+			}
+
+			// The error is correlated with the mismatch recorded at the
+			// height it mentions, if any.
+			height, hasHeight := pdErrorHeight(cfg.PDErrorHeightPattern, logEntry.payload)
+			correlated := hasHeight && mismatches.Mismatched(height)
+
+			summary.CountPDError()
+			events.Add(recentEvent{
+				Time:    logEntry.timestamp,
+				Kind:    eventKindPDError,
+				Network: network.Name,
+				PodName: podName,
+				Height:  height,
+				Details: truncate(logEntry.payload, cfg.MaxErrorChars),
+			})
+
+			if !dedup.Seen(podName, logEntry.payload, time.Now()) {
+				slog.Debug("suppressed duplicate pd error", "network", network.Name, "pod_name", podName)
+				continue
+			}
+
+			// Long payloads, e.g. stack traces, would not fit in a message.
+			payload := truncate(logEntry.payload, cfg.MaxErrorChars)
+			var attachment *Attachment
+			if cfg.UploadFullErrors && payload != logEntry.payload {
+				attachment = &Attachment{Name: podName + "-error.txt", Content: []byte(logEntry.payload)}
+			}
+
+			title := "pd error"
+			switch {
+			case correlated:
+				slog.Error("pd error at a mismatch height", "event", "pd_error_mismatch", "network", network.Name, "pod_name", podName, "height", height)
+				severity, title = SeverityCritical, fmt.Sprintf("pd error at mismatch height %d", height)
+			case hasHeight:
+				title = fmt.Sprintf("pd error at height %d", height)
+			}
+
+			commit := LogData{PodName: podName, Height: height, Timestamp: logEntry.timestamp}
+			body := cfg.formatter.Format(EventPDError, alertData{
+				LogData: commit,
+				Network: network.Name,
+				Payload: payload,
+			})
+			if correlated {
+				body += fmt.Sprintf("\na root mismatch was recorded at block **%d**", height)
+			}
+			notify(notifyCtx, notifier, Message{
+				Severity:   severity,
+				Title:      title,
+				Event:      EventPDError,
+				Commit:     &commit,
+				Body:       body,
+				Attachment: attachment,
+			})
+		}
+	}
+}
+
+func consistentRecords(current rootHashRecord, records []rootHashRecord) bool {
+	if len(records) == 0 {
+		return true
+	}
+
+	for record := range records {
+		if current.Root != records[record].Root {
+			return false
+		}
+	}
+
+	return true
+}
+
+// divergingFromReference compares the reports of a height among `records` to
+// the latest one of the `reference` pod, returning it along with the reports
+// of the other pods disagreeing with it. It reports false if the reference
+// pod has not reported the height yet.
+func divergingFromReference(reference string, records []rootHashRecord) (rootHashRecord, []rootHashRecord, bool) {
+	var ref rootHashRecord
+	reported := false
+	for _, record := range records {
+		if record.PodName == reference {
+			ref, reported = record, true
+		}
+	}
+	if !reported {
+		return rootHashRecord{}, nil, false
+	}
+
+	var diverging []rootHashRecord
+	for _, record := range records {
+		if record.PodName != reference && record.Root != ref.Root {
+			diverging = append(diverging, record)
+		}
+	}
+	return ref, diverging, true
+}
+
+// podConflict reports whether `current` contradicts what its pod reported
+// earlier among `records`, returning the earlier report. A root the pod has
+// already reported, e.g. redelivered after a reconnect, is no conflict.
+func podConflict(current rootHashRecord, records []rootHashRecord) (rootHashRecord, bool) {
+	var earlier rootHashRecord
+	conflicting := false
+	for _, record := range records {
+		if record.PodName != current.PodName {
+			continue
+		}
+		if record.Root == current.Root {
+			return rootHashRecord{}, false
+		}
+		earlier, conflicting = record, true
+	}
+	return earlier, conflicting
+}
+
+// numTxsConflict returns the first of `records` with the root of `current`
+// but a different number of transactions, if any.
+func numTxsConflict(current rootHashRecord, records []rootHashRecord) (rootHashRecord, bool) {
+	for _, record := range records {
+		if record.Root == current.Root && record.NumTxs != current.NumTxs && !record.NumTxsUnknown && !current.NumTxsUnknown {
+			return record, true
+		}
+	}
+	return rootHashRecord{}, false
+}
+
+// otherPods returns the records not reported by `podName`.
+func otherPods(records []rootHashRecord, podName string) []rootHashRecord {
+	others := make([]rootHashRecord, 0, len(records))
+	for _, record := range records {
+		if record.PodName != podName {
+			others = append(others, record)
+		}
+	}
+	return others
+}
+
+// agreeingPods counts the distinct pods that reported the root of `current`,
+// itself included, among `records`.
+func agreeingPods(current rootHashRecord, records []rootHashRecord) int {
+	pods := map[string]bool{current.PodName: true}
+	for _, record := range records {
+		if record.Root == current.Root {
+			pods[record.PodName] = true
+		}
+	}
+	return len(pods)
+}
+
+// groupByRoot maps each distinct root to the pods that reported it.
+func groupByRoot(records []rootHashRecord) map[string][]string {
+	groups := make(map[string][]string)
+	for _, record := range records {
+		groups[record.Root] = append(groups[record.Root], record.PodName)
+	}
+	return groups
+}
+
+func knownRootHashesString(records []rootHashRecord) string {
+	// Pods are listed with the time they logged the root, when known.
+	groups := make(map[string][]string)
+	for _, record := range records {
+		pod := record.PodName
+		if !record.Timestamp.IsZero() {
+			pod = fmt.Sprintf("%s (%s)", pod, record.Timestamp.UTC().Format(time.RFC3339Nano))
+		}
+		groups[record.Root] = append(groups[record.Root], pod)
+	}
+
+	roots := make([]string, 0, len(groups))
+	for root := range groups {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	var s string
+	for _, root := range roots {
+		s += fmt.Sprintf("%s: %s\n", root, strings.Join(groups[root], ", "))
+	}
+	return s
+}
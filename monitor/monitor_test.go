@@ -0,0 +1,1072 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier records the messages it is given.
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// titles returns the titles of the messages recorded so far, in order.
+func (n *recordingNotifier) titles() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	titles := make([]string, len(n.messages))
+	for i, msg := range n.messages {
+		titles[i] = msg.Title
+	}
+	return titles
+}
+
+// testConfig returns the configuration of a monitor running the tm worker,
+// every other setting left to setDefaults.
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := &Config{EnableTM: true}
+	setDefaults(cfg)
+	return cfg
+}
+
+// commitEntry returns the commit log of `podName` at `height`, with `root`.
+func commitEntry(podName string, height int64, root string) LogEntry {
+	payload := fmt.Sprintf("finalizing commit of block     module=consensus height=%d hash=%s root=%s num_txs=0", height, strings.Repeat("ab", 16), root)
+	return NewLogEntry(podName, payload, time.Time{})
+}
+
+// processEntries runs processCommitLogs over `entries` and returns the
+// messages it notified.
+func processEntries(t *testing.T, cfg *Config, deps tmDeps, entries ...LogEntry) *recordingNotifier {
+	t.Helper()
+	in := make(chan LogEntry, len(entries))
+	for _, entry := range entries {
+		in <- entry
+	}
+	close(in)
+	notifier := &recordingNotifier{}
+	processCommitLogs(context.Background(), context.Background(), in, notifier, cfg, NetworkConfig{Name: t.Name()}, deps)
+	return notifier
+}
+
+// confirmedHeight returns the height confirmed by the worker that reported
+// the roots of the test's network to `roots`.
+func confirmedHeight(t *testing.T, roots *rootsAPI) int64 {
+	t.Helper()
+	_, state, ok := roots.state(t.Name())
+	if !ok {
+		t.Fatalf("network %s not tracked", t.Name())
+	}
+	return state.ConfirmedHeight()
+}
+
+func TestProcessCommitLogs(t *testing.T) {
+	tests := []struct {
+		name       string
+		quorumSize int
+		milestones int
+		entries    []LogEntry
+		want       []string
+		// confirmed is the confirmed height once the entries are processed.
+		confirmed int64
+	}{
+		{
+			name:       "agreement",
+			quorumSize: 2,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-0", 11, "bb"),
+				commitEntry("pod-1", 11, "bb"),
+			},
+			confirmed: 11,
+		},
+		{
+			name:       "mismatch",
+			quorumSize: 1,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "bb"),
+			},
+			want: []string{"Root mismatch"},
+		},
+		{
+			name:       "heights beyond 32 bits",
+			quorumSize: 2,
+			entries: []LogEntry{
+				commitEntry("pod-0", 1<<40, "aa"),
+				commitEntry("pod-1", 1<<40, "aa"),
+				commitEntry("pod-0", 1<<40+1, "bb"),
+				commitEntry("pod-1", 1<<40+1, "cc"),
+			},
+			want:      []string{"Root mismatch"},
+			confirmed: 1 << 40,
+		},
+		{
+			name:       "heights 2^32 apart",
+			quorumSize: 2,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 1<<32+10, "bb"),
+			},
+		},
+		{
+			name:       "mismatch alerted once",
+			quorumSize: 1,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "bb"),
+				commitEntry("pod-2", 10, "cc"),
+			},
+			want: []string{"Root mismatch"},
+		},
+		{
+			name:       "roots in different cases",
+			quorumSize: 2,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "ABCD"),
+				commitEntry("pod-1", 10, "abcd"),
+				commitEntry("pod-2", 10, "AbCd"),
+			},
+			confirmed: 10,
+		},
+		{
+			name:       "below quorum",
+			quorumSize: 3,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+			},
+		},
+		{
+			name:       "quorum reached",
+			quorumSize: 3,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-2", 10, "aa"),
+			},
+			confirmed: 10,
+		},
+		{
+			name:       "mismatch below quorum",
+			quorumSize: 3,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "bb"),
+			},
+			want: []string{"Root mismatch"},
+		},
+		{
+			name:       "quorum of three across heights",
+			quorumSize: 3,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-2", 10, "aa"),
+				commitEntry("pod-0", 11, "bb"),
+				commitEntry("pod-1", 11, "bb"),
+			},
+			confirmed: 10,
+		},
+		{
+			name:       "pod repeating itself",
+			quorumSize: 2,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-0", 10, "aa"),
+			},
+		},
+		{
+			name:       "milestone announced once",
+			quorumSize: 1,
+			milestones: 5,
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-0", 11, "bb"),
+			},
+			want:      []string{"Milestone"},
+			confirmed: 10,
+		},
+		{
+			name:       "entry without pod name",
+			quorumSize: 1,
+			entries: []LogEntry{
+				{payload: commitEntry("pod-0", 10, "aa").payload},
+				commitEntry("pod-1", 10, "bb"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.QuorumSize = tt.quorumSize
+			cfg.MilestoneInterval = tt.milestones
+			roots := newRootsAPI()
+			notifier := processEntries(t, cfg, tmDeps{Roots: roots}, tt.entries...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+			if got := confirmedHeight(t, roots); got != tt.confirmed {
+				t.Errorf("confirmed height = %d, want %d", got, tt.confirmed)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsThreePods(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		// wantRoots lists the roots in the mismatch alert, none if no
+		// mismatch is alerted.
+		wantRoots string
+	}{
+		{
+			name: "all agree",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-2", 10, "aa"),
+			},
+		},
+		{
+			name: "third differs",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-2", 10, "bb"),
+			},
+			wantRoots: "aa: pod-0, pod-1\nbb: pod-2\n",
+		},
+		{
+			name: "first differs",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "bb"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-2", 10, "aa"),
+			},
+			wantRoots: "aa: pod-1\nbb: pod-0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.QuorumSize = 1
+			notifier := processEntries(t, cfg, tmDeps{}, tt.entries...)
+
+			var mismatches []Message
+			for _, msg := range notifier.messages {
+				if msg.Title == "Root mismatch" {
+					mismatches = append(mismatches, msg)
+				}
+			}
+			if tt.wantRoots == "" {
+				if len(mismatches) != 0 {
+					t.Errorf("alerted %d mismatches, want none", len(mismatches))
+				}
+				return
+			}
+			if len(mismatches) != 1 {
+				t.Fatalf("alerted %d mismatches, want 1", len(mismatches))
+			}
+			if !strings.HasSuffix(mismatches[0].Body, tt.wantRoots) {
+				t.Errorf("mismatch alert %q, want the roots listed as %q", mismatches[0].Body, tt.wantRoots)
+			}
+		})
+	}
+}
+
+func TestKnownRootHashesString(t *testing.T) {
+	logged := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		records []rootHashRecord
+		want    string
+	}{
+		{"none", nil, ""},
+		{"single", []rootHashRecord{{PodName: "pod-0", Root: "aa"}}, "aa: pod-0\n"},
+		{
+			name:    "grouped by root",
+			records: []rootHashRecord{{PodName: "pod-2", Root: "bb"}, {PodName: "pod-0", Root: "aa"}, {PodName: "pod-1", Root: "bb"}},
+			want:    "aa: pod-0\nbb: pod-2, pod-1\n",
+		},
+		{"with the logged time", []rootHashRecord{{PodName: "pod-0", Root: "aa", Timestamp: logged}}, "aa: pod-0 (2024-03-01T12:00:00Z)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := knownRootHashesString(tt.records); got != tt.want {
+				t.Errorf("knownRootHashesString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsMismatchConfirmations(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QuorumSize = 1
+	cfg.MismatchConfirmations = 2
+
+	notifier := processEntries(t, cfg, tmDeps{},
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "bb"),
+	)
+	if got := notifier.titles(); len(got) != 0 {
+		t.Errorf("notified %q for a single divergence, want nothing until confirmed", got)
+	}
+
+	notifier = processEntries(t, cfg, tmDeps{},
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "bb"),
+		commitEntry("pod-0", 11, "cc"),
+		commitEntry("pod-1", 11, "dd"),
+	)
+	if got, want := notifier.titles(), []string{"Root mismatch"}; !slices.Equal(got, want) {
+		t.Errorf("notified %q for two consecutive divergences, want %q", got, want)
+	}
+	if got := notifier.messages[0].Commit.Height; got != 10 {
+		t.Errorf("mismatch alerted at height %d, want the first divergent height 10", got)
+	}
+}
+
+func TestProcessCommitLogsRestartMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		// cached are reported at height 5 before the chain moves on to 10.
+		cached []LogEntry
+		want   []string
+	}{
+		{"no cached record", nil, nil},
+		{"single cached record", []LogEntry{commitEntry("pod-0", 5, "aa")}, []string{"aa: pod-0"}},
+		{"several cached records", []LogEntry{commitEntry("pod-0", 5, "aa"), commitEntry("pod-1", 5, "aa")}, []string{"aa: pod-0, pod-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.QuorumSize = 1
+			entries := append(tt.cached,
+				commitEntry("pod-0", 10, "bb"),
+				commitEntry("pod-1", 10, "bb"),
+				// The chain restarted from height 5.
+				commitEntry("pod-0", 5, "cc"),
+			)
+
+			notifier := processEntries(t, cfg, tmDeps{}, entries...)
+			if got, want := notifier.titles(), []string{"Height regression", "Chain restart"}; !slices.Equal(got, want) {
+				t.Fatalf("notified %q, want %q", got, want)
+			}
+			body := notifier.messages[1].Body
+			if listed := strings.Contains(body, "before the restart"); listed != (len(tt.want) > 0) {
+				t.Errorf("restart message %q lists the previous roots: %v, want %v", body, listed, len(tt.want) > 0)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("restart message %q does not list %q", body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsRestart(t *testing.T) {
+	// Both pods agree on the previous chain up to height 11.
+	previousChain := []LogEntry{
+		commitEntry("pod-0", 5, "aa"),
+		commitEntry("pod-1", 5, "aa"),
+		commitEntry("pod-0", 10, "bb"),
+		commitEntry("pod-1", 10, "bb"),
+		commitEntry("pod-0", 11, "cc"),
+		commitEntry("pod-1", 11, "cc"),
+	}
+	tests := []struct {
+		name       string
+		milestones int
+		maxLag     int
+		entries    []LogEntry
+		want       []string
+		confirmed  int64
+	}{
+		{
+			name: "restart",
+			entries: []LogEntry{
+				// The first pod back is not compared to the roots of the
+				// previous chain, the second one confirms the restart.
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-1", 5, "dd"),
+				commitEntry("pod-0", 6, "ee"),
+				commitEntry("pod-1", 6, "ee"),
+			},
+			want:      []string{"Height regression", "Height regression", "Chain restart"},
+			confirmed: 6,
+		},
+		{
+			name: "mismatch on the new chain",
+			entries: []LogEntry{
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-1", 5, "dd"),
+				commitEntry("pod-0", 6, "ee"),
+				commitEntry("pod-1", 6, "ff"),
+			},
+			want:      []string{"Height regression", "Height regression", "Chain restart", "Root mismatch"},
+			confirmed: 5,
+		},
+		{
+			name: "mismatch at the restart height",
+			entries: []LogEntry{
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-1", 5, "ee"),
+			},
+			want: []string{"Height regression", "Height regression", "Chain restart", "Root mismatch"},
+		},
+		{
+			name:       "milestones announced again",
+			milestones: 5,
+			entries: []LogEntry{
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-1", 5, "dd"),
+			},
+			want:      []string{"Milestone", "Milestone", "Height regression", "Height regression", "Chain restart", "Milestone"},
+			confirmed: 5,
+		},
+		{
+			name:   "lags of the previous chain forgotten",
+			maxLag: 3,
+			entries: []LogEntry{
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-1", 5, "dd"),
+				commitEntry("pod-2", 5, "dd"),
+			},
+			want:      []string{"Height regression", "Height regression", "Chain restart"},
+			confirmed: 5,
+		},
+		{
+			name: "single pod rewound",
+			entries: []LogEntry{
+				// Left out of the comparison until it is back at the
+				// confirmed height.
+				commitEntry("pod-0", 5, "dd"),
+				commitEntry("pod-0", 10, "dd"),
+				commitEntry("pod-0", 11, "dd"),
+				commitEntry("pod-1", 12, "ff"),
+				commitEntry("pod-0", 12, "ff"),
+			},
+			want:      []string{"Height regression", "Conflicting reports", "Root mismatch"},
+			confirmed: 12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.MilestoneInterval = tt.milestones
+			cfg.MaxLagBlocks = tt.maxLag
+			roots := newRootsAPI()
+			tip := &chainTip{}
+			notifier := processEntries(t, cfg, tmDeps{Roots: roots, Tip: tip}, append(previousChain, tt.entries...)...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+			if got := confirmedHeight(t, roots); got != tt.confirmed {
+				t.Errorf("confirmed height = %d, want %d", got, tt.confirmed)
+			}
+			if height, _ := tip.Get(); height > 12 {
+				t.Errorf("chain tip = %d, want the height of the new chain", height)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsConfirmedHeightMonotonic(t *testing.T) {
+	cfg := testConfig(t)
+	// Logs delivered up to 5 blocks late are not regressions.
+	cfg.RegressionTolerance = 5
+	roots := newRootsAPI()
+	tip := &chainTip{}
+
+	notifier := processEntries(t, cfg, tmDeps{Roots: roots, Tip: tip},
+		commitEntry("pod-0", 12, "cc"),
+		commitEntry("pod-1", 12, "cc"),
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "aa"),
+		commitEntry("pod-0", 11, "bb"),
+		commitEntry("pod-1", 11, "bb"),
+	)
+	if got := notifier.titles(); len(got) != 0 {
+		t.Errorf("notified %q for logs delivered out of order, want nothing", got)
+	}
+	if got := confirmedHeight(t, roots); got != 12 {
+		t.Errorf("confirmed height = %d after agreements at lower heights, want 12", got)
+	}
+	if height, _ := tip.Get(); height != 12 {
+		t.Errorf("chain tip = %d, want 12", height)
+	}
+}
+
+func TestProcessCommitLogsPodRewound(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		want    []string
+		// wantBody is the regression alert, if any.
+		wantBody string
+	}{
+		{
+			name: "to an uncached height",
+			entries: []LogEntry{
+				commitEntry("pod-0", 20, "aa"),
+				commitEntry("pod-1", 20, "aa"),
+				commitEntry("pod-1", 7, "bb"),
+			},
+			want:     []string{"Height regression"},
+			wantBody: "**pod-1** went back from height 20 to 7",
+		},
+		{
+			name: "to a cached height",
+			entries: []LogEntry{
+				commitEntry("pod-0", 19, "aa"),
+				commitEntry("pod-1", 19, "aa"),
+				commitEntry("pod-0", 20, "bb"),
+				commitEntry("pod-1", 20, "bb"),
+				commitEntry("pod-0", 19, "aa"),
+			},
+			want:     []string{"Height regression"},
+			wantBody: "**pod-0** went back from height 20 to 19",
+		},
+		{
+			name: "other pods behind",
+			entries: []LogEntry{
+				commitEntry("pod-0", 20, "aa"),
+				commitEntry("pod-1", 20, "aa"),
+				// pod-2 was never ahead: it is lagging, not rewound.
+				commitEntry("pod-2", 7, "bb"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := processEntries(t, testConfig(t), tmDeps{}, tt.entries...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %q, want %q", got, tt.want)
+			}
+			if tt.wantBody != "" && notifier.messages[0].Body != tt.wantBody {
+				t.Errorf("regression alert = %q, want %q", notifier.messages[0].Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsPodConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		want    []string
+	}{
+		{
+			name: "same root again",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-0", 10, "aa"),
+			},
+		},
+		{
+			name: "conflicting roots",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-0", 10, "bb"),
+			},
+			want: []string{"Conflicting reports"},
+		},
+		{
+			// Only the earlier report of the pod is left out, its new one
+			// still diverges from the fleet.
+			name: "fleet agreeing with the first report",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-0", 10, "bb"),
+				commitEntry("pod-2", 10, "aa"),
+			},
+			want: []string{"Conflicting reports", "Root mismatch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.QuorumSize = 3
+			notifier := processEntries(t, cfg, tmDeps{}, tt.entries...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %q, want %q", got, tt.want)
+			}
+			if len(tt.want) > 0 {
+				body := notifier.messages[0].Body
+				if want := "**pod-0** reported root _bb_ at height **10**, after reporting _aa_"; !strings.Contains(body, want) {
+					t.Errorf("conflict alert %q does not contain %q", body, want)
+				}
+			}
+		})
+	}
+}
+
+// sliceSource streams its entries, then closes the channel.
+type sliceSource []LogEntry
+
+func (s sliceSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	defer close(out)
+	for _, entry := range s {
+		select {
+		case out <- entry:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// processPDErrors runs pdWorker over `entries` and returns the messages it
+// notified.
+func processPDErrors(t *testing.T, cfg *Config, deps pdDeps, entries ...LogEntry) *recordingNotifier {
+	t.Helper()
+	notifier := &recordingNotifier{}
+	deps.Health, deps.Events = newHealthTracker(), newEventBuffer(len(entries))
+	pdWorker(context.Background(), context.Background(), cfg, NetworkConfig{Name: t.Name()}, sliceSource(entries), notifier, deps)
+	return notifier
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"much too long", 4, "much" + truncatedSuffix},
+		{"ééééé", 3, "ééé" + truncatedSuffix},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestPDWorkerTruncatesErrors(t *testing.T) {
+	oversized := "panic: " + strings.Repeat("x", 3000)
+	tests := []struct {
+		name   string
+		upload bool
+	}{
+		{"truncated", false},
+		{"full error uploaded", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.UploadFullErrors = tt.upload
+			notifier := processPDErrors(t, cfg, pdDeps{}, NewLogEntry("pod-0", oversized, time.Time{}))
+			if len(notifier.messages) != 1 {
+				t.Fatalf("notified %q, want a single pd error", notifier.titles())
+			}
+
+			msg := notifier.messages[0]
+			want := "pod-0: " + oversized[:defaultMaxErrorChars] + truncatedSuffix
+			if msg.Body != want {
+				t.Errorf("body of %d characters, want the first %d of the payload, marked as truncated", len([]rune(msg.Body)), defaultMaxErrorChars)
+			}
+			if n := len([]rune(msg.Body)); n > discordMaxMessageLen {
+				t.Errorf("body of %d characters, over Discord's limit", n)
+			}
+			if got := msg.Attachment != nil; got != tt.upload {
+				t.Fatalf("attached the full error: %v, want %v", got, tt.upload)
+			}
+			if tt.upload && string(msg.Attachment.Content) != oversized {
+				t.Error("attachment is not the full payload")
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsStartupWatchdog(t *testing.T) {
+	tests := []struct {
+		name string
+		// early are received right away, late once the watchdog fired, if
+		// it did.
+		early, late []LogEntry
+		want        []string
+	}{
+		{"commit logs flowing", []LogEntry{commitEntry("pod-0", 10, "aa")}, nil, nil},
+		{"channel staying empty", nil, nil, []string{"No commit logs"}},
+		{"commit logs after the warning", nil, []LogEntry{commitEntry("pod-0", 10, "aa")}, []string{"No commit logs", "Commit logs received"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.StartupTimeout = 20 * time.Millisecond
+			in := make(chan LogEntry)
+			notifier := &recordingNotifier{}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				processCommitLogs(context.Background(), context.Background(), in, notifier, cfg, NetworkConfig{Name: t.Name()}, tmDeps{})
+			}()
+
+			for _, entry := range tt.early {
+				in <- entry
+			}
+			// Give the watchdog time to fire, had it not been disarmed.
+			time.Sleep(10 * cfg.StartupTimeout)
+			for _, entry := range tt.late {
+				in <- entry
+			}
+			close(in)
+			<-done
+
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessCommitLogsMilestoneOncePerHeight(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		// want are the pods the milestones are announced with.
+		want []string
+	}{
+		{
+			name: "three pods at a milestone",
+			entries: []LogEntry{
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-2", 10, "aa"),
+			},
+			want: []string{"pod-1"},
+		},
+		{
+			name: "between milestones",
+			entries: []LogEntry{
+				commitEntry("pod-0", 11, "aa"),
+				commitEntry("pod-1", 11, "aa"),
+				commitEntry("pod-2", 11, "aa"),
+			},
+		},
+		{
+			name: "successive milestones",
+			entries: []LogEntry{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-1", 15, "bb"),
+				commitEntry("pod-0", 15, "bb"),
+			},
+			want: []string{"pod-0", "pod-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.MilestoneInterval = 5
+			notifier := processEntries(t, cfg, tmDeps{}, tt.entries...)
+
+			var got []string
+			for _, msg := range notifier.messages {
+				if msg.Title != "Milestone" {
+					t.Errorf("notified %q, want milestones only", msg.Title)
+					continue
+				}
+				got = append(got, msg.Commit.PodName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("announced milestones with %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// txsEntry returns the commit log of `podName` at `height`, with `root` and
+// `numTxs` transactions.
+func txsEntry(podName string, height int64, root string, numTxs int) LogEntry {
+	payload := fmt.Sprintf("finalizing commit of block     module=consensus height=%d hash=%s root=%s num_txs=%d", height, strings.Repeat("ab", 16), root, numTxs)
+	return NewLogEntry(podName, payload, time.Time{})
+}
+
+func TestProcessCommitLogsNumTxsMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		want    []string
+	}{
+		{
+			name:    "same root and count",
+			entries: []LogEntry{txsEntry("pod-0", 10, "aa", 3), txsEntry("pod-1", 10, "aa", 3)},
+		},
+		{
+			name:    "same root, different counts",
+			entries: []LogEntry{txsEntry("pod-0", 10, "aa", 3), txsEntry("pod-1", 10, "aa", 4)},
+			want:    []string{"Transaction count mismatch"},
+		},
+		{
+			name: "alerted once per height",
+			entries: []LogEntry{
+				txsEntry("pod-0", 10, "aa", 3),
+				txsEntry("pod-1", 10, "aa", 4),
+				txsEntry("pod-2", 10, "aa", 5),
+			},
+			want: []string{"Transaction count mismatch"},
+		},
+		{
+			name:    "different roots",
+			entries: []LogEntry{txsEntry("pod-0", 10, "aa", 3), txsEntry("pod-1", 10, "bb", 4)},
+			want:    []string{"Root mismatch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := processEntries(t, testConfig(t), tmDeps{}, tt.entries...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %q, want %q", got, tt.want)
+			}
+			if len(tt.want) > 0 && tt.want[0] == "Transaction count mismatch" {
+				msg := notifier.messages[0]
+				if msg.Severity != SeverityWarning {
+					t.Errorf("severity = %s, want warning", msg.Severity)
+				}
+				if want := "**pod-1** reported 4 transactions in block **10**, and **pod-0** 3"; !strings.Contains(msg.Body, want) {
+					t.Errorf("alert %q does not contain %q", msg.Body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNumTxsConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		current rootHashRecord
+		records []rootHashRecord
+		want    bool
+	}{
+		{"same count", rootHashRecord{Root: "aa", NumTxs: 3}, []rootHashRecord{{Root: "aa", NumTxs: 3}}, false},
+		{"different count", rootHashRecord{Root: "aa", NumTxs: 3}, []rootHashRecord{{Root: "aa", NumTxs: 4}}, true},
+		{"different root", rootHashRecord{Root: "aa", NumTxs: 3}, []rootHashRecord{{Root: "bb", NumTxs: 4}}, false},
+		{"unknown count", rootHashRecord{Root: "aa", NumTxsUnknown: true}, []rootHashRecord{{Root: "aa", NumTxs: 4}}, false},
+		{"other count unknown", rootHashRecord{Root: "aa", NumTxs: 3}, []rootHashRecord{{Root: "aa", NumTxsUnknown: true}}, false},
+	}
+	for _, tt := range tests {
+		if _, got := numTxsConflict(tt.current, tt.records); got != tt.want {
+			t.Errorf("%s: numTxsConflict() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessCommitLogsReferencePod(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+		want    []string
+	}{
+		{
+			name:    "agreeing with the reference",
+			entries: []LogEntry{commitEntry("ref", 10, "aa"), commitEntry("pod-1", 10, "aa")},
+		},
+		{
+			name:    "diverging after the reference reported",
+			entries: []LogEntry{commitEntry("ref", 10, "aa"), commitEntry("pod-1", 10, "bb")},
+			want:    []string{"Divergence from reference", "Root mismatch"},
+		},
+		{
+			name:    "diverging before the reference reported",
+			entries: []LogEntry{commitEntry("pod-1", 10, "bb"), commitEntry("ref", 10, "aa")},
+			want:    []string{"Divergence from reference", "Root mismatch"},
+		},
+		{
+			name:    "reference not reported yet",
+			entries: []LogEntry{commitEntry("pod-1", 10, "bb"), commitEntry("pod-2", 10, "bb")},
+		},
+		{
+			name:    "majority against the reference",
+			entries: []LogEntry{commitEntry("pod-1", 10, "bb"), commitEntry("pod-2", 10, "bb"), commitEntry("ref", 10, "aa")},
+			want:    []string{"Divergence from reference", "Divergence from reference", "Root mismatch"},
+		},
+		{
+			name:    "redelivered divergence",
+			entries: []LogEntry{commitEntry("ref", 10, "aa"), commitEntry("pod-1", 10, "bb"), commitEntry("pod-1", 10, "bb")},
+			want:    []string{"Divergence from reference", "Root mismatch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan LogEntry, len(tt.entries))
+			for _, entry := range tt.entries {
+				in <- entry
+			}
+			close(in)
+			notifier := &recordingNotifier{}
+			processCommitLogs(context.Background(), context.Background(), in, notifier, testConfig(t), NetworkConfig{Name: t.Name(), ReferencePod: "ref"}, tmDeps{})
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivergingFromReference(t *testing.T) {
+	records := []rootHashRecord{{PodName: "pod-1", Root: "bb"}, {PodName: "ref", Root: "aa"}, {PodName: "pod-2", Root: "aa"}}
+	tests := []struct {
+		name         string
+		records      []rootHashRecord
+		wantRoot     string
+		wantDiverged []string
+		wantReported bool
+	}{
+		{"reported", records, "aa", []string{"pod-1"}, true},
+		{"not reported", records[:1], "", nil, false},
+		{"alone", records[1:2], "aa", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reference, diverging, reported := divergingFromReference("ref", tt.records)
+			var diverged []string
+			for _, d := range diverging {
+				diverged = append(diverged, d.PodName)
+			}
+			if reference.Root != tt.wantRoot || !slices.Equal(diverged, tt.wantDiverged) || reported != tt.wantReported {
+				t.Errorf("divergingFromReference() = %s, %v, %v, want %s, %v, %v", reference.Root, diverged, reported, tt.wantRoot, tt.wantDiverged, tt.wantReported)
+			}
+		})
+	}
+}
+
+func TestRunMetricsBindFailure(t *testing.T) {
+	// The metrics address is already in use.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	notifier := &recordingNotifier{}
+	cfg := testConfig(t)
+	cfg.MetricsAddr = listener.Addr().String()
+	cfg.Networks = []NetworkConfig{{
+		Name: "testnet",
+		// Held until the metrics server failed, then compared.
+		TMLogSource: delayedSource{wait: func() bool { return slices.Contains(notifier.titles(), "Endpoints unavailable") }, entries: []LogEntry{commitEntry("pod-0", 10, "aa"), commitEntry("pod-1", 10, "bb")}},
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := New(*cfg, notifier).Run(ctx); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	titles := notifier.titles()
+	for _, want := range []string{"Endpoints unavailable", "[testnet] Root mismatch"} {
+		if !slices.Contains(titles, want) {
+			t.Errorf("notified %q, want %q among them", titles, want)
+		}
+	}
+}
+
+// delayedSource streams its entries once `wait` holds, then closes the
+// channel.
+type delayedSource struct {
+	wait    func() bool
+	entries []LogEntry
+}
+
+func (s delayedSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	for !s.wait() {
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			close(out)
+			return nil
+		}
+	}
+	return sliceSource(s.entries).Stream(ctx, out)
+}
+
+func TestRunEnvTag(t *testing.T) {
+	notifier := &recordingNotifier{}
+	cfg := testConfig(t)
+	cfg.EnvTag = "prod"
+	cfg.MetricsAddr = "127.0.0.1:0"
+	cfg.Networks = []NetworkConfig{{Name: "testnet", TMLogSource: sliceSource{commitEntry("pod-0", 10, "aa"), commitEntry("pod-1", 10, "bb")}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := New(*cfg, notifier).Run(ctx); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	// Every message delivered is prefixed, the one of the shutdown too.
+	for _, title := range notifier.titles() {
+		if !strings.HasPrefix(title, "[prod] ") {
+			t.Errorf("notified %q, want it prefixed with [prod]", title)
+		}
+	}
+	if want := "[prod] [testnet] Root mismatch"; !slices.Contains(notifier.titles(), want) {
+		t.Errorf("notified %q, want %q among them", notifier.titles(), want)
+	}
+}
+
+func TestRunWithoutLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries sliceSource
+		want    []string
+	}{
+		{
+			name:    "fork",
+			entries: sliceSource{commitEntry("pod-0", 10, "bb"), commitEntry("pod-1", 10, "cc")},
+			want:    []string{"[testnet] Root mismatch", "Shutdown"},
+		},
+		{
+			name: "late log",
+			entries: sliceSource{
+				commitEntry("pod-0", 10, "aa"),
+				commitEntry("pod-1", 10, "aa"),
+				commitEntry("pod-0", 11, "bb"),
+				commitEntry("pod-1", 11, "bb"),
+				commitEntry("pod-0", 9, "ff"),
+			},
+			want: []string{"[testnet] Height regression", "Shutdown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// As an embedder would, leaving the settings to their defaults.
+			cfg := Config{
+				EnableTM:    true,
+				MetricsAddr: "127.0.0.1:0",
+				Networks:    []NetworkConfig{{Name: "testnet", TMLogSource: tt.entries}},
+			}
+			notifier := &recordingNotifier{}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := New(cfg, notifier).Run(ctx); err != nil {
+				t.Fatalf("Run() = %v, want nil", err)
+			}
+			// The health server may fail to listen, which is not checked here.
+			titles := slices.DeleteFunc(notifier.titles(), func(title string) bool { return title == "Endpoints unavailable" })
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("notified %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import "sync"
+
+// monitorState is the state of a network shared by the goroutines that
+// monitor it: the roots reported at recent heights and the highest height
+// at which pods agreed. It is safe for concurrent use.
+type monitorState struct {
+	roots *rootCache
+
+	mu              sync.RWMutex
+	confirmedHeight int64
+}
+
+// newMonitorState keeps the roots of the `window` heights leading up to the
+// highest one seen.
+func newMonitorState(window int) *monitorState {
+	return &monitorState{roots: newRootCache(window)}
+}
+
+// RecordRoot adds `record` to the roots reported at `height` and returns the
+// roots reported there before it.
+func (s *monitorState) RecordRoot(height int64, record rootHashRecord) []rootHashRecord {
+	return s.roots.Append(height, record)
+}
+
+// Roots returns the roots reported at `height`.
+func (s *monitorState) Roots(height int64) ([]rootHashRecord, bool) {
+	return s.roots.Get(height)
+}
+
+// RecentHeights returns the roots of the `n` heights leading up to the
+// highest one seen.
+func (s *monitorState) RecentHeights(n int) map[int64][]rootHashRecord {
+	return s.roots.Recent(n)
+}
+
+// Restore replaces the state with one persisted earlier.
+func (s *monitorState) Restore(state *savedState) {
+	for height, records := range state.Roots {
+		s.roots.Set(height, records)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confirmedHeight = state.ConfirmedHeight
+}
+
+// ConfirmedHeight returns the highest height at which pods agreed.
+func (s *monitorState) ConfirmedHeight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.confirmedHeight
+}
+
+// Confirm records that pods agreed at `height`, and reports whether it is
+// the highest such height so far. The confirmed height only goes up, the
+// agreements at lower heights, e.g. of logs delivered late, are ignored.
+// Only Restart lowers it.
+func (s *monitorState) Confirm(height int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height <= s.confirmedHeight {
+		return false
+	}
+	s.confirmedHeight = height
+	return true
+}
+
+// Restart forgets the roots and the confirmed height after the chain
+// restarted from a lower height, whose heights are then reported again with
+// the roots of the new chain.
+func (s *monitorState) Restart() {
+	s.roots.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confirmedHeight = 0
+}
+
+// Snapshot captures the confirmed height and the roots recorded for the
+// `window` heights leading up to the highest one seen.
+func (s *monitorState) Snapshot(window int) *savedState {
+	return &savedState{
+		ConfirmedHeight: s.ConfirmedHeight(),
+		Roots:           s.RecentHeights(window),
+	}
+}
+
+// CachedHeights returns the number of heights whose roots are kept.
+func (s *monitorState) CachedHeights() int {
+	return s.roots.Len()
+}
@@ -0,0 +1,180 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NetworkConfig describes a chain whose nodes are monitored.
+type NetworkConfig struct {
+	// Name identifies the network in logs and alerts.
+	Name string `json:"name"`
+	// Cluster is the GKE cluster running the nodes.
+	Cluster string `json:"cluster"`
+	// PodPrefix selects the pods belonging to this network.
+	PodPrefix string `json:"pod_prefix"`
+	// ProjectID is the GCP project the logs are pulled from. ProjectIDs
+	// lists several projects, for networks spanning more than one.
+	ProjectID  string   `json:"project_id,omitempty"`
+	ProjectIDs []string `json:"project_ids,omitempty"`
+	// StateFile is where the tm worker persists its state, if set.
+	StateFile string `json:"state_file,omitempty"`
+	// ExpectedPods are tracked for liveness from startup. Other pods are
+	// tracked once they first report.
+	ExpectedPods []string `json:"expected_pods,omitempty"`
+	// ReferencePod, if set, is the pod trusted as canonical: the roots of
+	// the other pods are compared to its own.
+	ReferencePod string `json:"reference_pod,omitempty"`
+	// TMLogFilter and PDLogFilter override the GCP filters selecting the
+	// commit logs and the error logs.
+	TMLogFilter string `json:"tm_log_filter,omitempty"`
+	PDLogFilter string `json:"pd_log_filter,omitempty"`
+	// PubSubSubscription and PubSubPDSubscription, if set, are the Pub/Sub
+	// subscriptions the commit logs and the error logs are pulled from
+	// instead of tailing them. Short names are looked up in the first
+	// project.
+	PubSubSubscription   string `json:"pubsub_subscription,omitempty"`
+	PubSubPDSubscription string `json:"pubsub_pd_subscription,omitempty"`
+	// TMLogSource and PDLogSource, if set by an embedder, are where the
+	// commit logs and the error logs are read from instead.
+	TMLogSource LogSource `json:"-"`
+	PDLogSource LogSource `json:"-"`
+}
+
+// Projects returns every GCP project the logs of the network are pulled from.
+func (n NetworkConfig) Projects() []string {
+	var projects []string
+	if n.ProjectID != "" {
+		projects = append(projects, n.ProjectID)
+	}
+	for _, projectID := range n.ProjectIDs {
+		if projectID != n.ProjectID {
+			projects = append(projects, projectID)
+		}
+	}
+	return projects
+}
+
+func (n NetworkConfig) CommitLogFilter() string {
+	if n.TMLogFilter != "" {
+		return n.TMLogFilter
+	}
+	return fmt.Sprintf(`resource.labels.container_name="tm" AND resource.labels.cluster_name="%s" AND resource.labels.pod_name:"%s"`, n.Cluster, n.PodPrefix)
+}
+
+func (n NetworkConfig) ErrorLogFilter() string {
+	if n.PDLogFilter != "" {
+		return n.PDLogFilter
+	}
+	return fmt.Sprintf(`resource.labels.container_name="pd" AND resource.labels.cluster_name="%s" AND resource.labels.pod_name:"%s" AND severity>=ERROR`, n.Cluster, n.PodPrefix)
+}
+
+// CommitSubscription returns the full name of the Pub/Sub subscription the
+// commit logs are pulled from, empty if they are tailed.
+func (n NetworkConfig) CommitSubscription() string {
+	return subscriptionName(n.PubSubSubscription, n.firstProject())
+}
+
+// ErrorSubscription returns the full name of the Pub/Sub subscription the
+// error logs are pulled from, empty if they are tailed.
+func (n NetworkConfig) ErrorSubscription() string {
+	return subscriptionName(n.PubSubPDSubscription, n.firstProject())
+}
+
+func (n NetworkConfig) firstProject() string {
+	if projects := n.Projects(); len(projects) > 0 {
+		return projects[0]
+	}
+	return ""
+}
+
+func (n NetworkConfig) validate() error {
+	for _, projectID := range n.ProjectIDs {
+		if strings.TrimSpace(projectID) == "" {
+			return fmt.Errorf("network %s: project_ids contains an empty project", n.Name)
+		}
+	}
+
+	// The cluster and pod prefix are only needed to build the default
+	// filters of the logs that are tailed.
+	defaultFilters := (n.TMLogFilter == "" && n.PubSubSubscription == "") || (n.PDLogFilter == "" && n.PubSubPDSubscription == "")
+
+	switch {
+	case n.Name == "":
+		return fmt.Errorf("network name is empty")
+	case n.Cluster == "" && defaultFilters:
+		return fmt.Errorf("network %s: cluster is empty", n.Name)
+	case n.PodPrefix == "" && defaultFilters:
+		return fmt.Errorf("network %s: pod_prefix is empty", n.Name)
+	case len(n.Projects()) == 0:
+		return fmt.Errorf("network %s: project_id and project_ids are empty", n.Name)
+	case n.TMLogFilter != "" && strings.TrimSpace(n.TMLogFilter) == "":
+		return fmt.Errorf("network %s: tm_log_filter is blank", n.Name)
+	case n.PDLogFilter != "" && strings.TrimSpace(n.PDLogFilter) == "":
+		return fmt.Errorf("network %s: pd_log_filter is blank", n.Name)
+	case n.PubSubSubscription != "" && strings.TrimSpace(n.PubSubSubscription) == "":
+		return fmt.Errorf("network %s: pubsub_subscription is blank", n.Name)
+	case n.PubSubPDSubscription != "" && strings.TrimSpace(n.PubSubPDSubscription) == "":
+		return fmt.Errorf("network %s: pubsub_pd_subscription is blank", n.Name)
+	}
+	return nil
+}
+
+// loadNetworks reads the monitored networks from the JSON file at `path`.
+// Networks without their own log filters use `tmFilter` and `pdFilter`, if set.
+func loadNetworks(path, tmFilter, pdFilter string) ([]NetworkConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading networks config: %v", err)
+	}
+
+	var networks []NetworkConfig
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return nil, fmt.Errorf("decoding networks config: %v", err)
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("networks config %s lists no network", path)
+	}
+
+	seen := make(map[string]bool)
+	for i, network := range networks {
+		if network.TMLogFilter == "" {
+			networks[i].TMLogFilter = tmFilter
+		}
+		if network.PDLogFilter == "" {
+			networks[i].PDLogFilter = pdFilter
+		}
+		if err := networks[i].validate(); err != nil {
+			return nil, err
+		}
+		if seen[network.Name] {
+			return nil, fmt.Errorf("network %s is listed twice", network.Name)
+		}
+		seen[network.Name] = true
+	}
+
+	return networks, nil
+}
+
+// networkNotifier prefixes every alert with the name of the network it
+// originates from.
+type networkNotifier struct {
+	network  string
+	notifier Notifier
+}
+
+func (n networkNotifier) Notify(ctx context.Context, msg Message) error {
+	msg.Network = n.network
+	if msg.IncidentKey != "" {
+		msg.IncidentKey = n.network + "/" + msg.IncidentKey
+	}
+	if msg.Title != "" {
+		msg.Title = fmt.Sprintf("[%s] %s", n.network, msg.Title)
+	} else {
+		msg.Body = fmt.Sprintf("[%s] %s", n.network, msg.Body)
+	}
+	return n.notifier.Notify(ctx, msg)
+}
@@ -0,0 +1,399 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Message is an alert emitted by one of the workers.
+type Message struct {
+	Severity Severity
+	Title    string
+	Body     string
+	// IncidentKey identifies the incident the message is about, so that
+	// backends can deduplicate it and later resolve it.
+	IncidentKey string
+	// Resolved marks a recovery notice for the incident IncidentKey.
+	Resolved bool
+
+	// Event identifies the kind of alert, e.g. EventMismatch, for the
+	// backends whose payload is templated. It is empty for other alerts.
+	Event string
+	// Network is the network the alert is about, set by networkNotifier.
+	Network string
+	// EnvTag identifies the monitor instance, e.g. "prod", set by
+	// envTagNotifier.
+	EnvTag string
+	// Commit is the commit log the alert is about, if any.
+	Commit *LogData
+	// Attachment is uploaded along with the message by the backends that
+	// support files, e.g. the full payload of a truncated pd error.
+	Attachment *Attachment
+
+	// spanContext identifies the span of the work that raised the message,
+	// if traced.
+	spanContext trace.SpanContext
+}
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Name    string
+	Content []byte
+}
+
+// Notifier delivers alerts to an external channel.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// payloadRenderer is implemented by notifiers that can render the request
+// body they would send for a message.
+type payloadRenderer interface {
+	Payload(msg Message) ([]byte, error)
+}
+
+// dryRunNotifier logs the messages it is given instead of delivering them.
+type dryRunNotifier struct {
+	Backend  string
+	Renderer payloadRenderer
+}
+
+func (d *dryRunNotifier) Notify(ctx context.Context, msg Message) error {
+	attrs := []interface{}{
+		"backend", d.Backend,
+		"severity", msg.Severity.String(),
+		"title", msg.Title,
+		"body", msg.Body,
+	}
+	if msg.Attachment != nil {
+		attrs = append(attrs, "attachment", msg.Attachment.Name, "attachment_bytes", len(msg.Attachment.Content))
+	}
+	if d.Renderer != nil {
+		payload, err := d.Renderer.Payload(msg)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, "payload", string(payload))
+	}
+
+	slog.Info("dry run: would have sent alert", attrs...)
+	return nil
+}
+
+// drainAndClose discards the rest of a response body and closes it, so
+// that the underlying connection can be reused.
+func drainAndClose(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
+
+// discordNotifier posts messages to a Discord webhook.
+type discordNotifier struct {
+	WebhookURL string
+	// SeverityWebhookURLs routes the messages of a given severity to
+	// another webhook, e.g. critical alerts to an on-call channel.
+	SeverityWebhookURLs map[Severity]string
+	// Mention, e.g. a role mention `<@&id>`, is prepended to critical
+	// messages.
+	Mention string
+	// mention, if set, returns the mention instead of Mention, so that it
+	// follows the reloaded configuration.
+	mention func() string
+	// metrics, if set, count the messages that could not be delivered.
+	metrics *metrics
+	Client  *http.Client
+	// MaxRetries is the number of times a failed delivery is retried.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on every
+	// subsequent one. A 429 `Retry-After` header takes precedence.
+	RetryBackoff time.Duration
+}
+
+func newDiscordNotifier(webhookURL string, severityWebhookURLs map[Severity]string, mention string, client *http.Client) *discordNotifier {
+	return &discordNotifier{
+		WebhookURL:          webhookURL,
+		SeverityWebhookURLs: severityWebhookURLs,
+		Mention:             mention,
+		Client:              client,
+		MaxRetries:          3,
+		RetryBackoff:        500 * time.Millisecond,
+	}
+}
+
+// countFailure counts a message that could not be delivered.
+func (d *discordNotifier) countFailure() {
+	if d.metrics != nil {
+		d.metrics.discordFailures.Inc()
+	}
+}
+
+// webhookURL returns the webhook the messages of `severity` are posted to.
+func (d *discordNotifier) webhookURL(severity Severity) string {
+	if url, ok := d.SeverityWebhookURLs[severity]; ok {
+		return url
+	}
+	return d.WebhookURL
+}
+
+func (d *discordNotifier) Payload(msg Message) ([]byte, error) {
+	content := msg.Body
+	if msg.Title != "" {
+		content = fmt.Sprintf("**%s**\n%s", msg.Title, msg.Body)
+	}
+	mention := d.Mention
+	if d.mention != nil {
+		mention = d.mention()
+	}
+	if mention != "" && msg.Severity == SeverityCritical {
+		content = mention + " " + content
+	}
+
+	payload := map[string]interface{}{
+		"content": content,
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling discord payload: %v", err)
+	}
+	return payloadBytes, nil
+}
+
+func (d *discordNotifier) Notify(ctx context.Context, msg Message) error {
+	payloadBytes, err := d.Payload(msg)
+	if err != nil {
+		return err
+	}
+
+	contentType := "application/json"
+	if msg.Attachment != nil {
+		payloadBytes, contentType, err = discordMultipart(payloadBytes, msg.Attachment)
+		if err != nil {
+			return err
+		}
+	}
+
+	webhookURL := d.webhookURL(msg.Severity)
+	backoff := d.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := d.post(ctx, webhookURL, contentType, payloadBytes)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= d.MaxRetries {
+			d.countFailure()
+			return err
+		}
+
+		delay := backoff
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		backoff *= 2
+
+		select {
+		case <-ctx.Done():
+			d.countFailure()
+			return fmt.Errorf("%v (giving up: %v)", err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// post makes a single delivery attempt. On failure it returns the delay
+// requested by Discord (zero if none), or a negative duration if the
+// error is not worth retrying.
+func (d *discordNotifier) post(ctx context.Context, webhookURL, contentType string, payload []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return -1, fmt.Errorf("building discord request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("posting to discord: %v", err)
+	}
+	defer drainAndClose(resp)
+
+	switch {
+	case resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("discord webhook rate limited: %s", resp.Status)
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("discord webhook returned %s", resp.Status)
+	default:
+		return -1, fmt.Errorf("discord webhook returned %s", resp.Status)
+	}
+}
+
+// discordMultipart wraps a JSON payload and a file into the multipart body
+// Discord expects for uploads, returning it with its content type.
+func discordMultipart(payload []byte, attachment *Attachment) ([]byte, string, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if err := w.WriteField("payload_json", string(payload)); err != nil {
+		return nil, "", fmt.Errorf("building discord upload: %v", err)
+	}
+	part, err := w.CreateFormFile("files[0]", attachment.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("building discord upload: %v", err)
+	}
+	if _, err := part.Write(attachment.Content); err != nil {
+		return nil, "", fmt.Errorf("building discord upload: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("building discord upload: %v", err)
+	}
+	return b.Bytes(), w.FormDataContentType(), nil
+}
+
+// parseRetryAfter parses a `Retry-After` header expressed in (possibly
+// fractional) seconds.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// envTagNotifier prefixes the messages with the tag of the monitor instance,
+// so that the alerts of several instances sharing a channel can be told
+// apart.
+type envTagNotifier struct {
+	tag      string
+	notifier Notifier
+}
+
+func (n envTagNotifier) Notify(ctx context.Context, msg Message) error {
+	msg.EnvTag = n.tag
+	if msg.IncidentKey != "" {
+		msg.IncidentKey = n.tag + "/" + msg.IncidentKey
+	}
+	if msg.Title != "" {
+		msg.Title = fmt.Sprintf("[%s] %s", n.tag, msg.Title)
+	} else {
+		msg.Body = fmt.Sprintf("[%s] %s", n.tag, msg.Body)
+	}
+	return n.notifier.Notify(ctx, msg)
+}
+
+// severityGate keeps the alerts below the minimum severity of `backend`,
+// as last reloaded, from it, along with the resolutions of the incidents
+// they opened. The resolutions of the incidents it did not see, e.g. opened
+// before a restart, go through, so that those the backend received are
+// closed.
+type severityGate struct {
+	backend  string
+	cfg      *liveConfig
+	notifier Notifier
+	withheld *withheldIncidents
+}
+
+// newSeverityGate gates the messages to `notifier`, the backend named
+// `backend`, with the minimum severity of `cfg`.
+func newSeverityGate(backend string, cfg *liveConfig, notifier Notifier) severityGate {
+	return severityGate{backend: backend, cfg: cfg, notifier: notifier, withheld: &withheldIncidents{keys: make(map[string]bool)}}
+}
+
+// accepts reports whether `msg` is for the backend, recording the incidents
+// kept from it. It is called again on the messages it accepted, e.g. by
+// notifyPool then Notify, so it only forgets an incident once its
+// resolution is kept from the backend.
+func (g severityGate) accepts(msg Message) bool {
+	if msg.Resolved {
+		return msg.IncidentKey == "" || !g.withheld.resolve(msg.IncidentKey)
+	}
+	accepted := msg.Severity >= g.cfg.Load().minSeverity(g.backend)
+	if msg.IncidentKey != "" {
+		g.withheld.set(msg.IncidentKey, !accepted)
+	}
+	return accepted
+}
+
+func (g severityGate) Notify(ctx context.Context, msg Message) error {
+	if !g.accepts(msg) {
+		return nil
+	}
+	return g.notifier.Notify(ctx, msg)
+}
+
+// withheldIncidents are the keys of the incidents a severityGate kept from
+// its backend. An incident escalated past the gate is no longer withheld.
+type withheldIncidents struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (w *withheldIncidents) set(key string, withheld bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if withheld {
+		w.keys[key] = true
+	} else {
+		delete(w.keys, key)
+	}
+}
+
+// resolve forgets the incident `key`, reporting whether it was withheld.
+func (w *withheldIncidents) resolve(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	withheld := w.keys[key]
+	delete(w.keys, key)
+	return withheld
+}
+
+// multiNotifier fans a message out to several notifiers.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, msg Message) error {
+	var errs []string
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSeverityGate(t *testing.T) {
+	tests := []struct {
+		name          string
+		backend       string
+		minSeverities map[string]Severity
+		msg           Message
+		want          bool
+	}{
+		{"info to slack", "slack", nil, Message{Severity: SeverityInfo}, true},
+		{"info to pagerduty", "pagerduty", nil, Message{Severity: SeverityInfo}, false},
+		{"error to pagerduty", "pagerduty", nil, Message{Severity: SeverityError}, false},
+		{"critical to pagerduty", "pagerduty", nil, Message{Severity: SeverityCritical}, true},
+		{"resolution to pagerduty", "pagerduty", nil, Message{Severity: SeverityInfo, Resolved: true}, true},
+		{"below the configured severity", "slack", map[string]Severity{"slack": SeverityWarning}, Message{Severity: SeverityInfo}, false},
+		{"at the configured severity", "slack", map[string]Severity{"slack": SeverityWarning}, Message{Severity: SeverityWarning}, true},
+		{"pagerduty lowered", "pagerduty", map[string]Severity{"pagerduty": SeverityError}, Message{Severity: SeverityError}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &recordingNotifier{}
+			gate := newSeverityGate(tt.backend, newLiveConfig(&Config{MinSeverities: tt.minSeverities}), backend)
+			if err := gate.Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(backend.titles()) == 1; got != tt.want {
+				t.Errorf("delivered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityGateResolutions(t *testing.T) {
+	messages := []Message{
+		{Severity: SeverityWarning, Title: "Pod lagging", IncidentKey: "lag"},
+		{Severity: SeverityInfo, Title: "Pod caught up", IncidentKey: "lag", Resolved: true},
+		{Severity: SeverityCritical, Title: "Root mismatch", IncidentKey: "mismatch-10"},
+		{Severity: SeverityInfo, Title: "Root mismatch cleared", IncidentKey: "mismatch-10", Resolved: true},
+		{Severity: SeverityError, Title: "Chain stalled", IncidentKey: "stall"},
+		{Severity: SeverityCritical, Title: "Chain still stalled", IncidentKey: "stall"},
+		{Severity: SeverityInfo, Title: "Chain resumed", IncidentKey: "stall", Resolved: true},
+		// Opened before a restart.
+		{Severity: SeverityInfo, Title: "Monitoring restored", IncidentKey: "blind", Resolved: true},
+	}
+	want := []string{"Root mismatch", "Root mismatch cleared", "Chain still stalled", "Chain resumed", "Monitoring restored"}
+
+	tests := []struct {
+		name string
+		// pooled delivers through a notifyPool, which checks the gate
+		// before queueing.
+		pooled bool
+	}{
+		{name: "direct"},
+		{name: "pooled", pooled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &recordingNotifier{}
+			var notifier Notifier = newSeverityGate("pagerduty", newLiveConfig(&Config{}), backend)
+			if tt.pooled {
+				pool := newNotifyPool(multiNotifier{notifier}, 1, 10)
+				runPool(t, pool)
+				notifier = pool
+			}
+			for _, msg := range messages {
+				if err := notifier.Notify(context.Background(), msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if pool, ok := notifier.(*notifyPool); ok {
+				if err := pool.Flush(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The pool delivers the most severe messages first.
+			got, want := backend.titles(), slices.Clone(want)
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("delivered %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSeverityGateReloaded(t *testing.T) {
+	live := newLiveConfig(&Config{})
+	slack, pagerDuty := &recordingNotifier{}, &recordingNotifier{}
+	notifier := multiNotifier{
+		newSeverityGate("slack", live, slack),
+		newSeverityGate("pagerduty", live, pagerDuty),
+	}
+	info := Message{Severity: SeverityInfo, Title: "Milestone"}
+
+	if err := notifier.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	if len(slack.titles()) != 1 || len(pagerDuty.titles()) != 0 {
+		t.Fatalf("info message delivered to slack %d times and pagerduty %d times, want 1 and 0", len(slack.titles()), len(pagerDuty.titles()))
+	}
+
+	live.Store(&Config{MinSeverities: map[string]Severity{"slack": SeverityCritical, "pagerduty": SeverityInfo}})
+	if err := notifier.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	if len(slack.titles()) != 1 || len(pagerDuty.titles()) != 1 {
+		t.Errorf("after reloading, info message delivered to slack %d times and pagerduty %d times, want 1 and 1", len(slack.titles()), len(pagerDuty.titles()))
+	}
+}
+
+func TestEnvTagNotifier(t *testing.T) {
+	tests := []struct {
+		name            string
+		msg             Message
+		wantTitle       string
+		wantBody        string
+		wantIncidentKey string
+	}{
+		{
+			name:      "titled",
+			msg:       Message{Title: "Root mismatch", Body: "block 10"},
+			wantTitle: "[prod] Root mismatch",
+			wantBody:  "block 10",
+		},
+		{
+			name:     "untitled",
+			msg:      Message{Body: "pod lagging"},
+			wantBody: "[prod] pod lagging",
+		},
+		{
+			name:            "incident",
+			msg:             Message{Title: "Root mismatch", IncidentKey: "mismatch-10"},
+			wantTitle:       "[prod] Root mismatch",
+			wantIncidentKey: "prod/mismatch-10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivered := &recordingNotifier{}
+			if err := (envTagNotifier{tag: "prod", notifier: delivered}).Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			msg := delivered.messages[0]
+			if msg.Title != tt.wantTitle || msg.Body != tt.wantBody || msg.IncidentKey != tt.wantIncidentKey || msg.EnvTag != "prod" {
+				t.Errorf("delivered %+v, want title %q, body %q, incident key %q and env tag prod", msg, tt.wantTitle, tt.wantBody, tt.wantIncidentKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// instrumentedNotifier measures the deliveries of a backend: how long they
+// take, whether they succeed and the HTTP status the backend last answered.
+type instrumentedNotifier struct {
+	backend  string
+	notifier Notifier
+	metrics  *metrics
+}
+
+func (n instrumentedNotifier) Notify(ctx context.Context, msg Message) error {
+	status := &responseStatus{}
+	ctx = context.WithValue(ctx, responseStatusKey{}, status)
+
+	start := time.Now()
+	err := n.notifier.Notify(ctx, msg)
+	n.metrics.notifyDuration.WithLabelValues(n.backend).Observe(time.Since(start).Seconds())
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	n.metrics.notifyDeliveries.WithLabelValues(n.backend, result, status.String()).Inc()
+	return err
+}
+
+type responseStatusKey struct{}
+
+// responseStatus is the HTTP status of the last response received while
+// delivering a message.
+type responseStatus struct {
+	code atomic.Int32
+}
+
+// String returns the status code, or "none" if no response was received.
+func (s *responseStatus) String() string {
+	if code := s.code.Load(); code != 0 {
+		return strconv.Itoa(int(code))
+	}
+	return "none"
+}
+
+// statusTransport records the status of the HTTP responses on the
+// responseStatus carried by the request, if any.
+type statusTransport struct {
+	base http.RoundTripper
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if status, ok := req.Context().Value(responseStatusKey{}).(*responseStatus); ok && resp != nil {
+		status.code.Store(int32(resp.StatusCode))
+	}
+	return resp, err
+}
@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotifyMetrics(t *testing.T) {
+	discord, _ := countingServer(t, http.StatusNoContent)
+	slack, _ := countingServer(t, http.StatusInternalServerError)
+	cfg := &Config{DiscordWebhookURL: discord.URL, SlackWebhookURL: slack.URL}
+	setDefaults(cfg)
+	metrics := newMetrics()
+	server := httptest.NewServer(metricsHandler(newMetricsRegistry(metrics, "")))
+	defer server.Close()
+
+	notifier := buildNotifier(newLiveConfig(cfg), nil, metrics)
+	if err := notifier.Notify(context.Background(), Message{Severity: SeverityWarning, Title: "Pod lagging"}); err == nil {
+		t.Error("Notify() = nil with Slack failing, want an error")
+	}
+
+	tests := []struct {
+		sample string
+		want   float64
+	}{
+		{`apphash_notify_duration_seconds_count{backend="discord"}`, 1},
+		{`apphash_notify_duration_seconds_count{backend="slack"}`, 1},
+		{`apphash_notify_deliveries_total{backend="discord",result="success",status="204"}`, 1},
+		{`apphash_notify_deliveries_total{backend="slack",result="failure",status="500"}`, 1},
+	}
+	for _, tt := range tests {
+		if got := scrape(t, server.URL, tt.sample); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.sample, got, tt.want)
+		}
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	status := &responseStatus{}
+	if got := status.String(); got != "none" {
+		t.Errorf("String() = %q before any response, want none", got)
+	}
+	status.code.Store(http.StatusTooManyRequests)
+	if got := status.String(); got != "429" {
+		t.Errorf("String() = %q, want 429", got)
+	}
+}
@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// notifyPool delivers messages to several backends with a bounded pool of
+// workers, so that a slow backend does not hold up the others. Deliveries
+// are queued, the most severe first, and a backend receives one message at
+// a time, in order within a severity. When the queue is full, Notify blocks
+// until a worker frees a slot. The backends gated by severity are skipped
+// for the messages below their threshold.
+type notifyPool struct {
+	backends  []Notifier
+	workers   int
+	queueSize int
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []delivery
+	// busy marks the backends a worker is delivering to, by index.
+	busy     []bool
+	inFlight int
+	stopped  bool
+}
+
+// delivery is a message waiting to be delivered to a backend.
+type delivery struct {
+	backend int
+	ctx     context.Context
+	msg     Message
+}
+
+// newNotifyPool delivers to `notifier` with `workers` workers and up to
+// `queueSize` pending deliveries. If `notifier` is a multiNotifier, each of
+// its notifiers is a backend of its own.
+func newNotifyPool(notifier Notifier, workers, queueSize int) *notifyPool {
+	backends := []Notifier{notifier}
+	if multi, ok := notifier.(multiNotifier); ok {
+		backends = multi
+	}
+	p := &notifyPool{
+		backends:  backends,
+		workers:   workers,
+		queueSize: queueSize,
+		busy:      make([]bool, len(backends)),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Notify queues `msg` for delivery to every backend, waiting for a free
+// slot while the queue is full. It fails if `ctx` is done, or the pool
+// stopped, before every delivery is queued.
+func (p *notifyPool) Notify(ctx context.Context, msg Message) error {
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.cond.Broadcast()
+	})
+	defer stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	warned := false
+	for i, backend := range p.backends {
+		if gate, ok := backend.(severityGate); ok && !gate.accepts(msg) {
+			continue
+		}
+		if len(p.queue) >= p.queueSize && !warned {
+			slog.Warn("notification workers busy, waiting for a free slot", "title", msg.Title, "queued", len(p.queue))
+			warned = true
+		}
+		for len(p.queue) >= p.queueSize && !p.stopped && ctx.Err() == nil {
+			p.cond.Wait()
+		}
+		if p.stopped {
+			return fmt.Errorf("queueing alert %q: notification workers stopped", msg.Title)
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("queueing alert %q: %w", msg.Title, err)
+		}
+		p.queue = append(p.queue, delivery{backend: i, ctx: ctx, msg: msg})
+		p.cond.Broadcast()
+	}
+	return nil
+}
+
+// Run delivers the queued messages until `ctx` is cancelled.
+func (p *notifyPool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+
+	<-ctx.Done()
+	p.mu.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	wg.Wait()
+}
+
+// work delivers the queued messages until the pool is stopped.
+func (p *notifyPool) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		i := p.nextLocked()
+		for i < 0 && !p.stopped {
+			p.cond.Wait()
+			i = p.nextLocked()
+		}
+		if p.stopped {
+			return
+		}
+
+		d := p.queue[i]
+		p.queue = append(p.queue[:i], p.queue[i+1:]...)
+		p.busy[d.backend] = true
+		p.inFlight++
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		if err := p.backends[d.backend].Notify(d.ctx, d.msg); err != nil {
+			slog.Error("failed to deliver alert", "title", d.msg.Title, "severity", d.msg.Severity.String(), "err", err)
+		}
+
+		p.mu.Lock()
+		p.busy[d.backend] = false
+		p.inFlight--
+		p.cond.Broadcast()
+	}
+}
+
+// nextLocked returns the index in the queue of the next delivery: the
+// oldest of the most severe ones to a backend no worker is delivering to,
+// or -1 if there is none.
+func (p *notifyPool) nextLocked() int {
+	next := -1
+	for i, d := range p.queue {
+		if p.busy[d.backend] {
+			continue
+		}
+		if next < 0 || d.msg.Severity > p.queue[next].msg.Severity {
+			next = i
+		}
+	}
+	return next
+}
+
+func (p *notifyPool) pending() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight > 0 || len(p.queue) > 0
+}
+
+// Flush waits until every queued message has been delivered or `ctx` is
+// done.
+func (p *notifyPool) Flush(ctx context.Context) error {
+	for p.pending() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("flushing notification workers: %v", ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
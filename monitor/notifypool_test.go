@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// runPool runs `p` until the test ends.
+func runPool(t *testing.T, p *notifyPool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestNotifyPoolSlowBackend(t *testing.T) {
+	slow := &blockingNotifier{blocked: make(chan struct{}), released: make(chan struct{})}
+	fast := &recordingNotifier{}
+	p := newNotifyPool(multiNotifier{slow, fast}, 2, 10)
+	runPool(t, p)
+	defer close(slow.released)
+
+	for _, title := range []string{"first", "second"} {
+		if err := p.Notify(context.Background(), Message{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-slow.blocked
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fast.titles()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, want := fast.titles(), []string{"first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %q to the fast backend while the slow one is stuck, want %q", got, want)
+	}
+}
+
+func TestNotifyPoolBackpressure(t *testing.T) {
+	backend := &blockingNotifier{blocked: make(chan struct{}), released: make(chan struct{})}
+	p := newNotifyPool(backend, 1, 2)
+	runPool(t, p)
+
+	notify := func(ctx context.Context, title string, severity Severity) error {
+		return p.Notify(ctx, Message{Title: title, Severity: severity})
+	}
+	if err := notify(context.Background(), "in flight", SeverityInfo); err != nil {
+		t.Fatal(err)
+	}
+	<-backend.blocked
+	// Fills the queue.
+	if err := notify(context.Background(), "info", SeverityInfo); err != nil {
+		t.Fatal(err)
+	}
+	if err := notify(context.Background(), "critical", SeverityCritical); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queueing waits for a free slot, until its context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := notify(ctx, "timed out", SeverityInfo); err == nil {
+		t.Error("Notify() = nil with the queue full, want an error once its context is done")
+	}
+	queued := make(chan error, 1)
+	go func() { queued <- notify(context.Background(), "waiting", SeverityInfo) }()
+	select {
+	case err := <-queued:
+		t.Fatalf("Notify() = %v with the queue full, want it waiting", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(backend.released)
+	if err := <-queued; err != nil {
+		t.Errorf("Notify() = %v once a slot is free, want nil", err)
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := p.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	// The critical message overtakes the one queued before it.
+	if got, want := backend.titles(), []string{"in flight", "critical", "info", "waiting"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %q, want %q", got, want)
+	}
+}
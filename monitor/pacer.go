@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"context"
+	"time"
+)
+
+// pacer spaces out the processing of log entries to at most `ratePerSec`
+// per second, so that a burst, e.g. a backlog redelivered after a reconnect,
+// is consumed at a steady pace rather than all at once. Entries are only
+// delayed, never dropped. A nil *pacer does not pace.
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// newPacer returns a pacer allowing `ratePerSec` entries per second, or nil
+// if it is zero.
+func newPacer(ratePerSec int) *pacer {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	return &pacer{interval: time.Second / time.Duration(ratePerSec)}
+}
+
+// Wait blocks until the next entry may be processed, or `ctx` is done.
+func (p *pacer) Wait(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	wait := p.next.Sub(now)
+	p.next = p.next.Add(p.interval)
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int
+		entries int
+		// min and max bound the time taken by the waits.
+		min, max time.Duration
+	}{
+		{"unlimited", 0, 100, 0, 50 * time.Millisecond},
+		{"first entry right away", 10, 1, 0, 50 * time.Millisecond},
+		{"paced", 100, 11, 100 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPacer(tt.rate)
+			start := time.Now()
+			for i := 0; i < tt.entries; i++ {
+				p.Wait(context.Background())
+			}
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("%d entries took %s, want between %s and %s", tt.entries, elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestPacerCancelled(t *testing.T) {
+	p := newPacer(1)
+	p.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	p.Wait(ctx)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() returned after %s once cancelled, want right away", elapsed)
+	}
+}
+
+func TestProcessCommitLogsPaced(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ProcessRatePerSec = 100
+	var entries []LogEntry
+	for height := int64(1); height <= 10; height++ {
+		entries = append(entries, commitEntry("pod-0", height, "aa"), commitEntry("pod-1", height, "aa"))
+	}
+	roots := newRootsAPI()
+
+	start := time.Now()
+	processEntries(t, cfg, tmDeps{Roots: roots}, entries...)
+	// 20 entries at 100 per second, the first one right away.
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("processed %d entries in %s, want at least 190ms", len(entries), elapsed)
+	}
+	// Paced, not dropped.
+	if got := confirmedHeight(t, roots); got != 10 {
+		t.Errorf("confirmed height = %d, want 10", got)
+	}
+}
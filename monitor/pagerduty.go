@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier pages through the PagerDuty Events API v2. Only critical
+// messages trigger an incident; resolved messages resolve it.
+type pagerDutyNotifier struct {
+	RoutingKey string
+	EventsURL  string
+	Client     *http.Client
+	// MinSeverity is the least severe alerts that page, critical by
+	// default.
+	MinSeverity Severity
+}
+
+func newPagerDutyNotifier(routingKey string, client *http.Client) *pagerDutyNotifier {
+	return &pagerDutyNotifier{
+		RoutingKey:  routingKey,
+		EventsURL:   pagerDutyEventsURL,
+		Client:      client,
+		MinSeverity: SeverityCritical,
+	}
+}
+
+// pagerDutyDedupKey returns the key identifying the incident `msg` is about.
+func pagerDutyDedupKey(msg Message) string {
+	if msg.IncidentKey != "" {
+		return msg.IncidentKey
+	}
+	sum := sha256.Sum256([]byte(msg.Title + "\x00" + msg.Body))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (p *pagerDutyNotifier) Payload(msg Message) ([]byte, error) {
+	event := map[string]interface{}{
+		"routing_key": p.RoutingKey,
+		"dedup_key":   pagerDutyDedupKey(msg),
+	}
+
+	if msg.Resolved {
+		event["event_action"] = "resolve"
+	} else {
+		summary := msg.Title
+		if summary == "" {
+			summary = msg.Body
+		}
+		if len(summary) > 1024 {
+			summary = summary[:1024]
+		}
+
+		event["event_action"] = "trigger"
+		event["payload"] = map[string]interface{}{
+			"summary":  summary,
+			"source":   "check-apphash",
+			"severity": "critical",
+			"custom_details": map[string]interface{}{
+				"body": msg.Body,
+			},
+		}
+	}
+
+	payloadBytes, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling pagerduty event: %v", err)
+	}
+	return payloadBytes, nil
+}
+
+func (p *pagerDutyNotifier) Notify(ctx context.Context, msg Message) error {
+	// Only the events of MinSeverity and above page, and only the incidents
+	// we may have opened are resolved.
+	if msg.Resolved {
+		if msg.IncidentKey == "" {
+			return nil
+		}
+	} else if msg.Severity < p.MinSeverity {
+		return nil
+	}
+
+	payloadBytes, err := p.Payload(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.EventsURL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("building pagerduty request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to pagerduty: %v", err)
+	}
+	defer drainAndClose(resp)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("pagerduty events API returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultPayloadField is the field holding the log line in structured payloads.
+const defaultPayloadField = "message"
+
+// entryPayload returns the log line carried by `entry`. Structured (JSON or
+// proto) payloads are searched for `field`, a dot-separated path such as
+// "message" or "fields.msg". It returns an empty string if there is no such
+// string field.
+func entryPayload(entry *loggingpb.LogEntry, field string) string {
+	switch payload := entry.GetPayload().(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		return payload.TextPayload
+	case *loggingpb.LogEntry_JsonPayload:
+		return structField(payload.JsonPayload, field)
+	case *loggingpb.LogEntry_ProtoPayload:
+		msg, err := payload.ProtoPayload.UnmarshalNew()
+		if err != nil {
+			return ""
+		}
+		b, err := protojson.Marshal(msg)
+		if err != nil {
+			return ""
+		}
+		var s structpb.Struct
+		if err := protojson.Unmarshal(b, &s); err != nil {
+			return ""
+		}
+		return structField(&s, field)
+	default:
+		return ""
+	}
+}
+
+func structField(s *structpb.Struct, field string) string {
+	keys := strings.Split(field, ".")
+	for _, key := range keys[:len(keys)-1] {
+		s = s.GetFields()[key].GetStructValue()
+	}
+	return s.GetFields()[keys[len(keys)-1]].GetStringValue()
+}
+
+// entryTimestamp returns when `entry` was logged, or the zero time if it
+// carries no timestamp, which the workers take as unknown. A nil timestamp
+// would otherwise convert to the Unix epoch.
+func entryTimestamp(entry *loggingpb.LogEntry) time.Time {
+	if entry.GetTimestamp() == nil {
+		return time.Time{}
+	}
+	return entry.GetTimestamp().AsTime()
+}
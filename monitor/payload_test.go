@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestEntryTimestamp(t *testing.T) {
+	logged := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		entry *loggingpb.LogEntry
+		want  time.Time
+	}{
+		{"set", &loggingpb.LogEntry{Timestamp: timestamppb.New(logged)}, logged},
+		{"missing", &loggingpb.LogEntry{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := entryTimestamp(tt.entry)
+			if !got.Equal(tt.want) {
+				t.Errorf("entryTimestamp() = %v, want %v", got, tt.want)
+			}
+			if tt.want.IsZero() && !got.IsZero() {
+				t.Errorf("entryTimestamp() = %v, want the zero time", got)
+			}
+		})
+	}
+}
+
+func TestEntryPayload(t *testing.T) {
+	jsonPayload, err := structpb.NewStruct(map[string]interface{}{
+		"message": "top-level line",
+		"fields":  map[string]interface{}{"msg": "nested line"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		entry *loggingpb.LogEntry
+		field string
+		want  string
+	}{
+		{"text", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "plain line"}}, defaultPayloadField, "plain line"},
+		{"json", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: jsonPayload}}, defaultPayloadField, "top-level line"},
+		{"json nested field", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: jsonPayload}}, "fields.msg", "nested line"},
+		{"json missing field", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: jsonPayload}}, "fields.other", ""},
+		{"no payload", &loggingpb.LogEntry{}, defaultPayloadField, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entryPayload(tt.entry, tt.field); got != tt.want {
+				t.Errorf("entryPayload(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
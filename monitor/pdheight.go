@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// defaultPDErrorHeightPattern matches the heights pd mentions in its errors,
+// e.g. `height=1234` or `"height":1234`.
+const defaultPDErrorHeightPattern = `(?i)\bheight\W{1,3}(?P<height>\d+)`
+
+// compilePDErrorHeightPattern compiles a pattern extracting the height from
+// a pd error, checking that it has a `height` named group.
+func compilePDErrorHeightPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pd error height pattern: %v", err)
+	}
+	if re.SubexpIndex("height") < 0 {
+		return nil, fmt.Errorf("pd error height pattern is missing the named group height")
+	}
+	return re, nil
+}
+
+// pdErrorHeight extracts the height a pd error occurred at, using the first
+// match of `re` in `payload`.
+func pdErrorHeight(re *regexp.Regexp, payload string) (int64, bool) {
+	match := re.FindStringSubmatch(payload)
+	if len(match) == 0 {
+		return 0, false
+	}
+	height, err := strconv.ParseInt(match[re.SubexpIndex("height")], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return height, true
+}
+
+// mismatchHeights remembers the heights a root mismatch was alerted at, so
+// that the pd errors occurring at these heights can be correlated with it.
+// It is shared by the tm and pd workers. A nil *mismatchHeights records
+// nothing.
+type mismatchHeights struct {
+	mu      sync.RWMutex
+	heights map[int64]bool
+}
+
+func newMismatchHeights() *mismatchHeights {
+	return &mismatchHeights{heights: make(map[int64]bool)}
+}
+
+// Record marks the heights of `divergences` as mismatched.
+func (m *mismatchHeights) Record(divergences []divergence) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range divergences {
+		m.heights[d.Height] = true
+	}
+}
+
+// Mismatched reports whether a mismatch was recorded at `height`.
+func (m *mismatchHeights) Mismatched(height int64) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.heights[height]
+}
+
+// Prune forgets the heights at or below `floor`.
+func (m *mismatchHeights) Prune(floor int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for height := range m.heights {
+		if height <= floor {
+			delete(m.heights, height)
+		}
+	}
+}
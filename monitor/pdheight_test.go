@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPDErrorHeight(t *testing.T) {
+	re := regexp.MustCompile(defaultPDErrorHeightPattern)
+	tests := []struct {
+		payload    string
+		want       int64
+		wantHeight bool
+	}{
+		{"failed to apply block at height 42", 42, true},
+		{"error executing block: height=1234567", 1234567, true},
+		{`{"height":77,"err":"oops"}`, 77, true},
+		{"Height: 9000000000", 9000000000, true},
+		{"invalid block", 0, false},
+		{"heights diverge", 0, false},
+	}
+	for _, tt := range tests {
+		height, ok := pdErrorHeight(re, tt.payload)
+		if height != tt.want || ok != tt.wantHeight {
+			t.Errorf("pdErrorHeight(%q) = %d, %v, want %d, %v", tt.payload, height, ok, tt.want, tt.wantHeight)
+		}
+	}
+}
+
+func TestCompilePDErrorHeightPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{defaultPDErrorHeightPattern, false},
+		{`block (?P<height>\d+)`, false},
+		{`block (\d+)`, true},
+		{`block (?P<height>\d+`, true},
+	}
+	for _, tt := range tests {
+		if _, err := compilePDErrorHeightPattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("compilePDErrorHeightPattern(%q) = %v, want error: %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPDWorkerCorrelatesMismatches(t *testing.T) {
+	mismatches := newMismatchHeights()
+	mismatches.Record([]divergence{{Height: 42}})
+	tests := []struct {
+		name         string
+		payload      string
+		wantTitle    string
+		wantSeverity Severity
+	}{
+		{"at a mismatch height", "failed to apply block at height 42", "pd error at mismatch height 42", SeverityCritical},
+		{"at another height", "failed to apply block at height 43", "pd error at height 43", SeverityError},
+		{"without a height", "invalid block", "pd error", SeverityError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := processPDErrors(t, testConfig(t), pdDeps{Mismatches: mismatches}, NewLogEntry("pod-0", tt.payload, time.Time{}))
+			if got := notifier.titles(); !slices.Equal(got, []string{tt.wantTitle}) {
+				t.Fatalf("notified %q, want %q", got, tt.wantTitle)
+			}
+			msg := notifier.messages[0]
+			if msg.Severity != tt.wantSeverity {
+				t.Errorf("severity = %s, want %s", msg.Severity, tt.wantSeverity)
+			}
+			if correlated := strings.Contains(msg.Body, "a root mismatch was recorded at block **42**"); correlated != (tt.wantSeverity == SeverityCritical) {
+				t.Errorf("alert %q cross-references the mismatch: %v, want %v", msg.Body, correlated, !correlated)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// severityDrop is the severity of the rules whose matches are not alerted.
+const severityDrop = "drop"
+
+// SeverityRule sets the severity of the pd errors whose payload matches
+// Pattern, or drops them.
+type SeverityRule struct {
+	Pattern  *regexp.Regexp
+	Severity Severity
+	Drop     bool
+}
+
+// SeverityRules classify the pd errors by payload. The first matching rule
+// applies.
+type SeverityRules []SeverityRule
+
+// loadSeverityRules reads the rules from the JSON file at `path`, a list of
+// {"pattern": "...", "severity": "..."} objects where the severity is one of
+// info, warning, error, critical or drop.
+func loadSeverityRules(path string) (SeverityRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading severity rules: %v", err)
+	}
+
+	var raw []struct {
+		Pattern  string `json:"pattern"`
+		Severity string `json:"severity"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decoding severity rules: %v", err)
+	}
+
+	rules := make(SeverityRules, 0, len(raw))
+	for i, r := range raw {
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("rule %d: pattern is empty", i+1)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid pattern: %v", i+1, err)
+		}
+		rule := SeverityRule{Pattern: re}
+		if r.Severity == severityDrop {
+			rule.Drop = true
+		} else if rule.Severity, err = parseSeverity(r.Severity); err != nil {
+			return nil, fmt.Errorf("rule %d: %v, or %s", i+1, err, severityDrop)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Classify returns the severity of a pd error with `payload`, `severity` if
+// no rule matches, and whether it should be alerted at all.
+func (r SeverityRules) Classify(payload string, severity Severity) (Severity, bool) {
+	for _, rule := range r {
+		if rule.Pattern.MatchString(payload) {
+			return rule.Severity, !rule.Drop
+		}
+	}
+	return severity, true
+}
+
+// parseSeverity parses the name of a severity, as returned by
+// Severity.String.
+func parseSeverity(name string) (Severity, error) {
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical} {
+		if name == severity.String() {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, expected one of info, warning, error or critical", name)
+}
+
+// logSeverity maps the severity of a GCP log entry to the severity of its
+// alert. Entries without a severity, e.g. replayed ones, are errors, as the
+// pd worker only receives errors by default.
+func logSeverity(severity ltype.LogSeverity) Severity {
+	switch {
+	case severity == ltype.LogSeverity_DEFAULT:
+		return SeverityError
+	case severity >= ltype.LogSeverity_CRITICAL:
+		return SeverityCritical
+	case severity >= ltype.LogSeverity_ERROR:
+		return SeverityError
+	case severity >= ltype.LogSeverity_WARNING:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// writeSeverityRules writes `rules` to a file and returns its path.
+func writeSeverityRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSeverityRulesClassify(t *testing.T) {
+	rules, err := loadSeverityRules(writeSeverityRules(t, `[
+		{"pattern": "(?i)consensus failure", "severity": "critical"},
+		{"pattern": "peer disconnected", "severity": "drop"},
+		{"pattern": "slow block", "severity": "info"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		payload     string
+		severity    Severity
+		want        Severity
+		wantAlerted bool
+	}{
+		{"CONSENSUS FAILURE at height 10", SeverityWarning, SeverityCritical, true},
+		{"peer disconnected: timeout", SeverityError, 0, false},
+		{"slow block: 3s", SeverityError, SeverityInfo, true},
+		{"unmatched error", SeverityError, SeverityError, true},
+		// The first matching rule applies.
+		{"consensus failure after peer disconnected", SeverityError, SeverityCritical, true},
+	}
+	for _, tt := range tests {
+		got, alerted := rules.Classify(tt.payload, tt.severity)
+		if alerted != tt.wantAlerted || (alerted && got != tt.want) {
+			t.Errorf("Classify(%q) = %s, %v, want %s, %v", tt.payload, got, alerted, tt.want, tt.wantAlerted)
+		}
+	}
+}
+
+func TestLoadSeverityRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		// err is a substring of the error expected, if any.
+		err string
+	}{
+		{"valid", `[{"pattern": "x", "severity": "warning"}]`, ""},
+		{"empty list", `[]`, ""},
+		{"not JSON", `pattern: x`, "decoding severity rules"},
+		{"empty pattern", `[{"pattern": "", "severity": "error"}]`, "rule 1: pattern is empty"},
+		{"invalid pattern", `[{"pattern": "x", "severity": "error"}, {"pattern": "(", "severity": "error"}]`, "rule 2: invalid pattern"},
+		{"unknown severity", `[{"pattern": "x", "severity": "urgent"}]`, "rule 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadSeverityRules(writeSeverityRules(t, tt.rules))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("loadSeverityRules() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("loadSeverityRules() = %v, want an error mentioning %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLogSeverity(t *testing.T) {
+	tests := []struct {
+		severity ltype.LogSeverity
+		want     Severity
+	}{
+		{ltype.LogSeverity_DEFAULT, SeverityError},
+		{ltype.LogSeverity_INFO, SeverityInfo},
+		{ltype.LogSeverity_WARNING, SeverityWarning},
+		{ltype.LogSeverity_ERROR, SeverityError},
+		{ltype.LogSeverity_CRITICAL, SeverityCritical},
+		{ltype.LogSeverity_EMERGENCY, SeverityCritical},
+	}
+	for _, tt := range tests {
+		if got := logSeverity(tt.severity); got != tt.want {
+			t.Errorf("logSeverity(%s) = %s, want %s", tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestPDWorkerSeverityRules(t *testing.T) {
+	cfg := testConfig(t)
+	var err error
+	cfg.PDSeverityRules, err = loadSeverityRules(writeSeverityRules(t, `[
+		{"pattern": "consensus failure", "severity": "critical"},
+		{"pattern": "peer disconnected", "severity": "drop"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := processPDErrors(t, cfg, pdDeps{},
+		NewLogEntry("pod-0", "peer disconnected", time.Time{}),
+		NewLogEntry("pod-0", "consensus failure", time.Time{}),
+	)
+	if len(notifier.messages) != 1 {
+		t.Fatalf("notified %q, want the consensus failure only", notifier.titles())
+	}
+	if got := notifier.messages[0].Severity; got != SeverityCritical {
+		t.Errorf("consensus failure alerted at %s, want critical", got)
+	}
+}
@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PodFilter selects, by pod name glob, the pods whose roots are compared to
+// detect mismatches, e.g. to leave out archive nodes. The other pods are
+// still logged and counted in the metrics.
+type PodFilter struct {
+	// Include, if not empty, lists the only pods compared.
+	Include []string
+	Exclude []string
+}
+
+// parsePodGlobs parses a comma-separated list of path.Match patterns.
+func parsePodGlobs(v string) ([]string, error) {
+	var globs []string
+	for _, glob := range strings.Split(v, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", glob, err)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// Compared reports whether the roots of `podName` take part in the mismatch
+// comparison.
+func (f PodFilter) Compared(podName string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, podName) {
+		return false
+	}
+	return !matchAny(f.Exclude, podName)
+}
+
+func matchAny(globs []string, podName string) bool {
+	for _, glob := range globs {
+		// The patterns were validated by parsePodGlobs.
+		if ok, _ := path.Match(glob, podName); ok {
+			return true
+		}
+	}
+	return false
+}
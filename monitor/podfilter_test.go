@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPodFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter PodFilter
+		pod    string
+		want   bool
+	}{
+		{"no filter", PodFilter{}, "penumbra-archive-0", true},
+		{"excluded", PodFilter{Exclude: []string{"*-archive-*"}}, "penumbra-archive-0", false},
+		{"not excluded", PodFilter{Exclude: []string{"*-archive-*"}}, "penumbra-fn-0", true},
+		{"included", PodFilter{Include: []string{"penumbra-fn-*"}}, "penumbra-fn-0", true},
+		{"not included", PodFilter{Include: []string{"penumbra-fn-*"}}, "penumbra-val-0", false},
+		{"included then excluded", PodFilter{Include: []string{"penumbra-*"}, Exclude: []string{"*-0"}}, "penumbra-fn-0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Compared(tt.pod); got != tt.want {
+				t.Errorf("Compared(%s) = %v, want %v", tt.pod, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePodGlobs(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"*-archive-*", []string{"*-archive-*"}, false},
+		{" a-* , ,b-? ", []string{"a-*", "b-?"}, false},
+		{"pod-[", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePodGlobs(tt.v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePodGlobs(%q) = %v, want error: %v", tt.v, err, tt.wantErr)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parsePodGlobs(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestProcessCommitLogsExcludedPod(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ComparedPods = PodFilter{Exclude: []string{"*-archive-*"}}
+	roots := newRootsAPI()
+	notifier := processEntries(t, cfg, tmDeps{Roots: roots},
+		commitEntry("penumbra-fn-0", 10, "aa"),
+		commitEntry("penumbra-archive-0", 10, "bb"),
+		commitEntry("penumbra-fn-1", 10, "aa"),
+	)
+	if got := notifier.titles(); len(got) != 0 {
+		t.Errorf("notified %q for the divergent root of an excluded pod, want nothing", got)
+	}
+	if got := confirmedHeight(t, roots); got != 10 {
+		t.Errorf("confirmed height = %d, want 10", got)
+	}
+}
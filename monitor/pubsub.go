@@ -0,0 +1,195 @@
+package monitor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// pubsubMaxMessages is the number of messages pulled at once.
+	pubsubMaxMessages = 100
+	// pubsubNackTimeout bounds the request returning the undelivered
+	// messages on shutdown, made once the stream context is cancelled.
+	pubsubNackTimeout = 5 * time.Second
+)
+
+// pubSubLogSource pulls the log entries a logging sink publishes to a Pub/Sub
+// topic, from one of its subscriptions. Every message is a LogEntry in its
+// JSON form. A message is only acknowledged once its entry was handed over
+// on the channel, which is not buffered in front of the worker, so the
+// messages in flight when the process dies are redelivered, and the ones
+// pulled but not taken by the worker on shutdown are returned to the
+// subscription right away.
+type pubSubLogSource struct {
+	// Subscription is the full name of the subscription, i.e.
+	// projects/<project>/subscriptions/<name>.
+	Subscription string
+	// Credentials authenticate the pulls, nil for the Application Default
+	// Credentials.
+	Credentials option.ClientOption
+	// PayloadField is the field holding the log line in structured payloads,
+	// defaulting to "message".
+	PayloadField string
+	Config       StreamConfig
+	// tracer records a span for every pull, if set.
+	tracer trace.Tracer
+}
+
+func (s *pubSubLogSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	defer close(out)
+
+	field := s.PayloadField
+	if field == "" {
+		field = defaultPayloadField
+	}
+	tracer := tracerOrNoop(s.tracer)
+
+	service, err := newPubSubService(ctx, s.Credentials)
+	if err != nil {
+		return fmt.Errorf("creating Pub/Sub client: %w", err)
+	}
+	subscriptions := service.Projects.Subscriptions
+	if !s.Config.waitStartup(ctx, s.Subscription) {
+		return nil
+	}
+	slog.Info("pulling log entries", "subscription", s.Subscription)
+
+	attempt := 0
+	for ctx.Err() == nil {
+		_, span := tracer.Start(ctx, "pubsub_pull", trace.WithAttributes(attribute.String("subscription", s.Subscription)))
+		resp, err := subscriptions.Pull(s.Subscription, &pubsub.PullRequest{MaxMessages: pubsubMaxMessages}).Context(ctx).Do()
+		setSpanError(span, err)
+		span.End()
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			delay := s.Config.backoff(attempt)
+			attempt++
+			slog.Warn("pulling log entries failed, retrying", "subscription", s.Subscription, "err", err, "delay", delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			continue
+		}
+		attempt = 0
+
+		if err := s.deliver(ctx, subscriptions, resp.ReceivedMessages, field, out); err != nil {
+			slog.Warn("failed to settle Pub/Sub messages, they will be redelivered", "subscription", s.Subscription, "err", err)
+		}
+	}
+
+	slog.Info("terminating routine", "subscription", s.Subscription)
+	return nil
+}
+
+// deliver pushes the entries of `received` to `out`, then acknowledges the
+// messages delivered, along with those that carry no usable entry, which
+// would otherwise be redelivered forever. If `ctx` is cancelled first, the
+// rest are nacked.
+func (s *pubSubLogSource) deliver(ctx context.Context, subscriptions *pubsub.ProjectsSubscriptionsService, received []*pubsub.ReceivedMessage, field string, out chan<- LogEntry) error {
+	var acked, nacked []string
+	for i, msg := range received {
+		if ctx.Err() != nil {
+			for _, rest := range received[i:] {
+				nacked = append(nacked, rest.AckId)
+			}
+			break
+		}
+
+		entry, err := decodePubSubEntry(msg.Message, field)
+		if err != nil {
+			slog.Warn("dropping undecodable Pub/Sub message", "subscription", s.Subscription, "message_id", msg.Message.MessageId, "err", err)
+			acked = append(acked, msg.AckId)
+			continue
+		}
+		if entry.payload == "" {
+			slog.Debug("skipping entry without payload", "field", field, "message_id", msg.Message.MessageId)
+			acked = append(acked, msg.AckId)
+			continue
+		}
+
+		select {
+		case out <- entry:
+			acked = append(acked, msg.AckId)
+		case <-ctx.Done():
+			nacked = append(nacked, msg.AckId)
+		}
+	}
+
+	// The stream context may be cancelled, the messages are settled
+	// regardless.
+	settleCtx, cancel := context.WithTimeout(context.Background(), pubsubNackTimeout)
+	defer cancel()
+
+	var errs []string
+	if len(acked) > 0 {
+		if _, err := subscriptions.Acknowledge(s.Subscription, &pubsub.AcknowledgeRequest{AckIds: acked}).Context(settleCtx).Do(); err != nil {
+			errs = append(errs, fmt.Sprintf("acknowledging %d messages: %v", len(acked), err))
+		}
+	}
+	if len(nacked) > 0 {
+		// A zero deadline makes the messages available again immediately.
+		req := &pubsub.ModifyAckDeadlineRequest{AckIds: nacked, AckDeadlineSeconds: 0, ForceSendFields: []string{"AckDeadlineSeconds"}}
+		if _, err := subscriptions.ModifyAckDeadline(s.Subscription, req).Context(settleCtx).Do(); err != nil {
+			errs = append(errs, fmt.Sprintf("nacking %d messages: %v", len(nacked), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// decodePubSubEntry decodes the LogEntry published by a logging sink.
+func decodePubSubEntry(msg *pubsub.PubsubMessage, field string) (LogEntry, error) {
+	data, err := base64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("decoding message data: %v", err)
+	}
+	var entry loggingpb.LogEntry
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &entry); err != nil {
+		return LogEntry{}, fmt.Errorf("decoding log entry: %v", err)
+	}
+	return LogEntry{
+		metadata:  entry.GetResource().GetLabels(),
+		payload:   entryPayload(&entry, field),
+		timestamp: entryTimestamp(&entry),
+		severity:  entry.GetSeverity(),
+	}, nil
+}
+
+// newPubSubService creates a Pub/Sub client authenticated with
+// `credentials`, or the Application Default Credentials if nil.
+func newPubSubService(ctx context.Context, credentials option.ClientOption) (*pubsub.Service, error) {
+	opts := []option.ClientOption{option.WithScopes(pubsub.PubsubScope)}
+	if credentials != nil {
+		opts = append(opts, credentials)
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errCredentials, err)
+	}
+	return service, nil
+}
+
+// subscriptionName expands a short subscription name into a full one in
+// `projectID`. Full names are returned as is.
+func subscriptionName(name, projectID string) string {
+	if name == "" || strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return fmt.Sprintf("projects/%s/subscriptions/%s", projectID, name)
+}
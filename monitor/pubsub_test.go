@@ -0,0 +1,255 @@
+package monitor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// pubSubServer stubs the Pub/Sub API of a subscription holding `messages`,
+// returned by the first pull, recording the messages acknowledged and
+// nacked.
+type pubSubServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	pending []*pubsub.ReceivedMessage
+	acked   []string
+	nacked  []string
+}
+
+func newPubSubServer(t *testing.T, messages []*pubsub.ReceivedMessage) *pubSubServer {
+	t.Helper()
+	s := &pubSubServer{pending: messages}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":pull"):
+			resp := &pubsub.PullResponse{ReceivedMessages: s.pending}
+			s.pending = nil
+			if len(resp.ReceivedMessages) == 0 {
+				// Pulls wait for messages.
+				time.Sleep(10 * time.Millisecond)
+			}
+			json.NewEncoder(w).Encode(resp)
+		case strings.HasSuffix(r.URL.Path, ":acknowledge"):
+			var req pubsub.AcknowledgeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			s.acked = append(s.acked, req.AckIds...)
+			fmt.Fprint(w, "{}")
+		case strings.HasSuffix(r.URL.Path, ":modifyAckDeadline"):
+			var req pubsub.ModifyAckDeadlineRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			s.nacked = append(s.nacked, req.AckIds...)
+			fmt.Fprint(w, "{}")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// settled returns the ack IDs of the messages acknowledged and nacked so far.
+func (s *pubSubServer) settled() (acked, nacked []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.acked), slices.Clone(s.nacked)
+}
+
+// client returns credentials sending the requests of a Pub/Sub client to the
+// server.
+func (s *pubSubServer) client() option.ClientOption {
+	target, _ := url.Parse(s.URL)
+	return option.WithHTTPClient(&http.Client{Transport: redirectTransport{target}})
+}
+
+// redirectTransport sends every request to `target`.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// pubSubMessage publishes `entry` as a logging sink would, with `ackID`.
+func pubSubMessage(t *testing.T, ackID string, entry LogEntry) *pubsub.ReceivedMessage {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"resource":    map[string]interface{}{"labels": entry.metadata},
+		"textPayload": entry.payload,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pubsub.ReceivedMessage{AckId: ackID, Message: &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(data), MessageId: ackID}}
+}
+
+// blockingNotifier records the titles of the messages it delivers, blocking
+// the first one until released.
+type blockingNotifier struct {
+	recordingNotifier
+	blocked  chan struct{}
+	released chan struct{}
+	once     sync.Once
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, msg Message) error {
+	n.once.Do(func() {
+		close(n.blocked)
+		<-n.released
+	})
+	return n.recordingNotifier.Notify(ctx, msg)
+}
+
+func TestPubSubNacksEntriesNotTaken(t *testing.T) {
+	server := newPubSubServer(t, []*pubsub.ReceivedMessage{
+		pubSubMessage(t, "1", commitEntry("pod-0", 10, "aa")),
+		pubSubMessage(t, "2", commitEntry("pod-1", 10, "bb")),
+		pubSubMessage(t, "3", commitEntry("pod-0", 11, "cc")),
+		pubSubMessage(t, "4", commitEntry("pod-1", 11, "cc")),
+	})
+	source := &pubSubLogSource{Subscription: "projects/project/subscriptions/logs", Credentials: server.client()}
+	notifier := &blockingNotifier{blocked: make(chan struct{}), released: make(chan struct{})}
+	cfg := testConfig(t)
+	// Room for every message pulled, which must not be taken off the
+	// subscription regardless.
+	cfg.LogBufferSize = 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tmWorker(ctx, context.Background(), cfg, NetworkConfig{Name: t.Name()}, source, notifier, tmDeps{})
+	}()
+	// The worker is busy alerting on the mismatch at height 10 when the
+	// monitor stops.
+	select {
+	case <-notifier.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("mismatch not alerted")
+	}
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	acked, nacked := server.settled()
+	for len(acked)+len(nacked) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		acked, nacked = server.settled()
+	}
+	close(notifier.released)
+	<-done
+
+	if want := []string{"1", "2"}; !slices.Equal(acked, want) {
+		t.Errorf("acknowledged %q, want %q", acked, want)
+	}
+	if want := []string{"3", "4"}; !slices.Equal(nacked, want) {
+		t.Errorf("nacked %q, want %q", nacked, want)
+	}
+}
+
+func TestPubSubLogSource(t *testing.T) {
+	undecodable := &pubsub.ReceivedMessage{AckId: "2", Message: &pubsub.PubsubMessage{Data: "not base64", MessageId: "2"}}
+	server := newPubSubServer(t, []*pubsub.ReceivedMessage{
+		pubSubMessage(t, "1", LogEntry{metadata: map[string]string{"pod_name": "pod-0"}, payload: "first"}),
+		undecodable,
+		pubSubMessage(t, "3", LogEntry{metadata: map[string]string{"pod_name": "pod-0"}}),
+		pubSubMessage(t, "4", LogEntry{metadata: map[string]string{"pod_name": "pod-1"}, payload: "second"}),
+	})
+	source := &pubSubLogSource{Subscription: "projects/project/subscriptions/logs", Credentials: server.client()}
+	out := make(chan LogEntry)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- source.Stream(ctx, out) }()
+
+	// The undecodable message and the one without a payload are skipped.
+	for _, want := range []LogEntry{
+		{metadata: map[string]string{"pod_name": "pod-0"}, payload: "first"},
+		{metadata: map[string]string{"pod_name": "pod-1"}, payload: "second"},
+	} {
+		select {
+		case got := <-out:
+			if got.payload != want.payload || got.metadata["pod_name"] != want.metadata["pod_name"] {
+				t.Errorf("streamed %+v, want %+v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q not streamed", want.payload)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	acked, nacked := server.settled()
+	for len(acked) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		acked, nacked = server.settled()
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Stream() = %v, want nil once cancelled", err)
+	}
+
+	if want := []string{"1", "2", "3", "4"}; !slices.Equal(acked, want) {
+		t.Errorf("acknowledged %q, want %q", acked, want)
+	}
+	if len(nacked) != 0 {
+		t.Errorf("nacked %q, want none", nacked)
+	}
+}
+
+func TestPubSubLogSourceNacksOnShutdown(t *testing.T) {
+	server := newPubSubServer(t, []*pubsub.ReceivedMessage{
+		pubSubMessage(t, "1", LogEntry{metadata: map[string]string{"pod_name": "pod-0"}, payload: "first"}),
+		pubSubMessage(t, "2", LogEntry{metadata: map[string]string{"pod_name": "pod-0"}, payload: "second"}),
+	})
+	source := &pubSubLogSource{Subscription: "projects/project/subscriptions/logs", Credentials: server.client()}
+	out := make(chan LogEntry)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- source.Stream(ctx, out) }()
+
+	select {
+	case <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first message not streamed")
+	}
+	// Stopped before the second message is taken.
+	cancel()
+	<-done
+
+	acked, nacked := server.settled()
+	if want := []string{"1"}; !slices.Equal(acked, want) {
+		t.Errorf("acknowledged %q, want %q", acked, want)
+	}
+	if want := []string{"2"}; !slices.Equal(nacked, want) {
+		t.Errorf("nacked %q, want %q", nacked, want)
+	}
+}
+
+func TestSubscriptionName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"logs", "projects/project/subscriptions/logs"},
+		{"projects/other/subscriptions/logs", "projects/other/subscriptions/logs"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := subscriptionName(tt.name, "project"); got != tt.want {
+			t.Errorf("subscriptionName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
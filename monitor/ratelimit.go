@@ -0,0 +1,207 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// notifyQueueSize bounds the number of alerts waiting to be delivered.
+	notifyQueueSize = 50
+	// coalescedMaxLines bounds the number of alerts listed in a coalesced
+	// summary.
+	coalescedMaxLines = 20
+)
+
+// rateLimitedNotifier paces the delivery of alerts with a token bucket
+// refilled with `ratePerMin` tokens per minute and holding up to `burst`, so
+// that the few alerts of an incident go out at once while a flood of them is
+// spread out. Alerts are queued and delivered in the background, the most
+// severe first, so that a critical alert does not wait behind a backlog of
+// warnings. When the queue is full, the least severe alerts are coalesced
+// into a single summary. An alert is delivered with the context it was
+// raised with, e.g. one outliving the monitor by a grace period.
+type rateLimitedNotifier struct {
+	notifier  Notifier
+	limiter   *rate.Limiter
+	queueSize int
+	wake      chan struct{}
+
+	mu       sync.Mutex
+	queue    []queuedAlert
+	overflow []queuedAlert
+	inFlight bool
+}
+
+// queuedAlert is an alert waiting for a token, with the context of Notify.
+type queuedAlert struct {
+	ctx context.Context
+	msg Message
+}
+
+func newRateLimitedNotifier(notifier Notifier, ratePerMin, burst, queueSize int) *rateLimitedNotifier {
+	return &rateLimitedNotifier{
+		notifier:  notifier,
+		limiter:   rate.NewLimiter(rate.Every(time.Minute/time.Duration(ratePerMin)), burst),
+		queueSize: queueSize,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify enqueues `msg` for delivery. It never blocks. When the queue is
+// full, `msg` takes the place of a less severe alert, if any, which is
+// coalesced instead.
+func (r *rateLimitedNotifier) Notify(ctx context.Context, msg Message) error {
+	alert := queuedAlert{ctx: ctx, msg: msg}
+	r.mu.Lock()
+	if len(r.queue) < r.queueSize {
+		r.queue = append(r.queue, alert)
+	} else {
+		if i := r.leastSevereLocked(); i >= 0 && r.queue[i].msg.Severity < msg.Severity {
+			evicted := r.queue[i]
+			r.queue = append(slices.Delete(r.queue, i, i+1), alert)
+			alert = evicted
+		}
+		r.overflow = append(r.overflow, alert)
+		slog.Warn("notification queue full, coalescing alert", "title", alert.msg.Title, "severity", alert.msg.Severity.String())
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers the queued alerts until `ctx` is cancelled.
+func (r *rateLimitedNotifier) Run(ctx context.Context) {
+	for {
+		if !r.wait(ctx) {
+			return
+		}
+		// The alert is picked once a token is available, so that one raised
+		// meanwhile and more severe goes first.
+		if err := r.limiter.Wait(ctx); err != nil {
+			return
+		}
+		alert := r.next()
+
+		if err := r.notifier.Notify(alert.ctx, alert.msg); err != nil {
+			slog.Error("failed to deliver alert", "title", alert.msg.Title, "severity", alert.msg.Severity.String(), "err", err)
+		}
+
+		r.mu.Lock()
+		r.inFlight = false
+		r.mu.Unlock()
+	}
+}
+
+// wait waits until an alert is queued, and marks it as in flight. It
+// returns false once `ctx` is done.
+func (r *rateLimitedNotifier) wait(ctx context.Context) bool {
+	for {
+		r.mu.Lock()
+		if len(r.queue) > 0 || len(r.overflow) > 0 {
+			r.inFlight = true
+			r.mu.Unlock()
+			return true
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-r.wake:
+		}
+	}
+}
+
+// next dequeues the next alert to deliver, after wait: the most severe
+// queued alert, the earliest among equals, else a summary of the alerts
+// that overflowed the queue, delivered with the context of the latest.
+func (r *rateLimitedNotifier) next() queuedAlert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) == 0 {
+		msgs := make([]Message, len(r.overflow))
+		for i, alert := range r.overflow {
+			msgs[i] = alert.msg
+		}
+		summary := queuedAlert{ctx: r.overflow[len(r.overflow)-1].ctx, msg: coalesce(msgs)}
+		r.overflow = nil
+		return summary
+	}
+	i := 0
+	for j, alert := range r.queue {
+		if alert.msg.Severity > r.queue[i].msg.Severity {
+			i = j
+		}
+	}
+	alert := r.queue[i]
+	r.queue = slices.Delete(r.queue, i, i+1)
+	return alert
+}
+
+// leastSevereLocked returns the index of the least severe queued alert, the
+// latest among equals, or -1 if none.
+func (r *rateLimitedNotifier) leastSevereLocked() int {
+	least := -1
+	for i, alert := range r.queue {
+		if least < 0 || alert.msg.Severity <= r.queue[least].msg.Severity {
+			least = i
+		}
+	}
+	return least
+}
+
+func (r *rateLimitedNotifier) pending() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inFlight || len(r.queue) > 0 || len(r.overflow) > 0
+}
+
+// Flush waits until every queued alert has been delivered or `ctx` is done.
+func (r *rateLimitedNotifier) Flush(ctx context.Context) error {
+	for r.pending() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("flushing notification queue: %v", ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// coalesce summarizes several alerts into one, with the highest severity
+// among them.
+func coalesce(msgs []Message) Message {
+	summary := Message{
+		Severity: SeverityInfo,
+		Title:    fmt.Sprintf("%d alerts coalesced", len(msgs)),
+	}
+
+	var lines []string
+	for i, msg := range msgs {
+		if msg.Severity > summary.Severity {
+			summary.Severity = msg.Severity
+		}
+		if i < coalescedMaxLines {
+			firstLine, _, _ := strings.Cut(msg.Body, "\n")
+			lines = append(lines, fmt.Sprintf("- [%s] %s: %s", msg.Severity, msg.Title, firstLine))
+		}
+	}
+	if len(msgs) > coalescedMaxLines {
+		lines = append(lines, fmt.Sprintf("... and %d more", len(msgs)-coalescedMaxLines))
+	}
+
+	summary.Body = strings.Join(lines, "\n")
+	return summary
+}
@@ -0,0 +1,232 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timingNotifier records when it is given each message.
+type timingNotifier struct {
+	mu    sync.Mutex
+	times []time.Time
+	msgs  []Message
+}
+
+func (n *timingNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.times = append(n.times, time.Now())
+	n.msgs = append(n.msgs, msg)
+	return nil
+}
+
+func (n *timingNotifier) delivered() ([]time.Time, []Message) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]time.Time(nil), n.times...), append([]Message(nil), n.msgs...)
+}
+
+func TestRateLimitedNotifierRate(t *testing.T) {
+	const ratePerMin, burst = 600, 5
+	sent := &timingNotifier{}
+	limiter := newRateLimitedNotifier(sent, ratePerMin, burst, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go limiter.Run(ctx)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.Notify(ctx, Message{Title: fmt.Sprint(i)})
+	}
+	time.Sleep(time.Second)
+	cancel()
+
+	times, msgs := sent.delivered()
+	elapsed := time.Since(start)
+	if max := burst + int(elapsed.Minutes()*ratePerMin) + 1; len(times) > max {
+		t.Errorf("%d alerts sent in %s, want at most %d", len(times), elapsed, max)
+	}
+	if len(times) < burst {
+		t.Fatalf("%d alerts sent, want at least the burst of %d", len(times), burst)
+	}
+	if d := times[burst-1].Sub(start); d > 100*time.Millisecond {
+		t.Errorf("the burst of %d alerts took %s, want it sent at once", burst, d)
+	}
+	for i, msg := range msgs {
+		if msg.Title != fmt.Sprint(i) {
+			t.Fatalf("alert %d is %q, want the alerts in order", i, msg.Title)
+		}
+	}
+}
+
+func TestRateLimitedNotifierCoalescesOverflow(t *testing.T) {
+	sent := &timingNotifier{}
+	limiter := newRateLimitedNotifier(sent, 6000, 10, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 25; i++ {
+		limiter.Notify(ctx, Message{Severity: SeverityWarning, Title: fmt.Sprint(i)})
+	}
+	go limiter.Run(ctx)
+	flushCtx, cancelFlush := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelFlush()
+	if err := limiter.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, msgs := sent.delivered()
+	if len(msgs) != 11 {
+		t.Fatalf("%d messages sent, want the 10 queued ones and a summary", len(msgs))
+	}
+	if summary := msgs[10]; summary.Title != "15 alerts coalesced" || summary.Severity != SeverityWarning {
+		t.Errorf("summary is %q at %s, want 15 alerts coalesced at warning", summary.Title, summary.Severity)
+	}
+}
+
+func TestRateLimitedNotifierPriority(t *testing.T) {
+	msg := func(severity Severity, title string) Message {
+		return Message{Severity: severity, Title: title}
+	}
+	tests := []struct {
+		name      string
+		queueSize int
+		queued    []Message
+		want      []string
+	}{
+		{
+			name:      "most severe first",
+			queueSize: 10,
+			queued:    []Message{msg(SeverityInfo, "info"), msg(SeverityWarning, "warning"), msg(SeverityCritical, "critical"), msg(SeverityError, "error")},
+			want:      []string{"critical", "error", "warning", "info"},
+		},
+		{
+			name:      "in order among equals",
+			queueSize: 10,
+			queued:    []Message{msg(SeverityWarning, "first"), msg(SeverityCritical, "critical"), msg(SeverityWarning, "second")},
+			want:      []string{"critical", "first", "second"},
+		},
+		{
+			name:      "full queue evicts the least severe",
+			queueSize: 3,
+			queued:    []Message{msg(SeverityInfo, "first info"), msg(SeverityWarning, "warning"), msg(SeverityInfo, "second info"), msg(SeverityCritical, "critical")},
+			want:      []string{"critical", "warning", "first info", "1 alerts coalesced"},
+		},
+		{
+			name:      "full queue of more severe alerts",
+			queueSize: 2,
+			queued:    []Message{msg(SeverityCritical, "first critical"), msg(SeverityCritical, "second critical"), msg(SeverityWarning, "warning")},
+			want:      []string{"first critical", "second critical", "1 alerts coalesced"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := &timingNotifier{}
+			limiter := newRateLimitedNotifier(sent, 6000, 1, tt.queueSize)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			for _, msg := range tt.queued {
+				limiter.Notify(ctx, msg)
+			}
+			go limiter.Run(ctx)
+			flushCtx, cancelFlush := context.WithTimeout(ctx, 5*time.Second)
+			defer cancelFlush()
+			if err := limiter.Flush(flushCtx); err != nil {
+				t.Fatal(err)
+			}
+
+			_, msgs := sent.delivered()
+			titles := make([]string, len(msgs))
+			for i, msg := range msgs {
+				titles[i] = msg.Title
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("delivered %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitedNotifierCriticalOvertakesBacklog(t *testing.T) {
+	sent := &timingNotifier{}
+	// A token every 100ms, the backlog takes 5s to deliver.
+	limiter := newRateLimitedNotifier(sent, 600, 1, notifyQueueSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go limiter.Run(ctx)
+
+	for i := 0; i < notifyQueueSize; i++ {
+		limiter.Notify(ctx, Message{Severity: SeverityWarning, Title: fmt.Sprint(i)})
+	}
+	limiter.Notify(ctx, Message{Severity: SeverityCritical, Title: "critical"})
+	time.Sleep(500 * time.Millisecond)
+
+	_, msgs := sent.delivered()
+	for _, msg := range msgs {
+		if msg.Severity == SeverityCritical {
+			return
+		}
+	}
+	t.Errorf("critical alert not delivered after %d of the %d warnings queued before it", len(msgs), notifyQueueSize)
+}
+
+// contextNotifier records the contexts it is given each message with.
+type contextNotifier struct {
+	mu   sync.Mutex
+	ctxs []context.Context
+}
+
+func (n *contextNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ctxs = append(n.ctxs, ctx)
+	return nil
+}
+
+func (n *contextNotifier) contexts() []context.Context {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.ctxs)
+}
+
+func TestRateLimitedNotifierDeliversWithCallerContext(t *testing.T) {
+	type key struct{}
+	sent := &contextNotifier{}
+	limiter := newRateLimitedNotifier(sent, 6000, 1, 1)
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go limiter.Run(runCtx)
+
+	ctx := context.WithValue(context.Background(), key{}, "raised")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	limiter.Notify(ctx, Message{Title: "queued"})
+	// Queued, or coalesced if the first alert still is.
+	limiter.Notify(cancelled, Message{Title: "coalesced"})
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := limiter.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	ctxs := sent.contexts()
+	if len(ctxs) != 2 {
+		t.Fatalf("delivered %d alerts, want 2", len(ctxs))
+	}
+	for i, ctx := range ctxs {
+		if ctx.Value(key{}) != "raised" {
+			t.Errorf("alert %d delivered without the context it was raised with", i)
+		}
+	}
+	if ctxs[0].Err() != nil {
+		t.Errorf("alert delivered with a context done: %v, want the live one it was raised with", ctxs[0].Err())
+	}
+	if ctxs[1].Err() == nil {
+		t.Error("summary delivered with a live context, want the cancelled one of the alert coalesced")
+	}
+}
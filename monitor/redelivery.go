@@ -0,0 +1,48 @@
+package monitor
+
+// redeliveryFilter drops the commit logs redelivered by GCP when a dropped
+// tail stream is re-established. After a reconnect, a pod's entries at or
+// below the highest height already processed for it are skipped until it
+// reports a new height; outside of a reconnect, every entry is let through
+// so that regressions are still detected.
+type redeliveryFilter struct {
+	generation int
+	highest    map[string]int64
+	catchingUp map[string]bool
+}
+
+func newRedeliveryFilter() *redeliveryFilter {
+	return &redeliveryFilter{
+		highest:    make(map[string]int64),
+		catchingUp: make(map[string]bool),
+	}
+}
+
+// Redelivered records that `podName` reported `height` in an entry of the
+// given stream generation and reports whether it was already processed.
+func (f *redeliveryFilter) Redelivered(generation int, podName string, height int64) bool {
+	if generation != f.generation {
+		f.generation = generation
+		for pod := range f.highest {
+			f.catchingUp[pod] = true
+		}
+	}
+
+	highest, seen := f.highest[podName]
+	if f.catchingUp[podName] && height <= highest {
+		return true
+	}
+	if !seen || height > highest {
+		f.highest[podName] = height
+		delete(f.catchingUp, podName)
+	}
+	return false
+}
+
+// Reset forgets the heights processed so far, once a chain restart was
+// detected: the heights of the new chain are below them without having been
+// processed.
+func (f *redeliveryFilter) Reset() {
+	clear(f.highest)
+	clear(f.catchingUp)
+}
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRedeliveryFilter(t *testing.T) {
+	type report struct {
+		generation int
+		podName    string
+		height     int64
+		want       bool
+	}
+	tests := []struct {
+		name    string
+		reports []report
+	}{
+		{
+			name: "same stream",
+			reports: []report{
+				{0, "pod-0", 10, false},
+				{0, "pod-0", 11, false},
+				// A regression outside of a reconnect is let through.
+				{0, "pod-0", 5, false},
+			},
+		},
+		{
+			name: "redelivered after a reconnect",
+			reports: []report{
+				{0, "pod-0", 10, false},
+				{0, "pod-0", 11, false},
+				{1, "pod-0", 10, true},
+				{1, "pod-0", 11, true},
+				{1, "pod-0", 12, false},
+				// Caught up, later regressions are let through again.
+				{1, "pod-0", 5, false},
+			},
+		},
+		{
+			name: "pods caught up separately",
+			reports: []report{
+				{0, "pod-0", 10, false},
+				{0, "pod-1", 10, false},
+				{1, "pod-0", 11, false},
+				{1, "pod-1", 10, true},
+				{1, "pod-0", 10, false},
+			},
+		},
+		{
+			name: "pod first seen after a reconnect",
+			reports: []report{
+				{0, "pod-0", 10, false},
+				{1, "pod-1", 3, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRedeliveryFilter()
+			for i, r := range tt.reports {
+				if got := f.Redelivered(r.generation, r.podName, r.height); got != r.want {
+					t.Errorf("report %d: Redelivered(%d, %s, %d) = %v, want %v", i, r.generation, r.podName, r.height, got, r.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedeliveryFilterReset(t *testing.T) {
+	f := newRedeliveryFilter()
+	f.Redelivered(0, "pod-0", 100)
+	f.Reset()
+	// The chain restarted from a lower height, which was not processed even
+	// though the stream then reconnected.
+	if f.Redelivered(1, "pod-0", 1) {
+		t.Error("Redelivered() = true after Reset, want false")
+	}
+}
+
+// redelivered returns `entries` as delivered again by the stream of the given
+// generation.
+func redelivered(generation int, entries ...LogEntry) []LogEntry {
+	redelivered := make([]LogEntry, len(entries))
+	for i, entry := range entries {
+		entry.generation = generation
+		redelivered[i] = entry
+	}
+	return redelivered
+}
+
+func TestProcessCommitLogsRedelivery(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QuorumSize = 1
+	cfg.MilestoneInterval = 10
+
+	batch := []LogEntry{
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "aa"),
+		commitEntry("pod-0", 11, "bb"),
+		commitEntry("pod-1", 11, "cc"),
+	}
+	// The stream reconnected and redelivered the batch, before new entries.
+	entries := append(batch, redelivered(1, batch...)...)
+	entries = append(entries, redelivered(1, commitEntry("pod-0", 12, "dd"), commitEntry("pod-1", 12, "dd"))...)
+
+	notifier := processEntries(t, cfg, tmDeps{}, entries...)
+	if got, want := notifier.titles(), []string{"Milestone", "Root mismatch"}; !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package monitor
+
+// regressionTracker detects pods whose reported height goes backwards, e.g.
+// a node that was rewound or restored from an old snapshot.
+type regressionTracker struct {
+	// tolerance is how far below its maximum a pod may report without being
+	// flagged, to absorb logs delivered out of order.
+	tolerance int64
+	highest   map[string]int64
+	// regressed marks the pods that regressed since the last chain restart.
+	regressed map[string]bool
+}
+
+func newRegressionTracker(tolerance int) *regressionTracker {
+	return &regressionTracker{
+		tolerance: int64(tolerance),
+		highest:   make(map[string]int64),
+		regressed: make(map[string]bool),
+	}
+}
+
+// Observe records that `podName` reported `height`. On a regression it
+// returns the height the pod previously reached and true; the pod is then
+// tracked from `height` again so that a single rewind is reported once.
+func (r *regressionTracker) Observe(podName string, height int64) (int64, bool) {
+	highest, ok := r.highest[podName]
+	switch {
+	case !ok || height > highest:
+		r.highest[podName] = height
+		return 0, false
+	case height < highest-r.tolerance:
+		r.highest[podName] = height
+		r.regressed[podName] = true
+		return highest, true
+	default:
+		return 0, false
+	}
+}
+
+// Rewound returns the number of pods that regressed and have not reported
+// `height` or above since, e.g. because the chain restarted from a lower
+// height.
+func (r *regressionTracker) Rewound(height int64) int {
+	n := 0
+	for podName := range r.regressed {
+		if r.highest[podName] < height {
+			n++
+		}
+	}
+	return n
+}
+
+// Behind reports whether `podName` regressed and has not reported `height`
+// or above since.
+func (r *regressionTracker) Behind(podName string, height int64) bool {
+	return r.regressed[podName] && r.highest[podName] < height
+}
+
+// Restarted forgets the regressions once a chain restart was detected.
+func (r *regressionTracker) Restarted() {
+	clear(r.regressed)
+}
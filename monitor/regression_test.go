@@ -0,0 +1,90 @@
+package monitor
+
+import "testing"
+
+func TestRegressionTracker(t *testing.T) {
+	type report struct {
+		podName       string
+		height        int64
+		wantPrevious  int64
+		wantRegressed bool
+	}
+	tests := []struct {
+		name      string
+		tolerance int
+		reports   []report
+		// rewound is the number of pods left below height 10.
+		rewound int
+	}{
+		{
+			name: "advancing",
+			reports: []report{
+				{"pod-0", 10, 0, false},
+				{"pod-0", 11, 0, false},
+			},
+		},
+		{
+			name: "regression",
+			reports: []report{
+				{"pod-0", 10, 0, false},
+				{"pod-0", 5, 10, true},
+				// Tracked from the regressed height, reported once.
+				{"pod-0", 6, 0, false},
+			},
+			rewound: 1,
+		},
+		{
+			name:      "within tolerance",
+			tolerance: 2,
+			reports: []report{
+				{"pod-0", 10, 0, false},
+				{"pod-0", 8, 0, false},
+				{"pod-0", 7, 10, true},
+			},
+			rewound: 1,
+		},
+		{
+			name: "caught up",
+			reports: []report{
+				{"pod-0", 10, 0, false},
+				{"pod-0", 5, 10, true},
+				{"pod-0", 10, 0, false},
+			},
+		},
+		{
+			name: "several pods",
+			reports: []report{
+				{"pod-0", 10, 0, false},
+				{"pod-1", 10, 0, false},
+				{"pod-0", 5, 10, true},
+				{"pod-1", 5, 10, true},
+			},
+			rewound: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegressionTracker(tt.tolerance)
+			for i, report := range tt.reports {
+				previous, regressed := r.Observe(report.podName, report.height)
+				if previous != report.wantPrevious || regressed != report.wantRegressed {
+					t.Errorf("report %d: Observe(%s, %d) = %d, %v, want %d, %v", i, report.podName, report.height, previous, regressed, report.wantPrevious, report.wantRegressed)
+				}
+			}
+			if got := r.Rewound(10); got != tt.rewound {
+				t.Errorf("Rewound(10) = %d, want %d", got, tt.rewound)
+			}
+			if got := r.Behind("pod-0", 10); got != (tt.rewound > 0) {
+				t.Errorf("Behind(pod-0, 10) = %v, want %v", got, tt.rewound > 0)
+			}
+
+			r.Restarted()
+			if got := r.Rewound(10); got != 0 {
+				t.Errorf("Rewound(10) = %d after Restarted, want 0", got)
+			}
+			if r.Behind("pod-0", 10) {
+				t.Error("Behind(pod-0, 10) = true after Restarted, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// liveConfig is the configuration of a running monitor. Monitor.Reload
+// swaps it as a whole, so that its readers never see a partial update.
+type liveConfig struct {
+	atomic.Pointer[Config]
+}
+
+func newLiveConfig(cfg *Config) *liveConfig {
+	live := &liveConfig{}
+	live.Store(cfg)
+	return live
+}
+
+// reloadedInPlace are the settings Reload applies to the running monitor,
+// by field of Config. They are read for every log entry or every alert.
+var reloadedInPlace = []string{
+	"MilestoneInterval",
+	"MilestoneHeights",
+	"MaxTxsAlert",
+	"MaxErrorChars",
+	"AlertMention",
+	"MinSeverities",
+	"PDSeverityRules",
+}
+
+// reloadUncompared are the fields of Config that are built anew on every
+// load and cannot be compared, so their changes go unnoticed by Reload.
+var reloadUncompared = []string{"formatter", "SNSConfig", "WebhookTemplate", "NotifyRootCAs"}
+
+// Reload applies `next`, e.g. the configuration loaded again on SIGHUP, to
+// the running monitor, logging what changed. The thresholds, the mention
+// and the minimum severities of the backends are applied in place. A change
+// to the filters or the projects of a network reconnects its log streams.
+// The other settings only take effect on the next start.
+func (m *Monitor) Reload(next Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	setDefaults(&next)
+	current := m.cfg.Load()
+	reloaded := *current
+
+	var applied []string
+	currentValue, nextValue, reloadedValue := reflect.ValueOf(current).Elem(), reflect.ValueOf(&next).Elem(), reflect.ValueOf(&reloaded).Elem()
+	for _, field := range reloadedInPlace {
+		before, after := currentValue.FieldByName(field), nextValue.FieldByName(field)
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			continue
+		}
+		reloadedValue.FieldByName(field).Set(after)
+		applied = append(applied, field)
+		slog.Info("setting reloaded", "setting", field, "old", before.Interface(), "new", after.Interface())
+	}
+
+	networks, reconnect, restart := reloadNetworks(current.Networks, next.Networks)
+	if reconnect {
+		reloaded.Networks = networks
+		applied = append(applied, "Networks")
+	}
+	configType := currentValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i).Name
+		if field == "Networks" || slices.Contains(reloadedInPlace, field) || slices.Contains(reloadUncompared, field) {
+			continue
+		}
+		if !reflect.DeepEqual(currentValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			restart = append(restart, field)
+		}
+	}
+
+	m.cfg.Store(&reloaded)
+	if reconnect {
+		for _, source := range m.sources {
+			select {
+			case source.reconnect <- struct{}{}:
+			default:
+			}
+		}
+	}
+	if len(restart) > 0 {
+		slog.Warn("settings changed, they take effect on the next start", "settings", restart)
+	}
+	slog.Info("configuration reloaded", "applied", applied)
+}
+
+// reloadableSource returns the GCP source of `network` matching the filter
+// returned by `filter`, reconnected by Reload when it changes.
+func (m *Monitor) reloadableSource(network string, filter func(NetworkConfig) string, notifier Notifier) *reloadableSource {
+	source := newReloadableSource(network, filter, m.cfg, notifier)
+	source.tracer = m.tracer
+	source.metrics = m.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources = append(m.sources, source)
+	return source
+}
+
+// reloadNetworks returns the networks of `current` with the filters and the
+// projects of `next`, and whether any of them changed. Any other change, or
+// a network added or removed, is returned as requiring a restart.
+func reloadNetworks(current, next []NetworkConfig) ([]NetworkConfig, bool, []string) {
+	if len(current) != len(next) {
+		return current, false, []string{"Networks"}
+	}
+
+	networks := make([]NetworkConfig, len(current))
+	changed := false
+	var restart []string
+	for i, network := range current {
+		if next[i].Name != network.Name {
+			return current, false, []string{"Networks"}
+		}
+		reloaded := network
+		reloaded.Cluster, reloaded.PodPrefix = next[i].Cluster, next[i].PodPrefix
+		reloaded.ProjectID, reloaded.ProjectIDs = next[i].ProjectID, next[i].ProjectIDs
+		reloaded.TMLogFilter, reloaded.PDLogFilter = next[i].TMLogFilter, next[i].PDLogFilter
+		if !reflect.DeepEqual(reloaded, next[i]) {
+			restart = append(restart, "Networks["+network.Name+"]")
+		}
+		if reloaded.CommitLogFilter() != network.CommitLogFilter() || reloaded.ErrorLogFilter() != network.ErrorLogFilter() || !slices.Equal(reloaded.Projects(), network.Projects()) {
+			changed = true
+		}
+		networks[i] = reloaded
+	}
+	return networks, changed, restart
+}
+
+// reloadableSource tails the GCP logs of a network matching the filter
+// returned by `filter`. When signalled on `reconnect`, it reconnects if the
+// filter or the projects of the network changed in the reloaded
+// configuration. `out` stays open across reconnects.
+type reloadableSource struct {
+	network   string
+	filter    func(NetworkConfig) string
+	cfg       *liveConfig
+	notifier  Notifier
+	reconnect chan struct{}
+	// client tails the logs instead of a GCP logging client, if set.
+	client  tailClient
+	tracer  trace.Tracer
+	metrics *metrics
+}
+
+func newReloadableSource(network string, filter func(NetworkConfig) string, cfg *liveConfig, notifier Notifier) *reloadableSource {
+	return &reloadableSource{network: network, filter: filter, cfg: cfg, notifier: notifier, reconnect: make(chan struct{}, 1)}
+}
+
+// source returns the GCP source of the network in `cfg`.
+func (s *reloadableSource) source(cfg *Config) *gcpLogSource {
+	for _, network := range cfg.Networks {
+		if network.Name == s.network {
+			return &gcpLogSource{Credentials: cfg.Credentials, ProjectIDs: network.Projects(), Filter: s.filter(network), PayloadField: cfg.PayloadField, Config: cfg.StreamConfig, Notifier: s.notifier, client: s.client, tracer: s.tracer, metrics: s.metrics}
+		}
+	}
+	return nil
+}
+
+func (s *reloadableSource) Stream(ctx context.Context, out chan<- LogEntry) error {
+	defer close(out)
+
+	current := s.source(s.cfg.Load())
+	// The generations of the successive streams follow each other, so that
+	// the entries redelivered by a new stream are recognized.
+	base, highest := 0, 0
+	for {
+		streamCtx, cancel := context.WithCancel(ctx)
+		entries := make(chan LogEntry)
+		errc := make(chan error, 1)
+		go func(source *gcpLogSource) { errc <- source.Stream(streamCtx, entries) }(current)
+
+		reconnecting := false
+	forward:
+		for {
+			select {
+			case entry, ok := <-entries:
+				if !ok {
+					break forward
+				}
+				entry.generation += base
+				highest = max(highest, entry.generation)
+				select {
+				case out <- entry:
+				case <-ctx.Done():
+				}
+			case <-s.reconnect:
+				next := s.source(s.cfg.Load())
+				if next.Filter == current.Filter && slices.Equal(next.ProjectIDs, current.ProjectIDs) {
+					continue
+				}
+				slog.Info("log filter changed, reconnecting", "network", s.network, "filter", next.Filter, "project_ids", next.ProjectIDs)
+				current, reconnecting = next, true
+				cancel()
+				for range entries {
+				}
+				break forward
+			}
+		}
+		cancel()
+		err := <-errc
+		if !reconnecting || ctx.Err() != nil {
+			return err
+		}
+		base = highest + 1
+	}
+}
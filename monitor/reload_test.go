@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name   string
+		reload func(cfg *Config)
+		// check reports what is wrong with the configuration once reloaded.
+		check         func(cfg *Config) string
+		wantReconnect bool
+	}{
+		{
+			name:   "milestone interval",
+			reload: func(cfg *Config) { cfg.MilestoneInterval = 5 },
+			check: func(cfg *Config) string {
+				if cfg.MilestoneInterval != 5 {
+					return "MilestoneInterval not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name:   "mention",
+			reload: func(cfg *Config) { cfg.AlertMention = "<@&123>" },
+			check: func(cfg *Config) string {
+				if cfg.AlertMention != "<@&123>" {
+					return "AlertMention not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name:   "quorum size",
+			reload: func(cfg *Config) { cfg.QuorumSize = 3 },
+			check: func(cfg *Config) string {
+				if cfg.QuorumSize != 2 {
+					return "QuorumSize applied, want it left until the next start"
+				}
+				return ""
+			},
+		},
+		{
+			name: "filter",
+			reload: func(cfg *Config) {
+				cfg.Networks = []NetworkConfig{{Name: "testnet", ProjectID: "project", TMLogFilter: "severity>=INFO"}}
+			},
+			check: func(cfg *Config) string {
+				if cfg.Networks[0].TMLogFilter != "severity>=INFO" {
+					return "TMLogFilter not applied"
+				}
+				return ""
+			},
+			wantReconnect: true,
+		},
+		{
+			name: "network added",
+			reload: func(cfg *Config) {
+				cfg.Networks = append(cfg.Networks, NetworkConfig{Name: "mainnet", ProjectID: "project"})
+			},
+			check: func(cfg *Config) string {
+				if len(cfg.Networks) != 1 {
+					return "network added, want it left until the next start"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Networks = []NetworkConfig{{Name: "testnet", ProjectID: "project"}}
+			m := New(*cfg, nil)
+			source := m.reloadableSource("testnet", NetworkConfig.CommitLogFilter, nil)
+
+			next := *m.cfg.Load()
+			next.Networks = slices.Clone(next.Networks)
+			tt.reload(&next)
+			m.Reload(next)
+
+			if problem := tt.check(m.cfg.Load()); problem != "" {
+				t.Error(problem)
+			}
+			if got := len(source.reconnect) == 1; got != tt.wantReconnect {
+				t.Errorf("reconnect signalled: %v, want %v", got, tt.wantReconnect)
+			}
+		})
+	}
+}
+
+func TestReloadMilestoneInterval(t *testing.T) {
+	m := New(*testConfig(t), nil)
+	in := make(chan LogEntry)
+	notifier := &recordingNotifier{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processCommitLogs(context.Background(), context.Background(), in, notifier, m.cfg.Load(), NetworkConfig{Name: t.Name()}, tmDeps{Config: m.cfg})
+	}()
+
+	for height := int64(1); height <= 4; height++ {
+		in <- commitEntry("pod-0", height, "aa")
+		in <- commitEntry("pod-1", height, "aa")
+	}
+	next := *m.cfg.Load()
+	next.MilestoneInterval = 5
+	m.Reload(next)
+	in <- commitEntry("pod-0", 5, "aa")
+	in <- commitEntry("pod-1", 5, "aa")
+	close(in)
+	<-done
+
+	if got, want := notifier.titles(), []string{"Milestone"}; !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rootsAPI serves the roots recorded by the tm workers, read-only.
+type rootsAPI struct {
+	mu       sync.RWMutex
+	networks map[string]*monitorState
+}
+
+func newRootsAPI() *rootsAPI {
+	return &rootsAPI{networks: make(map[string]*monitorState)}
+}
+
+// Track exposes the roots of `network` held by `state`.
+func (a *rootsAPI) Track(network string, state *monitorState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.networks[network] = state
+}
+
+type rootRecord struct {
+	PodName  string     `json:"pod_name"`
+	Root     string     `json:"root"`
+	LoggedAt *time.Time `json:"logged_at,omitempty"`
+}
+
+type rootsResponse struct {
+	Network string `json:"network"`
+	Height  int64  `json:"height"`
+	// Status is "agreed" when every pod reported the same root, "disagreed"
+	// on a mismatch, and "pending" while a single pod has reported.
+	Status  string       `json:"status"`
+	Records []rootRecord `json:"records"`
+}
+
+// Handler serves `GET /roots/{height}` and `GET /roots/latest`. The network
+// is selected with `?network=`, which may be omitted when only one is
+// monitored.
+func (a *rootsAPI) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		network, state, ok := a.state(req.URL.Query().Get("network"))
+		if !ok {
+			http.Error(w, "unknown network, set ?network=", http.StatusNotFound)
+			return
+		}
+
+		var height int64
+		switch param := strings.TrimPrefix(req.URL.Path, "/roots/"); param {
+		case "latest":
+			height = state.ConfirmedHeight()
+		default:
+			var err error
+			height, err = strconv.ParseInt(param, 10, 64)
+			if err != nil || height <= 0 {
+				http.Error(w, "height must be a positive integer or latest", http.StatusBadRequest)
+				return
+			}
+		}
+
+		records, ok := state.Roots(height)
+		if !ok {
+			http.Error(w, "no roots recorded at this height", http.StatusNotFound)
+			return
+		}
+
+		resp := rootsResponse{
+			Network: network,
+			Height:  height,
+			Status:  rootsStatus(records),
+			Records: make([]rootRecord, 0, len(records)),
+		}
+		for _, r := range records {
+			record := rootRecord{PodName: r.PodName, Root: r.Root}
+			if !r.Timestamp.IsZero() {
+				loggedAt := r.Timestamp.UTC()
+				record.LoggedAt = &loggedAt
+			}
+			resp.Records = append(resp.Records, record)
+		}
+		sort.Slice(resp.Records, func(i, j int) bool { return resp.Records[i].PodName < resp.Records[j].PodName })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// state returns the state of `network`, or of the only network monitored
+// when `network` is empty.
+func (a *rootsAPI) state(network string) (string, *monitorState, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if network == "" && len(a.networks) == 1 {
+		for name, state := range a.networks {
+			return name, state, true
+		}
+	}
+	state, ok := a.networks[network]
+	return network, state, ok
+}
+
+func rootsStatus(records []rootHashRecord) string {
+	switch {
+	case len(groupByRoot(records)) > 1:
+		return "disagreed"
+	case len(records) > 1:
+		return "agreed"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignPayload(t *testing.T) {
+	// Test case 2 of RFC 4231.
+	got := signPayload([]byte("Jefe"), []byte("what do ya want for nothing?"))
+	if want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"; got != want {
+		t.Errorf("signPayload() = %s, want %s", got, want)
+	}
+}
+
+func TestBuildNotifierSigning(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		header string
+	}{
+		{"unsigned", "", ""},
+		{"default header", "secret", ""},
+		{"custom header", "secret", "X-Hub-Signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var headers http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				if body, err = io.ReadAll(r.Body); err != nil {
+					t.Error(err)
+				}
+				headers = r.Header
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cfg := &Config{DiscordWebhookURL: server.URL, WebhookSigningSecret: tt.secret, WebhookSignatureHeader: tt.header}
+			setDefaults(cfg)
+			if cfg.WebhookSignatureHeader == "" {
+				cfg.WebhookSignatureHeader = defaultSignatureHeader
+			}
+			notifier := buildNotifier(newLiveConfig(cfg), nil, newMetrics())
+			if err := notifier.Notify(context.Background(), Message{Severity: SeverityCritical, Title: "Root mismatch"}); err != nil {
+				t.Fatal(err)
+			}
+
+			got := headers.Get(cfg.WebhookSignatureHeader)
+			want := ""
+			if tt.secret != "" {
+				want = signPayload([]byte(tt.secret), body)
+			}
+			if got != want {
+				t.Errorf("%s = %q, want %q", cfg.WebhookSignatureHeader, got, want)
+			}
+		})
+	}
+}
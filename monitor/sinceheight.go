@@ -0,0 +1,55 @@
+package monitor
+
+// sinceHeightSample is the number of commit logs the height floor is seeded
+// from with --since-height=auto.
+const sinceHeightSample = 10
+
+// heightFloor skips the commit logs below a height, e.g. the backlog
+// redelivered when the monitor starts against a chain far along. In auto
+// mode, the floor is the highest height among the first commit logs. A nil
+// *heightFloor skips nothing.
+type heightFloor struct {
+	height int64
+	// sample is the number of commit logs the floor is still seeded from.
+	sample int
+}
+
+// newHeightFloor skips the commit logs below `height`, or below the highest
+// of the first ones if `auto`. It returns nil if neither is set.
+func newHeightFloor(height int64, auto bool) *heightFloor {
+	if auto {
+		return &heightFloor{sample: sinceHeightSample}
+	}
+	if height <= 0 {
+		return nil
+	}
+	return &heightFloor{height: height}
+}
+
+// Skip reports whether a commit log at `height` is below the floor, seeding
+// it first in auto mode.
+func (f *heightFloor) Skip(height int64) bool {
+	if f == nil {
+		return false
+	}
+	if f.sample > 0 {
+		f.sample--
+		if height > f.height {
+			f.height = height
+		}
+	}
+	return height < f.height
+}
+
+// Seeding reports whether the floor is still being seeded.
+func (f *heightFloor) Seeding() bool {
+	return f != nil && f.sample > 0
+}
+
+// Height returns the floor.
+func (f *heightFloor) Height() int64 {
+	if f == nil {
+		return 0
+	}
+	return f.height
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// slackNotifier posts messages to a Slack incoming webhook.
+type slackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func newSlackNotifier(webhookURL string, client *http.Client) *slackNotifier {
+	return &slackNotifier{WebhookURL: webhookURL, Client: client}
+}
+
+func slackColor(severity Severity) string {
+	switch severity {
+	case SeverityCritical, SeverityError:
+		return "#d00000"
+	case SeverityWarning:
+		return "#f2c744"
+	default:
+		return "#2eb67d"
+	}
+}
+
+func slackPayload(msg Message) map[string]interface{} {
+	var blocks []map[string]interface{}
+	if msg.Title != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type": "plain_text",
+				"text": msg.Title,
+			},
+		})
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			"text": msg.Body,
+		},
+	})
+
+	return map[string]interface{}{
+		"attachments": []map[string]interface{}{
+			{
+				"color":  slackColor(msg.Severity),
+				"blocks": blocks,
+			},
+		},
+	}
+}
+
+func (s *slackNotifier) Payload(msg Message) ([]byte, error) {
+	payloadBytes, err := json.Marshal(slackPayload(msg))
+	if err != nil {
+		return nil, fmt.Errorf("marshalling slack payload: %v", err)
+	}
+	return payloadBytes, nil
+}
+
+func (s *slackNotifier) Notify(ctx context.Context, msg Message) error {
+	payloadBytes, err := s.Payload(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("building slack request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to slack: %v", err)
+	}
+	defer drainAndClose(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// slackBody is the shape of the payloads posted to Slack.
+type slackBody struct {
+	Attachments []struct {
+		Color  string `json:"color"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	} `json:"attachments"`
+}
+
+func TestSlackNotifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        Message
+		wantColor  string
+		wantBlocks []string
+	}{
+		{"mismatch", Message{Severity: SeverityCritical, Title: "Root mismatch", Body: "block 10"}, "#d00000", []string{"header:plain_text:Root mismatch", "section:mrkdwn:block 10"}},
+		{"error", Message{Severity: SeverityError, Body: "pd error"}, "#d00000", []string{"section:mrkdwn:pd error"}},
+		{"warning", Message{Severity: SeverityWarning, Title: "Pod lagging", Body: "pod-1"}, "#f2c744", []string{"header:plain_text:Pod lagging", "section:mrkdwn:pod-1"}},
+		{"milestone", Message{Severity: SeverityInfo, Title: "Milestone", Body: "block 1000"}, "#2eb67d", []string{"header:plain_text:Milestone", "section:mrkdwn:block 1000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body slackBody
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				data, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				if err := json.Unmarshal(data, &body); err != nil {
+					t.Errorf("decoding %s: %v", data, err)
+				}
+			}))
+			defer server.Close()
+
+			if err := newSlackNotifier(server.URL, server.Client()).Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Attachments) != 1 {
+				t.Fatalf("posted %d attachments, want 1", len(body.Attachments))
+			}
+			attachment := body.Attachments[0]
+			if attachment.Color != tt.wantColor {
+				t.Errorf("color = %q, want %q", attachment.Color, tt.wantColor)
+			}
+			var blocks []string
+			for _, block := range attachment.Blocks {
+				blocks = append(blocks, block.Type+":"+block.Text.Type+":"+block.Text.Text)
+			}
+			if !slices.Equal(blocks, tt.wantBlocks) {
+				t.Errorf("blocks = %q, want %q", blocks, tt.wantBlocks)
+			}
+		})
+	}
+}
+
+func TestSlackNotifierFailure(t *testing.T) {
+	server, _ := countingServer(t, http.StatusInternalServerError)
+	err := newSlackNotifier(server.URL, server.Client()).Notify(context.Background(), Message{Body: "body"})
+	if err == nil {
+		t.Error("Notify() = nil when Slack answers 500, want an error")
+	}
+}
+
+func TestBuildNotifierDiscordAndSlack(t *testing.T) {
+	discord, discordRequests := countingServer(t, http.StatusNoContent)
+	slack, slackRequests := countingServer(t, http.StatusOK)
+	cfg := &Config{DiscordWebhookURL: discord.URL, SlackWebhookURL: slack.URL}
+	setDefaults(cfg)
+
+	notifier := buildNotifier(newLiveConfig(cfg), nil, newMetrics())
+	if err := notifier.Notify(context.Background(), Message{Severity: SeverityWarning, Title: "Pod lagging"}); err != nil {
+		t.Fatal(err)
+	}
+	if discordRequests.Load() != 1 || slackRequests.Load() != 1 {
+		t.Errorf("posted %d times to Discord and %d times to Slack, want once to each", discordRequests.Load(), slackRequests.Load())
+	}
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsMaxSubjectLen is the maximum length of the subject of an SNS message,
+// used as the subject of the emails.
+const snsMaxSubjectLen = 100
+
+// snsPublisher is the part of sns.Client used by snsNotifier.
+type snsPublisher interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// snsNotifier publishes messages to an Amazon SNS topic, which fans them out
+// to its email, SMS or Lambda subscriptions. The severity, network and event
+// are set as message attributes, for subscription filter policies.
+type snsNotifier struct {
+	TopicARN string
+	Client   snsPublisher
+}
+
+// newSNSNotifier publishes to `topicARN` with the region and credentials of
+// `awsCfg`.
+func newSNSNotifier(topicARN string, awsCfg aws.Config) *snsNotifier {
+	return &snsNotifier{
+		TopicARN: topicARN,
+		Client:   sns.NewFromConfig(awsCfg),
+	}
+}
+
+// input renders `msg` as a Publish request.
+func (n *snsNotifier) input(msg Message) *sns.PublishInput {
+	subject := msg.Title
+	if subject == "" {
+		subject = "check-apphash alert"
+	}
+	if msg.Resolved {
+		subject = "[resolved] " + subject
+	}
+	// Subjects are a single line of printable characters.
+	subject = strings.Join(strings.Fields(subject), " ")
+	if runes := []rune(subject); len(runes) > snsMaxSubjectLen {
+		subject = string(runes[:snsMaxSubjectLen])
+	}
+
+	attributes := map[string]snstypes.MessageAttributeValue{
+		"severity": snsAttribute(msg.Severity.String()),
+	}
+	if msg.Network != "" {
+		attributes["network"] = snsAttribute(msg.Network)
+	}
+	if msg.Event != "" {
+		attributes["event"] = snsAttribute(msg.Event)
+	}
+	if msg.EnvTag != "" {
+		attributes["env"] = snsAttribute(msg.EnvTag)
+	}
+
+	return &sns.PublishInput{
+		TopicArn:          aws.String(n.TopicARN),
+		Subject:           aws.String(subject),
+		Message:           aws.String(msg.Body),
+		MessageAttributes: attributes,
+	}
+}
+
+func snsAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (n *snsNotifier) Payload(msg Message) ([]byte, error) {
+	input := n.input(msg)
+	attributes := make(map[string]string, len(input.MessageAttributes))
+	for name, value := range input.MessageAttributes {
+		attributes[name] = aws.ToString(value.StringValue)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"topic_arn":  n.TopicARN,
+		"subject":    aws.ToString(input.Subject),
+		"message":    aws.ToString(input.Message),
+		"attributes": attributes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling sns message: %v", err)
+	}
+	return payload, nil
+}
+
+func (n *snsNotifier) Notify(ctx context.Context, msg Message) error {
+	if _, err := n.Client.Publish(ctx, n.input(msg)); err != nil {
+		return fmt.Errorf("publishing to sns: %v", err)
+	}
+	return nil
+}
+
+// snsRegion returns the region of a topic ARN, i.e.
+// arn:aws:sns:<region>:<account>:<topic>.
+func snsRegion(topicARN string) (string, error) {
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[3] == "" || parts[5] == "" {
+		return "", fmt.Errorf("%q is not an SNS topic ARN, e.g. arn:aws:sns:us-east-1:123456789012:alerts", topicARN)
+	}
+	return parts[3], nil
+}
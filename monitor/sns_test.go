@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// mockSNS records the Publish requests, failing them with `err` if set.
+type mockSNS struct {
+	err    error
+	inputs []*sns.PublishInput
+}
+
+func (m *mockSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	m.inputs = append(m.inputs, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sns.PublishOutput{MessageId: aws.String("id")}, nil
+}
+
+func TestSNSNotifier(t *testing.T) {
+	const topic = "arn:aws:sns:eu-west-1:123456789012:alerts"
+	tests := []struct {
+		name           string
+		msg            Message
+		wantSubject    string
+		wantAttributes map[string]string
+	}{
+		{
+			name:           "mismatch",
+			msg:            Message{Severity: SeverityCritical, Title: "Root mismatch", Body: "block 10", Network: "testnet", Event: EventMismatch, EnvTag: "prod"},
+			wantSubject:    "Root mismatch",
+			wantAttributes: map[string]string{"severity": "critical", "network": "testnet", "event": "mismatch", "env": "prod"},
+		},
+		{
+			name:           "without a title",
+			msg:            Message{Severity: SeverityWarning, Body: "pod lagging"},
+			wantSubject:    "check-apphash alert",
+			wantAttributes: map[string]string{"severity": "warning"},
+		},
+		{
+			name:           "resolved",
+			msg:            Message{Severity: SeverityInfo, Title: "Monitoring restored", Resolved: true},
+			wantSubject:    "[resolved] Monitoring restored",
+			wantAttributes: map[string]string{"severity": "info"},
+		},
+		{
+			name:           "multiline and long title",
+			msg:            Message{Severity: SeverityError, Title: "pd error\n" + strings.Repeat("x", 200)},
+			wantSubject:    ("pd error " + strings.Repeat("x", 200))[:snsMaxSubjectLen],
+			wantAttributes: map[string]string{"severity": "error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockSNS{}
+			notifier := &snsNotifier{TopicARN: topic, Client: client}
+			if err := notifier.Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			if len(client.inputs) != 1 {
+				t.Fatalf("published %d messages, want 1", len(client.inputs))
+			}
+
+			input := client.inputs[0]
+			if got := aws.ToString(input.TopicArn); got != topic {
+				t.Errorf("topic = %s, want %s", got, topic)
+			}
+			if got := aws.ToString(input.Subject); got != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", got, tt.wantSubject)
+			}
+			if got := aws.ToString(input.Message); got != tt.msg.Body {
+				t.Errorf("message = %q, want %q", got, tt.msg.Body)
+			}
+			attributes := make(map[string]string)
+			for name, value := range input.MessageAttributes {
+				if aws.ToString(value.DataType) != "String" {
+					t.Errorf("attribute %s of type %s, want String", name, aws.ToString(value.DataType))
+				}
+				attributes[name] = aws.ToString(value.StringValue)
+			}
+			if !maps.Equal(attributes, tt.wantAttributes) {
+				t.Errorf("attributes = %v, want %v", attributes, tt.wantAttributes)
+			}
+		})
+	}
+}
+
+func TestSNSNotifierFailure(t *testing.T) {
+	notifier := &snsNotifier{TopicARN: "arn:aws:sns:eu-west-1:123456789012:alerts", Client: &mockSNS{err: errors.New("throttled")}}
+	if err := notifier.Notify(context.Background(), Message{Body: "body"}); err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Errorf("Notify() = %v, want the SNS error", err)
+	}
+}
+
+func TestSNSRegion(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    string
+		wantErr bool
+	}{
+		{"arn:aws:sns:us-east-1:123456789012:alerts", "us-east-1", false},
+		{"arn:aws-cn:sns:cn-north-1:123456789012:alerts", "cn-north-1", false},
+		{"arn:aws:sqs:us-east-1:123456789012:queue", "", true},
+		{"arn:aws:sns::123456789012:alerts", "", true},
+		{"alerts", "", true},
+	}
+	for _, tt := range tests {
+		got, err := snsRegion(tt.arn)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("snsRegion(%q) = %q, %v, want %q, error: %v", tt.arn, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// sqliteDriver is the database/sql driver used by sqliteStore. It is only
+// registered in binaries built with `-tags sqlite`.
+const sqliteDriver = "sqlite3"
+
+// sqliteAvailable reports whether the SQLite driver is linked in.
+func sqliteAvailable() bool {
+	return slices.Contains(sql.Drivers(), sqliteDriver)
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS commits (
+	network     TEXT NOT NULL,
+	height      INTEGER NOT NULL,
+	pod_name    TEXT NOT NULL,
+	root        TEXT NOT NULL,
+	hash        TEXT NOT NULL,
+	num_txs     INTEGER NOT NULL,
+	logged_at   TIMESTAMP,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS commits_height_pod ON commits (network, height, pod_name);
+`
+
+// storedCommit is a commit log as recorded by sqliteStore.
+type storedCommit struct {
+	Network string
+	Height  int64
+	PodName string
+	Root    string
+	Hash    string
+	NumTxs  int64
+	// LoggedAt is when the pod logged the commit, zero if unknown.
+	LoggedAt   time.Time
+	ObservedAt time.Time
+}
+
+// sqliteStore keeps a historical record of every commit log parsed, to help
+// investigate mismatches after the fact. A nil *sqliteStore records nothing.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore opens the database at `path`, creating its schema if
+// needed.
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating sqlite schema: %v", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// Record inserts a commit log reported by a pod. A pod reporting the same
+// height again, e.g. after a stream reconnect, is only recorded once.
+func (s *sqliteStore) Record(ctx context.Context, network string, commitLog *LogData, observedAt time.Time) error {
+	if s == nil {
+		return nil
+	}
+
+	var loggedAt sql.NullTime
+	if !commitLog.Timestamp.IsZero() {
+		loggedAt = sql.NullTime{Time: commitLog.Timestamp.UTC(), Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO commits (network, height, pod_name, root, hash, num_txs, logged_at, observed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		network, commitLog.Height, commitLog.PodName, commitLog.Root, commitLog.Hash, commitLog.NumTxs, loggedAt, observedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording commit at height %d: %v", commitLog.Height, err)
+	}
+	return nil
+}
+
+// RecordsAt returns every commit log recorded for `network` at `height`,
+// ordered by pod name.
+func (s *sqliteStore) RecordsAt(ctx context.Context, network string, height int64) ([]storedCommit, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT network, height, pod_name, root, hash, num_txs, logged_at, observed_at FROM commits WHERE network = ? AND height = ? ORDER BY pod_name`,
+		network, height,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying commits at height %d: %v", height, err)
+	}
+	defer rows.Close()
+
+	var records []storedCommit
+	for rows.Next() {
+		var r storedCommit
+		var loggedAt sql.NullTime
+		if err := rows.Scan(&r.Network, &r.Height, &r.PodName, &r.Root, &r.Hash, &r.NumTxs, &loggedAt, &r.ObservedAt); err != nil {
+			return nil, fmt.Errorf("scanning commit at height %d: %v", height, err)
+		}
+		r.LoggedAt = loggedAt.Time
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
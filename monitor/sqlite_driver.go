@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package monitor
+
+// The SQLite driver requires cgo, so it is only linked in when building with
+// `-tags sqlite`.
+import _ "github.com/mattn/go-sqlite3"
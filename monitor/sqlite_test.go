@@ -0,0 +1,51 @@
+//go:build sqlite
+
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := openSQLiteStore(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	loggedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	commits := []LogData{
+		{Height: 42, PodName: "pod-1", Root: "bb", Hash: "cc", NumTxs: 1, Timestamp: loggedAt},
+		{Height: 42, PodName: "pod-0", Root: "aa", Hash: "cc", NumTxs: 1},
+		// Redelivered, only recorded once.
+		{Height: 42, PodName: "pod-0", Root: "dd", Hash: "cc", NumTxs: 1},
+		{Height: 43, PodName: "pod-0", Root: "ee", Hash: "ff", NumTxs: 2},
+	}
+	for _, commit := range commits {
+		commit := commit
+		if err := store.Record(ctx, "testnet", &commit, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := store.RecordsAt(ctx, "testnet", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("RecordsAt(42) = %+v, want the reports of pod-0 and pod-1", records)
+	}
+	if r := records[0]; r.PodName != "pod-0" || r.Root != "aa" || !r.LoggedAt.IsZero() {
+		t.Errorf("RecordsAt(42)[0] = %+v, want the first report of pod-0, without a logged time", r)
+	}
+	if r := records[1]; r.PodName != "pod-1" || r.Root != "bb" || !r.LoggedAt.Equal(loggedAt) {
+		t.Errorf("RecordsAt(42)[1] = %+v, want the report of pod-1 logged at %s", r, loggedAt)
+	}
+	if records, err := store.RecordsAt(ctx, "other", 42); err != nil || len(records) != 0 {
+		t.Errorf("RecordsAt() of another network = %+v, %v, want none", records, err)
+	}
+}
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// chainTip tracks the highest height reported by any pod and when it last
+// increased.
+type chainTip struct {
+	mu         sync.Mutex
+	height     int64
+	advancedAt time.Time
+}
+
+func (t *chainTip) Observe(height int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if height > t.height {
+		t.height = height
+		t.advancedAt = now
+	}
+}
+
+// Reset forgets the tip once the chain restarted from a lower height.
+func (t *chainTip) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.height, t.advancedAt = 0, time.Time{}
+}
+
+// Get returns the tip height and when it was reached.
+func (t *chainTip) Get() (int64, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.height, t.advancedAt
+}
+
+const (
+	// stallCheckInterval is how often the chain tip is checked for a stall.
+	stallCheckInterval = 10 * time.Second
+	// stallEscalationFactor is the multiple of the stall timeout after which
+	// an ongoing stall is escalated to critical.
+	stallEscalationFactor = 3
+)
+
+// stallWatcher alerts when the chain tip has not advanced for `timeout`,
+// escalates if the stall persists, and posts a recovery notice once the
+// chain moves again.
+func stallWatcher(ctx, notifyCtx context.Context, network NetworkConfig, tip *chainTip, timeout time.Duration, notifier Notifier) {
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+
+	// Severity of the alert already posted for the ongoing stall, if any.
+	var alerted *Severity
+	var stalledHeight int64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			height, advancedAt := tip.Get()
+			if height == 0 {
+				continue
+			}
+
+			// The tip moves up as the chain advances, or down when it
+			// restarted from a lower height.
+			if alerted != nil && height != stalledHeight {
+				slog.Info("chain resumed", "event", "stall_recovered", "network", network.Name, "height", height)
+				notify(notifyCtx, notifier, Message{
+					Severity:    SeverityInfo,
+					Title:       "Chain resumed",
+					Body:        fmt.Sprintf("the chain advanced again and is now at height %d", height),
+					IncidentKey: fmt.Sprintf("stall-%d", stalledHeight),
+					Resolved:    true,
+				})
+				alerted = nil
+				continue
+			}
+
+			stalledFor := now.Sub(advancedAt)
+			severity := SeverityWarning
+			if stalledFor >= stallEscalationFactor*timeout {
+				severity = SeverityCritical
+			}
+			if stalledFor < timeout || (alerted != nil && *alerted >= severity) {
+				continue
+			}
+
+			slog.Error("chain stalled", "event", "stall", "network", network.Name, "height", height, "stalled_for", stalledFor)
+			notify(notifyCtx, notifier, Message{
+				Severity:    severity,
+				Title:       "Chain stalled",
+				Body:        fmt.Sprintf("no pod has reported a height above %d for %s", height, stalledFor.Round(time.Second)),
+				IncidentKey: fmt.Sprintf("stall-%d", height),
+			})
+			alerted = &severity
+			stalledHeight = height
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// savedState is the part of the tm worker state that survives a restart.
+type savedState struct {
+	ConfirmedHeight int64                      `json:"confirmed_height"`
+	Roots           map[int64][]rootHashRecord `json:"roots"`
+	// AnnouncedMilestones and AlertedMismatches are the heights already
+	// notified, so that they are not notified again after a restart.
+	AnnouncedMilestones []int64 `json:"announced_milestones,omitempty"`
+	AlertedMismatches   []int64 `json:"alerted_mismatches,omitempty"`
+	// Incidents are the recent mismatch incidents, see incidents.
+	Incidents []incident `json:"incidents,omitempty"`
+}
+
+// sortedHeights returns the heights of `set`, in ascending order.
+func sortedHeights(set map[int64]bool) []int64 {
+	heights := make([]int64, 0, len(set))
+	for height := range set {
+		heights = append(heights, height)
+	}
+	slices.Sort(heights)
+	return heights
+}
+
+// stateStore persists the tm worker state across restarts.
+type stateStore interface {
+	Load() (*savedState, error)
+	Save(state *savedState) error
+}
+
+// fileStateStore stores the state as a JSON document on disk.
+type fileStateStore struct {
+	Path string
+}
+
+func newFileStateStore(path string) *fileStateStore {
+	return &fileStateStore{Path: path}
+}
+
+func (s *fileStateStore) Load() (*savedState, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decoding state file: %w", err)
+	}
+	if state.Roots == nil {
+		state.Roots = make(map[int64][]rootHashRecord)
+	}
+	return &state, nil
+}
+
+// Save atomically replaces the state file.
+func (s *fileStateStore) Save(state *savedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding state: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("creating state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing state file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing state file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return fmt.Errorf("replacing state file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileStateStore(t *testing.T) {
+	store := newFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if _, err := store.Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load() = %v before any save, want fs.ErrNotExist", err)
+	}
+
+	saved := &savedState{
+		ConfirmedHeight:     11,
+		Roots:               map[int64][]rootHashRecord{11: {{PodName: "pod-0", Root: "aa"}}},
+		AnnouncedMilestones: []int64{10},
+		AlertedMismatches:   []int64{11},
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ConfirmedHeight != 11 || len(loaded.Roots[11]) != 1 || loaded.Roots[11][0].Root != "aa" {
+		t.Errorf("Load() = %+v, want the saved state", loaded)
+	}
+	if !slices.Equal(loaded.AnnouncedMilestones, saved.AnnouncedMilestones) || !slices.Equal(loaded.AlertedMismatches, saved.AlertedMismatches) {
+		t.Errorf("Load() notified heights = %v and %v, want %v and %v", loaded.AnnouncedMilestones, loaded.AlertedMismatches, saved.AnnouncedMilestones, saved.AlertedMismatches)
+	}
+}
+
+func TestProcessCommitLogsRestartedMidStream(t *testing.T) {
+	// The logs of the first run are delivered again after the restart.
+	firstRun := []LogEntry{
+		commitEntry("pod-0", 10, "aa"),
+		commitEntry("pod-1", 10, "aa"),
+		commitEntry("pod-0", 11, "bb"),
+		commitEntry("pod-1", 11, "cc"),
+	}
+	tests := []struct {
+		name      string
+		secondRun []LogEntry
+		want      []string
+	}{
+		{"logs delivered again", firstRun, nil},
+		{
+			name: "chain moving on",
+			secondRun: append(firstRun[:len(firstRun):len(firstRun)],
+				commitEntry("pod-0", 15, "dd"),
+				commitEntry("pod-1", 15, "ee"),
+			),
+			want: []string{"Milestone", "Root mismatch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.MilestoneInterval = 5
+			store := newFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+
+			notifier := processEntries(t, cfg, tmDeps{Store: store}, firstRun...)
+			if got, want := notifier.titles(), []string{"Milestone", "Root mismatch"}; !slices.Equal(got, want) {
+				t.Fatalf("first run notified %q, want %q", got, want)
+			}
+
+			notifier = processEntries(t, cfg, tmDeps{Store: store}, tt.secondRun...)
+			if got := notifier.titles(); !slices.Equal(got, tt.want) {
+				t.Errorf("notified %q after the restart, want %q", got, tt.want)
+			}
+		})
+	}
+}
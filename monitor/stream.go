@@ -0,0 +1,291 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	logging "cloud.google.com/go/logging/apiv2"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamConfig controls how a dropped tail stream is re-established.
+type StreamConfig struct {
+	// InitialBackoff is the delay before the first reconnect attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between consecutive reconnect attempts.
+	MaxBackoff time.Duration
+	// Multiplier is applied to the delay after every failed attempt.
+	Multiplier float64
+	// Jitter is the fraction of the delay that is randomized, e.g. 0.2
+	// spreads a 10s delay over [8s, 12s].
+	Jitter float64
+	// StartupJitter bounds the random delay before the first connection,
+	// so that the streams of a monitor do not all connect at once.
+	StartupJitter time.Duration
+}
+
+func DefaultStreamConfig() StreamConfig {
+	return StreamConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     60 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		StartupJitter:  2 * time.Second,
+	}
+}
+
+// The delays are drawn from the top-level math/rand functions, which are
+// seeded randomly at startup, so that restarted monitors do not reconnect in
+// lockstep either.
+
+// startupDelay returns the delay to wait before the first connection.
+func (c StreamConfig) startupDelay() time.Duration {
+	if c.StartupJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(c.StartupJitter)))
+}
+
+// waitStartup waits out the startup delay, reporting whether `ctx` is still
+// live.
+func (c StreamConfig) waitStartup(ctx context.Context, source string) bool {
+	delay := c.startupDelay()
+	if delay == 0 {
+		return ctx.Err() == nil
+	}
+	slog.Debug("staggering stream startup", "source", source, "delay", delay)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// backoff returns the delay to wait before the given (zero-based) reconnect attempt.
+func (c StreamConfig) backoff(attempt int) time.Duration {
+	delay := float64(c.InitialBackoff)
+	for i := 0; i < attempt && delay < float64(c.MaxBackoff); i++ {
+		delay *= c.Multiplier
+	}
+	if delay > float64(c.MaxBackoff) {
+		delay = float64(c.MaxBackoff)
+	}
+	if c.Jitter > 0 {
+		delay += delay * c.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// tailClient is the part of the GCP logging client the tail streams use,
+// so that it can be swapped for a fake.
+type tailClient interface {
+	TailLogEntries(ctx context.Context) (tailStream, error)
+	Close() error
+}
+
+// tailStream is a tail stream opened by a tailClient.
+type tailStream interface {
+	Send(req *loggingpb.TailLogEntriesRequest) error
+	Recv() (*loggingpb.TailLogEntriesResponse, error)
+	CloseSend() error
+}
+
+// loggingTailClient is the tailClient of a GCP logging client.
+type loggingTailClient struct {
+	client *logging.Client
+}
+
+func (c loggingTailClient) TailLogEntries(ctx context.Context) (tailStream, error) {
+	return c.client.TailLogEntries(ctx)
+}
+
+func (c loggingTailClient) Close() error {
+	return c.client.Close()
+}
+
+// errStreamSend is returned by tailLogEntries when the tail request could not
+// be sent on a freshly opened stream.
+var errStreamSend = errors.New("stream.Send error")
+
+// streamLogsWithFilter tails the log entries of `projectIDs` matching `filter`
+// with `client`, or if nil a GCP logging client authenticated with
+// `credentials` (nil for the Application Default Credentials), and pushes
+// them to `out`, reading structured payloads from `payloadField`.
+// Stream failures are retried with exponential backoff, `out` is only closed
+// once `ctx` is cancelled. Failures that need attention, see
+// streamFailureMessage, are reported to `notifier`, if set. Every stream is
+// traced with `tracer` and counted in `metrics`, if set.
+func streamLogsWithFilter(ctx context.Context, client tailClient, credentials option.ClientOption, projectIDs []string, filter string, payloadField string, cfg StreamConfig, notifier Notifier, tracer trace.Tracer, metrics *metrics, out chan<- LogEntry) error {
+	defer close(out)
+	tracer = tracerOrNoop(tracer)
+	metrics = metricsOrUnregistered(metrics)
+
+	if client == nil {
+		loggingClient, err := newLoggingClient(ctx, credentials)
+		if err != nil {
+			return fmt.Errorf("NewClient error: %w", err)
+		}
+		client = loggingTailClient{loggingClient}
+	}
+	defer client.Close()
+
+	slog.Info("connected to GCP", "project_ids", projectIDs)
+
+	resourceNames := make([]string, 0, len(projectIDs))
+	for _, projectID := range projectIDs {
+		resourceNames = append(resourceNames, "projects/"+projectID)
+	}
+	req := &loggingpb.TailLogEntriesRequest{
+		ResourceNames: resourceNames,
+		Filter:        filter,
+	}
+
+	if !cfg.waitStartup(ctx, filter) {
+		return nil
+	}
+
+	attempt := 0
+	// blind is set while the stream is refused for lack of access.
+	blind := false
+	for generation := 0; ; generation++ {
+		_, span := tracer.Start(ctx, "tail_log_entries", trace.WithAttributes(attribute.String("filter", filter), attribute.Int("generation", generation)))
+		received, err := tailLogEntries(ctx, client, req, generation, payloadField, metrics, out)
+		span.SetAttributes(attribute.Bool("received", received))
+		setSpanError(span, err)
+		span.End()
+		if ctx.Err() != nil {
+			break
+		}
+		if received {
+			attempt = 0
+			if blind && notifier != nil {
+				notify(ctx, notifier, Message{
+					Severity:    SeverityInfo,
+					Title:       "Monitoring restored",
+					Body:        fmt.Sprintf("the logs matching `%s` are received again", filter),
+					IncidentKey: blindIncidentKey,
+					Resolved:    true,
+				})
+			}
+			blind = false
+		}
+
+		delay := cfg.backoff(attempt)
+		attempt++
+		slog.Warn("stream interrupted, reconnecting", "filter", filter, "err", err, "delay", delay)
+		msg, ok := streamFailureMessage(filter, err, delay)
+		if ok && msg.IncidentKey == blindIncidentKey {
+			// Only the first failure of an outage is alerted.
+			ok = !blind
+			blind = true
+		}
+		if ok && notifier != nil {
+			notify(ctx, notifier, msg)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	slog.Info("terminating routine", "filter", filter)
+	return nil
+}
+
+// blindIncidentKey identifies the incident of a monitor denied access to the
+// logs.
+const blindIncidentKey = "monitoring-blind"
+
+// streamFailureMessage returns the alert raised when a stream fails with
+// `err`, if any. Authentication and permission errors mean the monitor sees
+// nothing until an operator steps in, so they are critical; failing to send
+// the tail request only degrades monitoring. Other errors are transient.
+func streamFailureMessage(filter string, err error, delay time.Duration) (Message, bool) {
+	switch code := status.Code(err); {
+	case code == codes.Unauthenticated || code == codes.PermissionDenied:
+		return Message{
+			Severity:    SeverityCritical,
+			Title:       "Monitoring is blind",
+			Body:        fmt.Sprintf("access to the logs matching `%s` was refused (%s), no commit or error is checked until the credentials or the logging permissions are fixed: %v\nretrying in %s", filter, code, err, delay.Round(time.Second)),
+			IncidentKey: blindIncidentKey,
+		}, true
+	case errors.Is(err, errStreamSend):
+		return Message{
+			Severity: SeverityWarning,
+			Title:    "Monitoring degraded",
+			Body:     fmt.Sprintf("could not request the logs matching `%s`: %v\nretrying in %s", filter, err, delay.Round(time.Second)),
+		}, true
+	default:
+		return Message{}, false
+	}
+}
+
+// tailLogEntries opens a single tail stream and forwards its entries, tagged
+// with `generation`, until the stream fails. It reports whether at least one
+// response was received.
+func tailLogEntries(ctx context.Context, client tailClient, req *loggingpb.TailLogEntriesRequest, generation int, payloadField string, metrics *metrics, out chan<- LogEntry) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.TailLogEntries(ctx)
+	if err != nil {
+		return false, fmt.Errorf("TailLogEntries error: %w", err)
+	}
+	defer stream.CloseSend()
+
+	slog.Info("established stream", "filter", req.Filter)
+	metrics.activeStreams.Inc()
+	defer metrics.activeStreams.Dec()
+
+	if err := stream.Send(req); err != nil {
+		return false, fmt.Errorf("%w: %w", errStreamSend, err)
+	}
+
+	received := false
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return received, fmt.Errorf("stream EOF")
+		}
+		if err != nil {
+			return received, fmt.Errorf("stream.Recv error: %w", err)
+		}
+		received = true
+
+		for _, entry := range resp.Entries {
+			metadata := entry.GetResource().GetLabels()
+			payload := entryPayload(entry, payloadField)
+			if payload == "" {
+				slog.Debug("skipping entry without payload", "field", payloadField, "insert_id", entry.GetInsertId())
+				continue
+			}
+
+			select {
+			case out <- LogEntry{
+				metadata:   metadata,
+				payload:    payload,
+				timestamp:  entryTimestamp(entry),
+				severity:   entry.GetSeverity(),
+				generation: generation,
+			}:
+			case <-ctx.Done():
+				return received, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeStream replays its responses, then fails with `err`, or blocks until
+// its context is done if `err` is nil. Sending the request fails with
+// `sendErr`, if set.
+type fakeStream struct {
+	ctx       context.Context
+	responses []*loggingpb.TailLogEntriesResponse
+	err       error
+	sendErr   error
+}
+
+func (s *fakeStream) Send(req *loggingpb.TailLogEntriesRequest) error {
+	return s.sendErr
+}
+
+func (s *fakeStream) Recv() (*loggingpb.TailLogEntriesResponse, error) {
+	if len(s.responses) > 0 {
+		resp := s.responses[0]
+		s.responses = s.responses[1:]
+		return resp, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	<-s.ctx.Done()
+	return nil, status.FromContextError(s.ctx.Err()).Err()
+}
+
+func (s *fakeStream) CloseSend() error {
+	return nil
+}
+
+// fakeTailClient opens copies of its streams in turn, the last one again
+// once they are exhausted, recording when.
+type fakeTailClient struct {
+	streams []fakeStream
+
+	mu     sync.Mutex
+	opened []time.Time
+}
+
+func (c *fakeTailClient) TailLogEntries(ctx context.Context) (tailStream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened = append(c.opened, time.Now())
+	stream := c.streams[min(len(c.opened), len(c.streams))-1]
+	stream.ctx = ctx
+	return &stream, nil
+}
+
+func (c *fakeTailClient) Close() error {
+	return nil
+}
+
+// openedAt returns when the streams were opened so far.
+func (c *fakeTailClient) openedAt() []time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Time(nil), c.opened...)
+}
+
+// textResponse returns a response carrying an entry per line.
+func textResponse(lines ...string) *loggingpb.TailLogEntriesResponse {
+	resp := &loggingpb.TailLogEntriesResponse{}
+	for _, line := range lines {
+		resp.Entries = append(resp.Entries, &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: line}})
+	}
+	return resp
+}
+
+// testStreamConfig reconnects after 10ms, then 100ms, without jitter.
+func testStreamConfig() StreamConfig {
+	return StreamConfig{InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 10}
+}
+
+// streamEntries streams with `client` until `n` entries were received, and
+// returns them along with the messages notified.
+func streamEntries(t *testing.T, client *fakeTailClient, n int) ([]LogEntry, *recordingNotifier) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan LogEntry)
+	notifier := &recordingNotifier{}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- streamLogsWithFilter(ctx, client, nil, []string{"project"}, "filter", defaultPayloadField, testStreamConfig(), notifier, nil, nil, out)
+	}()
+
+	var entries []LogEntry
+	for len(entries) < n {
+		select {
+		case entry := <-out:
+			entries = append(entries, entry)
+		case <-ctx.Done():
+			t.Fatalf("received %d entries, want %d", len(entries), n)
+		}
+	}
+	cancel()
+	for range out {
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("streamLogsWithFilter() = %v once cancelled, want nil", err)
+	}
+	return entries, notifier
+}
+
+func TestStreamLogsReconnects(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "stream reset")
+	tests := []struct {
+		name    string
+		streams []fakeStream
+		// want are the payloads received, by stream generation.
+		want        map[int][]string
+		wantNotices []string
+	}{
+		{
+			name: "after an error",
+			streams: []fakeStream{
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a", "b")}, err: unavailable},
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("c")}},
+			},
+			want: map[int][]string{0: {"a", "b"}, 1: {"c"}},
+		},
+		{
+			name: "after a clean EOF",
+			streams: []fakeStream{
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}, err: io.EOF},
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("b")}},
+			},
+			want: map[int][]string{0: {"a"}, 1: {"b"}},
+		},
+		{
+			name: "after failing to connect",
+			streams: []fakeStream{
+				{err: unavailable},
+				{err: unavailable},
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}},
+			},
+			want: map[int][]string{2: {"a"}},
+		},
+		{
+			name: "after failing to send the request",
+			streams: []fakeStream{
+				{sendErr: io.ErrClosedPipe},
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}},
+			},
+			want:        map[int][]string{1: {"a"}},
+			wantNotices: []string{"Monitoring degraded"},
+		},
+		{
+			name: "access refused",
+			streams: []fakeStream{
+				{err: status.Error(codes.PermissionDenied, "no access")},
+				{err: status.Error(codes.PermissionDenied, "no access")},
+				{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}},
+			},
+			want:        map[int][]string{2: {"a"}},
+			wantNotices: []string{"Monitoring is blind", "Monitoring restored"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 0
+			for _, payloads := range tt.want {
+				n += len(payloads)
+			}
+			entries, notifier := streamEntries(t, &fakeTailClient{streams: tt.streams}, n)
+
+			got := make(map[int][]string)
+			for _, entry := range entries {
+				got[entry.generation] = append(got[entry.generation], entry.payload)
+			}
+			for generation, payloads := range tt.want {
+				if !slices.Equal(got[generation], payloads) {
+					t.Errorf("stream %d delivered %q, want %q", generation, got[generation], payloads)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("entries delivered by streams %v, want %v", got, tt.want)
+			}
+			// The restored notice is raised once the next stream fails.
+			if titles := notifier.titles(); len(titles) > 0 && !slices.Equal(titles, tt.wantNotices[:len(titles)]) {
+				t.Errorf("notified %q, want %q", titles, tt.wantNotices)
+			}
+		})
+	}
+}
+
+func TestStreamLogsBackoffReset(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "stream reset")
+	client := &fakeTailClient{streams: []fakeStream{
+		{err: unavailable},
+		{err: unavailable},
+		// Receiving resets the backoff.
+		{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}, err: unavailable},
+		{responses: []*loggingpb.TailLogEntriesResponse{textResponse("b")}},
+	}}
+	streamEntries(t, client, 2)
+
+	opened := client.openedAt()
+	if len(opened) != 4 {
+		t.Fatalf("opened %d streams, want 4", len(opened))
+	}
+	tests := []struct {
+		after    int
+		min, max time.Duration
+	}{
+		{0, 10 * time.Millisecond, 90 * time.Millisecond},
+		{1, 100 * time.Millisecond, time.Second},
+		{2, 10 * time.Millisecond, 90 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if d := opened[tt.after+1].Sub(opened[tt.after]); d < tt.min || d > tt.max {
+			t.Errorf("reconnected %s after stream %d, want between %s and %s", d, tt.after, tt.min, tt.max)
+		}
+	}
+}
+
+func TestStreamLogsActiveStreams(t *testing.T) {
+	metrics := newMetrics()
+	server := httptest.NewServer(metricsHandler(newMetricsRegistry(metrics, "")))
+	defer server.Close()
+	const sample = "apphash_active_log_streams"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan LogEntry)
+	done := make(chan struct{})
+	client := &fakeTailClient{streams: []fakeStream{
+		{responses: []*loggingpb.TailLogEntriesResponse{textResponse("a")}, err: io.EOF},
+		{responses: []*loggingpb.TailLogEntriesResponse{textResponse("b")}},
+	}}
+	go func() {
+		defer close(done)
+		streamLogsWithFilter(ctx, client, nil, []string{"project"}, "filter", defaultPayloadField, testStreamConfig(), nil, nil, metrics, out)
+	}()
+
+	// The first stream ended once "a" was received, only the second one is
+	// established once "b" is.
+	for _, want := range []string{"a", "b"} {
+		if entry := <-out; entry.payload != want {
+			t.Fatalf("received %q, want %q", entry.payload, want)
+		}
+	}
+	if got := scrape(t, server.URL, sample); got != 1 {
+		t.Errorf("%s = %v while a stream is established, want 1", sample, got)
+	}
+
+	cancel()
+	for range out {
+	}
+	<-done
+	if got := scrape(t, server.URL, sample); got != 0 {
+		t.Errorf("%s = %v once the streams stopped, want 0", sample, got)
+	}
+}
+
+func TestStreamLogsStructuredPayload(t *testing.T) {
+	line := "finalizing commit of block module=consensus height=42 hash=abcdef root=0123ab num_txs=3"
+	jsonPayload, err := structpb.NewStruct(map[string]interface{}{"message": line, "level": "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoPayload, err := anypb.New(jsonPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		entry *loggingpb.LogEntry
+	}{
+		{"text", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: line}}},
+		{"json", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: jsonPayload}}},
+		{"proto", &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_ProtoPayload{ProtoPayload: protoPayload}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeTailClient{streams: []fakeStream{{
+				responses: []*loggingpb.TailLogEntriesResponse{{Entries: []*loggingpb.LogEntry{tt.entry}}},
+			}}}
+			entries, _ := streamEntries(t, client, 1)
+
+			parser := newCommitLogParser(DefaultCommitLogPatterns())
+			got, err := parser.Parse("pod-0", entries[0].payload, time.Time{})
+			if err != nil {
+				t.Fatalf("parsing the streamed payload %q: %v", entries[0].payload, err)
+			}
+			if got.Height != 42 || got.Hash != "abcdef" || got.Root != "0123ab" || got.NumTxs != 3 {
+				t.Errorf("parsed %+v, want block 42 with root 0123ab", got)
+			}
+		})
+	}
+}
+
+func TestStreamFailureMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantTitle    string
+		wantSeverity Severity
+		wantKey      string
+	}{
+		{"permission denied", status.Error(codes.PermissionDenied, "missing roles/logging.viewer"), "Monitoring is blind", SeverityCritical, blindIncidentKey},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "token expired"), "Monitoring is blind", SeverityCritical, blindIncidentKey},
+		{"send failure", fmt.Errorf("%w: %v", errStreamSend, io.ErrClosedPipe), "Monitoring degraded", SeverityWarning, ""},
+		{"unavailable", status.Error(codes.Unavailable, "stream reset"), "", 0, ""},
+		{"EOF", io.EOF, "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, ok := streamFailureMessage("filter", tt.err, 10*time.Second)
+			if ok != (tt.wantTitle != "") {
+				t.Fatalf("streamFailureMessage() alerted: %v, want %v", ok, tt.wantTitle != "")
+			}
+			if !ok {
+				return
+			}
+			if msg.Title != tt.wantTitle || msg.Severity != tt.wantSeverity || msg.IncidentKey != tt.wantKey {
+				t.Errorf("alerted %q at %s with key %q, want %q at %s with key %q", msg.Title, msg.Severity, msg.IncidentKey, tt.wantTitle, tt.wantSeverity, tt.wantKey)
+			}
+			if !strings.Contains(msg.Body, "`filter`") || !strings.Contains(msg.Body, "retrying in 10s") {
+				t.Errorf("alert %q does not name the filter and the retry delay", msg.Body)
+			}
+		})
+	}
+}
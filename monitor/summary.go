@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// summary counts what happened on a network between two periodic summaries.
+// It is shared by the tm and pd workers. A nil *summary counts nothing.
+type summary struct {
+	mu         sync.Mutex
+	pods       map[string]bool
+	mismatches int
+	pdErrors   int
+}
+
+func newSummary() *summary {
+	return &summary{pods: make(map[string]bool)}
+}
+
+// summaryCounts is what happened since the previous summary.
+type summaryCounts struct {
+	// ActivePods is the number of pods that reported a commit.
+	ActivePods int
+	Mismatches int
+	PDErrors   int
+}
+
+func (s *summary) ObserveCommit(podName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pods[podName] = true
+}
+
+func (s *summary) CountMismatch() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mismatches++
+}
+
+func (s *summary) CountPDError() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pdErrors++
+}
+
+// Reset returns the counts since the previous call and starts over.
+func (s *summary) Reset() summaryCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := summaryCounts{
+		ActivePods: len(s.pods),
+		Mismatches: s.mismatches,
+		PDErrors:   s.pdErrors,
+	}
+	s.pods = make(map[string]bool)
+	s.mismatches, s.pdErrors = 0, 0
+	return counts
+}
+
+// summaryBody renders the periodic summary of a network.
+func summaryBody(counts summaryCounts, interval time.Duration, confirmedHeight int64, laggingPod string, maxLag int64) string {
+	lag := "none"
+	if laggingPod != "" && maxLag > 0 {
+		lag = fmt.Sprintf("%d blocks (%s)", maxLag, laggingPod)
+	}
+	return fmt.Sprintf("monitor up for %s\nconfirmed height: **%d**\nactive pods: %d\nhighest lag: %s\nin the last %s: %d mismatches, %d pd errors",
+		time.Since(startTime).Round(time.Second), confirmedHeight, counts.ActivePods, lag, interval, counts.Mismatches, counts.PDErrors)
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	telegramAPIURL = "https://api.telegram.org"
+	// telegramMaxMessageLen is the maximum length of a Telegram message, in
+	// characters.
+	telegramMaxMessageLen = 4096
+)
+
+// telegramNotifier posts messages to a chat through the Telegram Bot API.
+type telegramNotifier struct {
+	BotToken string
+	ChatID   string
+	APIURL   string
+	Client   *http.Client
+}
+
+func newTelegramNotifier(botToken, chatID string, client *http.Client) *telegramNotifier {
+	return &telegramNotifier{
+		BotToken: botToken,
+		ChatID:   chatID,
+		APIURL:   telegramAPIURL,
+		Client:   client,
+	}
+}
+
+// payloads renders `msg` as one or more sendMessage requests, splitting it
+// to honour Telegram's message length limit.
+func (t *telegramNotifier) payloads(msg Message) ([][]byte, error) {
+	text := msg.Body
+	if msg.Title != "" {
+		text = fmt.Sprintf("*%s*\n%s", msg.Title, msg.Body)
+	}
+
+	var payloads [][]byte
+	for _, chunk := range splitMessage(text, telegramMaxMessageLen) {
+		payload, err := json.Marshal(map[string]interface{}{
+			"chat_id":    t.ChatID,
+			"text":       chunk,
+			"parse_mode": "Markdown",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("marshalling telegram payload: %v", err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads, nil
+}
+
+func (t *telegramNotifier) Payload(msg Message) ([]byte, error) {
+	payloads, err := t.payloads(msg)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Join(payloads, []byte("\n")), nil
+}
+
+func (t *telegramNotifier) Notify(ctx context.Context, msg Message) error {
+	payloads, err := t.payloads(msg)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.APIURL, t.BotToken)
+	for i, payload := range payloads {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("building telegram request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := t.Client.Do(req)
+		if err != nil {
+			// Do not leak the bot token, which is part of the URL.
+			return fmt.Errorf("posting to telegram: %v", strings.ReplaceAll(err.Error(), t.BotToken, "<token>"))
+		}
+		drainAndClose(resp)
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("telegram returned %s for part %d/%d", resp.Status, i+1, len(payloads))
+		}
+	}
+	return nil
+}
+
+// splitMessage splits `text` into chunks of at most `limit` characters,
+// breaking on newlines when possible.
+func splitMessage(text string, limit int) []string {
+	runes := []rune(text)
+
+	var chunks []string
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return append(chunks, string(runes))
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// telegramRequest is a sendMessage request received by the fake Bot API.
+type telegramRequest struct {
+	Path      string
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+// telegramServer records the sendMessage requests it receives.
+func telegramServer(t *testing.T) (*httptest.Server, func() []telegramRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []telegramRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := telegramRequest{Path: r.URL.Path}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding the request: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, req)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []telegramRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]telegramRequest(nil), requests...)
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		// want are the texts sent, in order.
+		want []string
+	}{
+		{"title and body", Message{Title: "Root mismatch", Body: "block 10"}, []string{"*Root mismatch*\nblock 10"}},
+		{"body only", Message{Body: "pd error"}, []string{"pd error"}},
+		{
+			name: "long payload split",
+			msg:  Message{Body: strings.Repeat("a", 4000) + "\n" + strings.Repeat("b", 1000)},
+			want: []string{strings.Repeat("a", 4000) + "\n", strings.Repeat("b", 1000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := telegramServer(t)
+			telegram := newTelegramNotifier("123:token", "-100", server.Client())
+			telegram.APIURL = server.URL
+			if err := telegram.Notify(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+
+			got := requests()
+			if len(got) != len(tt.want) {
+				t.Fatalf("sent %d messages, want %d", len(got), len(tt.want))
+			}
+			for i, req := range got {
+				if req.Path != "/bot123:token/sendMessage" {
+					t.Errorf("posted to %s, want /bot123:token/sendMessage", req.Path)
+				}
+				if req.ChatID != "-100" || req.ParseMode != "Markdown" {
+					t.Errorf("chat_id = %q and parse_mode = %q, want -100 and Markdown", req.ChatID, req.ParseMode)
+				}
+				if req.Text != tt.want[i] {
+					t.Errorf("message %d = %.40q…, want %.40q…", i, req.Text, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramNotifierHidesToken(t *testing.T) {
+	telegram := newTelegramNotifier("123:secret", "-100", &http.Client{})
+	telegram.APIURL = "http://127.0.0.1:0"
+	err := telegram.Notify(context.Background(), Message{Body: "body"})
+	if err == nil {
+		t.Fatal("Notify() succeeded with an unreachable API, want an error")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Notify() = %v, want the bot token hidden", err)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"exact", "0123456789", 10, []string{"0123456789"}},
+		{"no newline", "0123456789abc", 10, []string{"0123456789", "abc"}},
+		{"on a newline", "012345\n789abc", 10, []string{"012345\n", "789abc"}},
+		{"newline too early", "01\n3456789abc", 10, []string{"01\n3456789", "abc"}},
+		{"runes", strings.Repeat("é", 12), 10, []string{strings.Repeat("é", 10), "éé"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			for _, chunk := range got {
+				if n := utf8.RuneCountInString(chunk); n > tt.limit {
+					t.Errorf("chunk of %d characters, want at most %d", n, tt.limit)
+				}
+			}
+		})
+	}
+}
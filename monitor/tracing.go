@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracingServiceName identifies the monitor in the exported traces.
+const tracingServiceName = "check-apphash"
+
+// newTracerProvider returns the provider of the tracers of the pipeline,
+// exporting their spans with OTLP over HTTP to `endpoint`, e.g.
+// http://localhost:4318, the traces being posted to its /v1/traces. When
+// `endpoint` is empty, the spans are not recorded. The returned function
+// exports the spans ended so far, then stops exporting.
+func newTracerProvider(endpoint string) (trace.TracerProvider, func(context.Context) error, error) {
+	if endpoint == "" {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating the OTLP exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", tracingServiceName))),
+	)
+	return provider, provider.Shutdown, nil
+}
+
+// tracerOrNoop returns `tracer`, or one recording nothing if it is nil, e.g.
+// for the log sources of an embedder.
+func tracerOrNoop(tracer trace.Tracer) trace.Tracer {
+	if tracer == nil {
+		return noop.NewTracerProvider().Tracer(tracingServiceName)
+	}
+	return tracer
+}
+
+// setSpanError marks `span` as failed with `err`, if not nil.
+func setSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// tracingNotifier records a span for every message delivered by a backend.
+type tracingNotifier struct {
+	backend  string
+	tracer   trace.Tracer
+	notifier Notifier
+}
+
+func (n tracingNotifier) Notify(ctx context.Context, msg Message) error {
+	// Messages are queued before delivery, so the span of the entry that
+	// raised the message is carried by the message rather than `ctx`.
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, msg.spanContext)
+	}
+	ctx, span := n.tracer.Start(ctx, "notify", trace.WithAttributes(
+		attribute.String("backend", n.backend),
+		attribute.String("severity", msg.Severity.String()),
+		attribute.String("title", msg.Title),
+	))
+	defer span.End()
+
+	err := n.notifier.Notify(ctx, msg)
+	setSpanError(span, err)
+	return err
+}
+
+// tracingTransport records the status of the HTTP responses on the span
+// carried by the request, if any.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if resp != nil {
+		trace.SpanFromContext(req.Context()).SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	}
+	return resp, err
+}
@@ -0,0 +1,189 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingTracer returns a tracer whose ended spans are recorded.
+func recordingTracer(t *testing.T) (trace.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider.Tracer(tracingServiceName), recorder
+}
+
+// spanAttr returns the value of the attribute `key` of `span`.
+func spanAttr(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// failingNotifier fails to deliver every message.
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, msg Message) error {
+	return errors.New("backend unavailable")
+}
+
+func TestProcessCommitLogsSpans(t *testing.T) {
+	tracer, recorder := recordingTracer(t)
+	processEntries(t, testConfig(t), tmDeps{Tracer: tracer},
+		commitEntry("pod-0", 10, "aa"),
+		LogEntry{metadata: map[string]string{podNameLabel: "pod-0"}, payload: "not a commit log"},
+	)
+
+	var entries, parses []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "process_log_entry":
+			entries = append(entries, span)
+		case "parse_commit_log":
+			parses = append(parses, span)
+		}
+	}
+	if len(entries) != 2 || len(parses) != 2 {
+		t.Fatalf("ended %d process_log_entry and %d parse_commit_log spans, want 2 of each", len(entries), len(parses))
+	}
+
+	tests := []struct {
+		key  string
+		want attribute.Value
+	}{
+		{"network", attribute.StringValue(t.Name())},
+		{"pod_name", attribute.StringValue("pod-0")},
+		{"height", attribute.Int64Value(10)},
+	}
+	for _, tt := range tests {
+		if got, ok := spanAttr(entries[0], tt.key); !ok || got != tt.want {
+			t.Errorf("process_log_entry %s = %v, want %v", tt.key, got.Emit(), tt.want.Emit())
+		}
+	}
+	if _, ok := spanAttr(entries[1], "height"); ok {
+		t.Error("process_log_entry of a line that is no commit log has a height")
+	}
+	for i, parse := range parses {
+		if parse.Parent().SpanID() != entries[i].SpanContext().SpanID() {
+			t.Errorf("parse_commit_log %d is not a child of its process_log_entry", i)
+		}
+		// A line that is no commit log is not a parse failure.
+		if parse.Status().Code == codes.Error {
+			t.Errorf("parse_commit_log %d failed: %s", i, parse.Status().Description)
+		}
+	}
+}
+
+func TestTracingNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		notifier Notifier
+		want     codes.Code
+	}{
+		{"delivered", &recordingNotifier{}, codes.Unset},
+		{"failed", failingNotifier{}, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := recordingTracer(t)
+			// The message is raised while processing an entry, then queued,
+			// and delivered outside of the context of the entry.
+			entryCtx, entrySpan := tracer.Start(context.Background(), "process_log_entry")
+			queued := &recordingNotifier{}
+			notify(entryCtx, queued, Message{Severity: SeverityError, Title: "Root mismatch"})
+			entrySpan.End()
+
+			traced := tracingNotifier{backend: "discord", tracer: tracer, notifier: tt.notifier}
+			traced.Notify(context.Background(), queued.messages[0])
+
+			spans := recorder.Ended()
+			if len(spans) != 2 || spans[1].Name() != "notify" {
+				t.Fatalf("ended %d spans, want process_log_entry then notify", len(spans))
+			}
+			notifySpan := spans[1]
+			if notifySpan.Parent().SpanID() != entrySpan.SpanContext().SpanID() {
+				t.Error("notify span is not a child of the span of the entry that raised the message")
+			}
+			if got, _ := spanAttr(notifySpan, "backend"); got.AsString() != "discord" {
+				t.Errorf("notify backend = %q, want discord", got.AsString())
+			}
+			if got := notifySpan.Status().Code; got != tt.want {
+				t.Errorf("notify status = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	tracer, recorder := recordingTracer(t)
+
+	ctx, span := tracer.Start(context.Background(), "notify")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: &tracingTransport{base: http.DefaultTransport}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+
+	if got, _ := spanAttr(recorder.Ended()[0], "http.status_code"); got.AsInt64() != http.StatusTooManyRequests {
+		t.Errorf("http.status_code = %d, want %d", got.AsInt64(), http.StatusTooManyRequests)
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			exports.Add(1)
+		}
+	}))
+	defer collector.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		want     int32
+	}{
+		{"disabled", "", 0},
+		{"exported on shutdown", collector.URL + "/", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exports.Store(0)
+			provider, shutdown, err := newTracerProvider(tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, span := provider.Tracer(tracingServiceName).Start(context.Background(), "process_log_entry")
+			span.End()
+			if err := shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := exports.Load(); got != tt.want {
+				t.Errorf("collector received %d exports, want %d", got, tt.want)
+			}
+		})
+	}
+}
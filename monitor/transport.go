@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// newNotifyTransport returns the transport of the notifiers. It goes through
+// the proxy set in HTTPS_PROXY or HTTP_PROXY, if any, and trusts the extra
+// CAs of the configuration.
+func newNotifyTransport(cfg *Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.NotifyRootCAs == nil && !cfg.NotifyInsecureSkipVerify {
+		return transport
+	}
+
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:            cfg.NotifyRootCAs,
+		InsecureSkipVerify: cfg.NotifyInsecureSkipVerify,
+	}
+	if cfg.NotifyInsecureSkipVerify {
+		slog.Warn("TLS CERTIFICATE VERIFICATION IS DISABLED for the notifiers: alerts can be intercepted, never use NOTIFY_INSECURE_SKIP_VERIFY outside of testing")
+	}
+	return transport
+}
+
+// loadCABundle returns the system CAs along with those of the PEM file at
+// `path`.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return pool, nil
+}
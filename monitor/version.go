@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Build information, set at build time with e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// startTime is when the process started.
+var startTime = time.Now()
+
+type versionResponse struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildDate string    `json:"build_date"`
+	StartTime time.Time `json:"start_time"`
+	Uptime    string    `json:"uptime"`
+}
+
+// versionHandler serves the build information and the uptime, e.g. to check
+// that a deploy rolled out.
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		StartTime: startTime.UTC(),
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+	})
+}
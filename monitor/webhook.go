@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookNotifier posts a user-templated JSON body to an arbitrary URL, to
+// integrate with systems that have no dedicated backend.
+type webhookNotifier struct {
+	URL      string
+	Headers  http.Header
+	Template *template.Template
+	Client   *http.Client
+}
+
+// webhookData is what webhook payload templates are rendered with. The
+// fields of the commit log the alert is about, if any, are promoted.
+type webhookData struct {
+	LogData
+	Event       string
+	Severity    string
+	Network     string
+	EnvTag      string
+	Title       string
+	Body        string
+	IncidentKey string
+	Resolved    bool
+}
+
+// webhookTemplateFuncs are available to payload templates. `json` encodes a
+// value as JSON, e.g. {"text": {{json .Body}}}.
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func newWebhookNotifier(url string, headers http.Header, payloadTemplate *template.Template, client *http.Client) *webhookNotifier {
+	return &webhookNotifier{URL: url, Headers: headers, Template: payloadTemplate, Client: client}
+}
+
+// parseWebhookTemplate parses a payload template and checks that it renders
+// valid JSON for a sample alert.
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing payload template: %v", err)
+	}
+
+	sample := Message{
+		Severity: SeverityCritical,
+		Event:    EventMismatch,
+		Network:  "testnet",
+		Title:    "Root mismatch",
+		Body:     "ROOT MISMATCH DETECTED AT BLOCK 1\n\"ab\": pod-0",
+		Commit:   &LogData{Height: 1, Hash: "ab", Root: "cd", PodName: "pod-0", Timestamp: time.Now()},
+	}
+	payload, err := newWebhookNotifier("", nil, tmpl, nil).Payload(sample)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(payload) {
+		return nil, fmt.Errorf("payload template does not render valid JSON, got %s", payload)
+	}
+	return tmpl, nil
+}
+
+func (w *webhookNotifier) Payload(msg Message) ([]byte, error) {
+	data := webhookData{
+		Event:       msg.Event,
+		Severity:    msg.Severity.String(),
+		Network:     msg.Network,
+		EnvTag:      msg.EnvTag,
+		Title:       msg.Title,
+		Body:        msg.Body,
+		IncidentKey: msg.IncidentKey,
+		Resolved:    msg.Resolved,
+	}
+	if msg.Commit != nil {
+		data.LogData = *msg.Commit
+	}
+
+	var b bytes.Buffer
+	if err := w.Template.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("rendering webhook payload: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, msg Message) error {
+	payload, err := w.Payload(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building webhook request: %v", err)
+	}
+	for name, values := range w.Headers {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %v", err)
+	}
+	defer drainAndClose(resp)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// parseHeaders parses a comma-separated list of `Name: value` headers.
+func parseHeaders(v string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, field := range strings.Split(v, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%q is not a Name: value header", strings.TrimSpace(field))
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}